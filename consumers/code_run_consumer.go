@@ -0,0 +1,58 @@
+package consumers
+
+import (
+	"context"
+	"log"
+
+	"github.com/spf13/viper"
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/services"
+)
+
+// StartCodeRunConsumer runs the code runs of the code run topic until ctx is done.
+// Code runs are short, so a code run in progress is not stopped by jobCtx.
+func StartCodeRunConsumer(ctx, jobCtx context.Context, serviceKit *services.ServiceKit) error {
+	topicName := viper.GetString("KAFKA_CODE_RUN_TOPIC")
+	if topicName == "" {
+		log.Fatal("KAFKA_CODE_RUN_TOPIC is not set")
+	}
+
+	groupID := viper.GetString("KAFKA_CODE_RUN_GROUP")
+	if groupID == "" {
+		log.Fatal("KAFKA_CODE_RUN_GROUP is not set")
+	}
+
+	if serviceKit.KafkaService == nil {
+		log.Fatal("Kafka service is not initialized")
+	}
+
+	// the run topic is newer than the submission topic, so create it on first start
+	if !serviceKit.KafkaService.IsTopicExist(topicName) {
+		err := serviceKit.KafkaService.CreateTopic(topicName, 1)
+		if err != nil {
+			log.Fatal("Failed to create topic: ", err)
+		}
+	}
+
+	topics := readJobTopics(topicName, "KAFKA_CODE_RUN_RETRY_TOPIC", "KAFKA_CODE_RUN_DEAD_LETTER_TOPIC")
+	processor := newJobProcessor(serviceKit, topics, entities.JobTypeCodeRun, func(ctx context.Context, job *entities.JobEnvelope) error {
+		// get code run
+		codeRun, err := serviceKit.CodeRunService.GetCodeRunByID(job.ID)
+		if err != nil {
+			return err
+		}
+
+		// process code run
+		codeRun, err = serviceKit.CodeRunService.ProcessCodeRun(codeRun)
+		if err != nil {
+			return err
+		}
+
+		log.Println("Code run processed:", codeRun.ID)
+		return nil
+	})
+
+	log.Println("Start consuming code run topic...")
+
+	return consumeJobs(ctx, jobCtx, serviceKit, topics, groupID, entities.JobTypeCodeRun, readWorkers("JUDGE_CODE_RUN_WORKERS"), processor)
+}
@@ -0,0 +1,93 @@
+package consumers
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/services"
+)
+
+// StartConsumers runs the submission and code run consumers and the sandbox reaper until
+// ctx is done or a consumer fails. The consumers stop taking new jobs and the jobs in
+// progress get shutdownTimeout to finish. Jobs still running after that are stopped, their
+// submissions are queued again and their messages are consumed again after the restart.
+// The error of a failed consumer is returned.
+func StartConsumers(ctx context.Context, serviceKit *services.ServiceKit, shutdownTimeout time.Duration) error {
+	consumeCtx, stopConsuming := context.WithCancelCause(ctx)
+	defer stopConsuming(nil)
+
+	go StartSandboxReaper(consumeCtx, serviceKit)
+
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	done := make(chan struct{})
+	go func() {
+		var wg sync.WaitGroup
+		for _, start := range []func(ctx, jobCtx context.Context, serviceKit *services.ServiceKit) error{
+			StartCodeRunConsumer,
+			StartSubmissionConsumer,
+		} {
+			wg.Add(1)
+			go func(start func(ctx, jobCtx context.Context, serviceKit *services.ServiceKit) error) {
+				defer wg.Done()
+
+				// a failed consumer stops the others the same way as a shutdown
+				err := start(consumeCtx, jobCtx, serviceKit)
+				if err != nil {
+					stopConsuming(err)
+				}
+			}(start)
+		}
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return failure(consumeCtx)
+	case <-consumeCtx.Done():
+	}
+
+	err := failure(consumeCtx)
+	if err != nil {
+		log.Println("Consumer failed, shutting down:", err)
+	}
+	log.Println("Waiting for jobs in progress...")
+
+	select {
+	case <-done:
+		log.Println("Jobs in progress are done")
+	case <-time.After(shutdownTimeout):
+		log.Println("Jobs in progress did not finish in time, stopping them")
+		stopJobs()
+		<-done
+	}
+
+	return err
+}
+
+// requeueSubmission queues a submission whose judging was stopped again, so it is not
+// left compiling or running.
+func requeueSubmission(serviceKit *services.ServiceKit, submissionID uint) {
+	submission, err := serviceKit.SubmissionService.GetSubmissionByID(submissionID)
+	if err != nil {
+		log.Println("Failed to get submission ID:", submissionID, "with error:", err)
+		return
+	}
+
+	if submission.State != entities.SubmissionStateCompiling && submission.State != entities.SubmissionStateRunning {
+		return
+	}
+
+	_, err = serviceKit.SubmissionService.RequeueSubmission(submission)
+	if err != nil {
+		log.Println("Failed to requeue submission ID:", submissionID, "with error:", err)
+		return
+	}
+
+	log.Println("Submission requeued:", submissionID)
+}
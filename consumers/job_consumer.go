@@ -0,0 +1,142 @@
+package consumers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/services"
+)
+
+// readJobTopics returns the topics of a job topic with the retry and dead letter topics
+// read from the env vars, defaulting to names derived from the job topic.
+func readJobTopics(topic, retryTopicEnv, deadLetterTopicEnv string) services.JobTopics {
+	topics := services.JobTopics{
+		Topic:           topic,
+		RetryTopic:      viper.GetString(retryTopicEnv),
+		DeadLetterTopic: viper.GetString(deadLetterTopicEnv),
+	}
+	if topics.RetryTopic == "" {
+		topics.RetryTopic = topic + "-retry"
+	}
+	if topics.DeadLetterTopic == "" {
+		topics.DeadLetterTopic = topic + "-dead-letter"
+	}
+	return topics
+}
+
+// readWorkers returns the number of jobs a consumer processes at the same time.
+func readWorkers(workersEnv string) int {
+	// read env var workersEnv for the jobs processed at the same time
+	// if it is empty, process one job at a time
+	workers := viper.GetInt(workersEnv)
+	if workers <= 0 {
+		workers = 1
+	}
+	return workers
+}
+
+// newJobProcessor creates the processor of a job type with the retry settings from the env vars.
+func newJobProcessor(serviceKit *services.ServiceKit, topics services.JobTopics, jobType string, handler services.JobHandler) services.JobProcessor {
+	// read env var "JOB_MAX_ATTEMPTS" for the attempts of a job before it is dead lettered
+	// if JOB_MAX_ATTEMPTS is empty, try a job 3 times
+	maxAttempts := viper.GetInt("JOB_MAX_ATTEMPTS")
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+
+	// read env var "JOB_RETRY_BACKOFF_MS" for the wait before the first retry
+	// if JOB_RETRY_BACKOFF_MS is empty, wait a second
+	retryBackoffMs := viper.GetInt("JOB_RETRY_BACKOFF_MS")
+	if retryBackoffMs <= 0 {
+		retryBackoffMs = 1000
+	}
+
+	return services.NewJobProcessor(
+		serviceKit.KafkaService,
+		serviceKit.DeadLetterService,
+		topics,
+		jobType,
+		maxAttempts,
+		time.Duration(retryBackoffMs)*time.Millisecond,
+		handler,
+	)
+}
+
+// consumeJobs processes the jobs of the job topic and of its retry topic on workers
+// goroutines shared by both topics, creating the retry and dead letter topics on first start.
+// It returns once ctx is done and the jobs in progress are processed, jobCtx stops them.
+// A message that cannot be handed on stops both topics and its error is returned.
+func consumeJobs(ctx, jobCtx context.Context, serviceKit *services.ServiceKit, topics services.JobTopics, groupID, jobType string, workers int, processor services.JobProcessor) error {
+	for _, topic := range []string{topics.RetryTopic, topics.DeadLetterTopic} {
+		if !serviceKit.KafkaService.IsTopicExist(topic) {
+			err := serviceKit.KafkaService.CreateTopic(topic, 1)
+			if err != nil {
+				log.Fatal("Failed to create topic: ", err)
+			}
+		}
+	}
+
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+
+	// when a message cannot be handed on, no later offset of its partition can be committed,
+	// so consuming is stopped with the error and the message is consumed again after the restart
+	dispatcher := services.NewJobDispatcher(jobType, workers, func(message *services.KafkaMessage) {
+		err := processor.Process(jobCtx, message)
+		if err != nil && jobCtx.Err() == nil {
+			log.Println("Failed to hand on", jobType, "message with error:", err)
+			stop(err)
+		}
+	})
+
+	// retried jobs wait for their backoff without holding up new jobs
+	var wg sync.WaitGroup
+	for _, topic := range []string{topics.Topic, topics.RetryTopic} {
+		wg.Add(1)
+		go func(topic string) {
+			defer wg.Done()
+			consumeJobTopic(ctx, serviceKit, topic, groupID, jobType, dispatcher)
+		}(topic)
+	}
+	wg.Wait()
+	dispatcher.Close()
+
+	return failure(ctx)
+}
+
+// consumeJobTopic dispatches the messages of a topic until ctx is done. A retried job waits
+// for its backoff before it is dispatched, which pauses the topic instead of taking a worker.
+func consumeJobTopic(ctx context.Context, serviceKit *services.ServiceKit, topic, groupID, jobType string, dispatcher services.JobDispatcher) {
+	messageC, errorC := serviceKit.KafkaService.Consume(ctx, topic, groupID)
+
+	go func() {
+		for err := range errorC {
+			log.Println(err)
+		}
+	}()
+
+	for message := range messageC {
+		job, err := entities.ParseJobEnvelope(message.Value, jobType)
+		if err == nil && services.WaitForRetry(ctx, job) != nil {
+			// the message is not committed and is consumed again after the restart
+			return
+		}
+
+		dispatcher.Dispatch(message)
+	}
+}
+
+// failure returns the error ctx was stopped with after a failure, nil when it was not stopped
+// or stopped for a shutdown.
+func failure(ctx context.Context) error {
+	err := context.Cause(ctx)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,57 @@
+package consumers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/wuttinanhi/code-judge-system/services"
+)
+
+// ReadSandboxReaperMaxAge returns the age after which sandbox containers and volumes are
+// taken as left behind by a dead judge.
+func ReadSandboxReaperMaxAge() time.Duration {
+	// read env var "SANDBOX_REAPER_MAX_AGE_MS" for the age of sandboxes to remove
+	// if SANDBOX_REAPER_MAX_AGE_MS is empty, remove those older than an hour
+	maxAgeMs := viper.GetInt("SANDBOX_REAPER_MAX_AGE_MS")
+	if maxAgeMs <= 0 {
+		maxAgeMs = 3600000
+	}
+	return time.Duration(maxAgeMs) * time.Millisecond
+}
+
+// StartSandboxReaper removes the sandboxes left behind by dead judges on start and then
+// periodically until ctx is done. It returns at once when the sandbox has no reaper.
+func StartSandboxReaper(ctx context.Context, serviceKit *services.ServiceKit) {
+	if serviceKit.SandboxReaper == nil {
+		return
+	}
+
+	// read env var "SANDBOX_REAPER_INTERVAL_MS" for how often sandboxes are reaped
+	// if SANDBOX_REAPER_INTERVAL_MS is empty, reap every 5 minutes
+	intervalMs := viper.GetInt("SANDBOX_REAPER_INTERVAL_MS")
+	if intervalMs <= 0 {
+		intervalMs = 300000
+	}
+	maxAge := ReadSandboxReaperMaxAge()
+
+	ticker := time.NewTicker(time.Duration(intervalMs) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		containers, volumes, err := serviceKit.SandboxReaper.Reap(maxAge)
+		if err != nil {
+			log.Println("Failed to reap sandboxes:", err)
+		}
+		if containers > 0 || volumes > 0 {
+			log.Println("Reaped", containers, "sandbox containers and", volumes, "sandbox volumes")
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
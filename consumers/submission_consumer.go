@@ -1,15 +1,18 @@
 package consumers
 
 import (
+	"context"
 	"log"
-	"strconv"
 
 	"github.com/spf13/viper"
 	"github.com/wuttinanhi/code-judge-system/configs"
+	"github.com/wuttinanhi/code-judge-system/entities"
 	"github.com/wuttinanhi/code-judge-system/services"
 )
 
-func StartSubmissionConsumer(serviceKit *services.ServiceKit) {
+// StartSubmissionConsumer judges the submissions of the submission topic until ctx is done,
+// jobCtx stops the submissions being judged.
+func StartSubmissionConsumer(ctx, jobCtx context.Context, serviceKit *services.ServiceKit) error {
 	configs.LoadConfig()
 
 	kafkaHost := viper.GetString("KAFKA_HOST")
@@ -35,39 +38,59 @@ func StartSubmissionConsumer(serviceKit *services.ServiceKit) {
 		log.Fatal("Topic does not exist")
 	}
 
-	messageC, errorC := serviceKit.KafkaService.Consume(topicName, groupID)
+	eventTopicName := viper.GetString("KAFKA_SUBMISSION_EVENT_TOPIC")
+	if eventTopicName == "" {
+		log.Fatal("KAFKA_SUBMISSION_EVENT_TOPIC is not set")
+	}
+
+	// the event topic is newer than the submission topic, so create it on first start
+	if !serviceKit.KafkaService.IsTopicExist(eventTopicName) {
+		err := serviceKit.KafkaService.CreateTopic(eventTopicName, 1)
+		if err != nil {
+			log.Fatal("Failed to create topic: ", err)
+		}
+	}
+
+	topics := readJobTopics(topicName, "KAFKA_SUBMISSION_RETRY_TOPIC", "KAFKA_SUBMISSION_DEAD_LETTER_TOPIC")
+	processor := newJobProcessor(serviceKit, topics, entities.JobTypeSubmission, func(ctx context.Context, job *entities.JobEnvelope) error {
+		return processSubmissionJob(ctx, serviceKit, job)
+	})
 
 	log.Println("Start consuming submission topic...")
 
-	for {
-		select {
-		case message := <-messageC:
-			log.Println("Receiving submission ID:", message)
-
-			// parse message as submissionID
-			submissionID, err := strconv.ParseUint(message, 10, 64)
-			if err != nil {
-				log.Println(err)
-				continue
-			}
-
-			// get submission
-			submission, err := serviceKit.SubmissionService.GetSubmissionByID(uint(submissionID))
-			if err != nil {
-				log.Println(err)
-				continue
-			}
-
-			// process submission
-			submission, err = serviceKit.SubmissionService.ProcessSubmission(submission)
-			if err != nil {
-				log.Println(err)
-				continue
-			}
-
-			log.Println("Submission processed:", submission.ID)
-		case err := <-errorC:
-			log.Println(err)
+	return consumeJobs(ctx, jobCtx, serviceKit, topics, groupID, entities.JobTypeSubmission, readWorkers("JUDGE_SUBMISSION_WORKERS"), processor)
+}
+
+// processSubmissionJob judges the submission of a job. A submission that is judged already
+// is skipped, one whose judge died or that failed is queued and judged again.
+// A submission whose judging is stopped by ctx is queued again.
+func processSubmissionJob(ctx context.Context, serviceKit *services.ServiceKit, job *entities.JobEnvelope) error {
+	submission, err := serviceKit.SubmissionService.GetSubmissionByID(job.ID)
+	if err != nil {
+		return err
+	}
+
+	switch submission.State {
+	case entities.SubmissionStateJudged:
+		log.Println("Submission judged already:", submission.ID)
+		return nil
+	case entities.SubmissionStateCompiling, entities.SubmissionStateRunning, entities.SubmissionStateFailed:
+		submission, err = serviceKit.SubmissionService.RequeueSubmission(submission)
+		if err != nil {
+			return err
 		}
 	}
+
+	// the result is saved before the message is committed
+	submission, err = serviceKit.SubmissionService.ProcessSubmission(ctx, submission)
+	if ctx.Err() != nil {
+		requeueSubmission(serviceKit, job.ID)
+		return ctx.Err()
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Println("Submission processed:", submission.ID)
+	return nil
 }
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"fmt"
 	"log"
 	"time"
 
@@ -55,10 +56,18 @@ func SetupAPI(serviceKit *services.ServiceKit, ratelimitStorage fiber.Storage) *
 		return c.SendString("Hello, World!")
 	})
 
+	// code runs per user per minute
+	codeRunRateLimit := viper.GetInt("CODE_RUN_RATE_LIMIT")
+	if codeRunRateLimit <= 0 {
+		codeRunRateLimit = 10
+	}
+
 	authHandler := NewAuthHandler(serviceKit)
 	userHandler := NewUserHandler(serviceKit)
 	challengeHandler := NewChallengeHandler(serviceKit)
 	submissionHandler := NewSubmissionHandler(serviceKit)
+	languageHandler := NewLanguageHandler(serviceKit)
+	deadLetterHandler := NewDeadLetterHandler(serviceKit)
 	// challengeTestcaseHandler := NewChallengeTestcaseHandler(serviceKit)
 
 	authGroup := app.Group("/auth")
@@ -87,11 +96,46 @@ func SetupAPI(serviceKit *services.ServiceKit, ratelimitStorage fiber.Storage) *
 	// testcaseGroup.Put("/update", challengeTestcaseHandler.UpdateTestcase)
 	// testcaseGroup.Delete("/delete/:id", challengeTestcaseHandler.DeleteTestcase)
 
+	app.Get("/languages", languageHandler.EnabledLanguages)
+
+	languageGroup := app.Group("/language")
+	languageGroup.Use(UserMiddleware(serviceKit))
+	languageGroup.Get("/all", languageHandler.AllLanguages)
+	languageGroup.Post("/create", languageHandler.CreateLanguage)
+	languageGroup.Put("/update/:id", languageHandler.UpdateLanguage)
+	languageGroup.Put("/enable/:id", languageHandler.EnableLanguage)
+	languageGroup.Put("/disable/:id", languageHandler.DisableLanguage)
+
+	deadLetterGroup := app.Group("/deadletter")
+	deadLetterGroup.Use(UserMiddleware(serviceKit))
+	deadLetterGroup.Get("/pagination", deadLetterHandler.Pagination)
+	deadLetterGroup.Get("/get/:id", deadLetterHandler.GetDeadLetterJobByID)
+	deadLetterGroup.Post("/replay/:id", deadLetterHandler.Replay)
+
+	// registered before the submission group so the token can come from the query,
+	// EventSource in browsers can not send the Authorization header
+	app.Get("/submission/stream/:id", QueryTokenMiddleware(), UserMiddleware(serviceKit), submissionHandler.StreamSubmission)
+
 	submissionGroup := app.Group("/submission")
 	submissionGroup.Use(UserMiddleware(serviceKit))
 	submissionGroup.Post("/submit", submissionHandler.SubmitSubmission)
 	submissionGroup.Get("/pagination", submissionHandler.Pagination)
 	submissionGroup.Get("/get/:id", submissionHandler.GetSubmissionByID)
+	submissionGroup.Post("/run", limiter.New(limiter.Config{
+		Max:        codeRunRateLimit,
+		Expiration: 60 * time.Second,
+		KeyGenerator: func(c *fiber.Ctx) string {
+			return fmt.Sprintf("code-run-%d", GetUserFromRequest(c).ID)
+		},
+		LimitReached: func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusTooManyRequests)
+		},
+		Storage: ratelimitStorage,
+	}), submissionHandler.RunCode)
+	submissionGroup.Get("/run/:id", submissionHandler.GetCodeRunByID)
+	submissionGroup.Post("/rejudge/:id", submissionHandler.RejudgeSubmission)
+	submissionGroup.Post("/rejudge/challenge/:id", submissionHandler.RejudgeChallenge)
+	submissionGroup.Post("/rejudge/testcase/:id", submissionHandler.RejudgeChallengeTestcase)
 	// submissionGroup.Get("/get/user", submissionHandler.GetSubmissionByUser)
 	// submissionGroup.Get("/get/challenge/:id", submissionHandler.GetSubmissionByChallenge)
 
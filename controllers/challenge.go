@@ -55,12 +55,19 @@ func (h *challengeHandler) CreateChallengeWithTestcase(c *fiber.Ctx) error {
 	}
 
 	// create challenge
-	challenge, err := h.serviceKit.ChallengeService.CreateChallenge(&entities.Challenge{
-		Name:        dto.Name,
-		Description: dto.Description,
-		UserID:      user.ID,
-		Testcases:   dto.GetTestcases(),
-	})
+	challenge := &entities.Challenge{
+		Name:            dto.Name,
+		Description:     dto.Description,
+		UserID:          user.ID,
+		Testcases:       dto.GetTestcases(),
+		Subtasks:        dto.GetSubtasks(),
+		LanguageLimits:  dto.GetLanguageLimits(),
+		SerialTestcases: dto.SerialTestcases,
+	}
+	dto.ApplyChecker(challenge)
+	dto.ApplyType(challenge)
+
+	challenge, err = h.serviceKit.ChallengeService.CreateChallenge(challenge)
 	if err != nil {
 		if strings.Contains(err.Error(), "testcase #") {
 			return c.Status(http.StatusBadRequest).JSON(CreateValidationError(err))
@@ -91,6 +98,11 @@ func (h *challengeHandler) UpdateChallenge(c *fiber.Ctx) error {
 	challenge.Name = dto.Name
 	challenge.Description = dto.Description
 	challenge.Testcases = dto.GetTestcases()
+	challenge.Subtasks = dto.GetSubtasks()
+	challenge.LanguageLimits = dto.GetLanguageLimits()
+	challenge.SerialTestcases = dto.SerialTestcases
+	dto.ApplyChecker(challenge)
+	dto.ApplyType(challenge)
 	err = h.serviceKit.ChallengeService.UpdateChallengeWithTestcase(challenge)
 	if err != nil {
 		if strings.Contains(err.Error(), "testcase #") {
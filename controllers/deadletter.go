@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/services"
+)
+
+type deadLetterHandler struct {
+	serviceKit *services.ServiceKit
+}
+
+func (h *deadLetterHandler) Pagination(c *fiber.Ctx) error {
+	user := GetUserFromRequest(c)
+	options := ParsePaginationOptions(c)
+
+	// only user with role admin can inspect dead letter jobs
+	if user.Role != entities.UserRoleAdmin {
+		return c.SendStatus(fiber.StatusForbidden)
+	}
+
+	result, err := h.serviceKit.DeadLetterService.Pagination(options)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
+	}
+
+	return c.Status(http.StatusOK).JSON(result)
+}
+
+func (h *deadLetterHandler) GetDeadLetterJobByID(c *fiber.Ctx) error {
+	user := GetUserFromRequest(c)
+	id := ParseIntParam(c, "id")
+
+	// only user with role admin can inspect dead letter jobs
+	if user.Role != entities.UserRoleAdmin {
+		return c.SendStatus(fiber.StatusForbidden)
+	}
+
+	job, err := h.serviceKit.DeadLetterService.GetDeadLetterJobByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
+	}
+
+	return c.Status(http.StatusOK).JSON(job)
+}
+
+func (h *deadLetterHandler) Replay(c *fiber.Ctx) error {
+	user := GetUserFromRequest(c)
+	id := ParseIntParam(c, "id")
+
+	// only user with role admin can replay dead letter jobs
+	if user.Role != entities.UserRoleAdmin {
+		return c.SendStatus(fiber.StatusForbidden)
+	}
+
+	job, err := h.serviceKit.DeadLetterService.GetDeadLetterJobByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
+	}
+
+	job, err = h.serviceKit.DeadLetterService.Replay(job)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
+	}
+
+	return c.Status(http.StatusOK).JSON(job)
+}
+
+func NewDeadLetterHandler(serviceKit *services.ServiceKit) *deadLetterHandler {
+	return &deadLetterHandler{
+		serviceKit: serviceKit,
+	}
+}
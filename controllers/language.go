@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/services"
+)
+
+type languageHandler struct {
+	serviceKit *services.ServiceKit
+}
+
+func (h *languageHandler) EnabledLanguages(c *fiber.Ctx) error {
+	languages, err := h.serviceKit.LanguageService.EnabledLanguages()
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
+	}
+
+	return c.Status(http.StatusOK).JSON(languages)
+}
+
+func (h *languageHandler) AllLanguages(c *fiber.Ctx) error {
+	user := GetUserFromRequest(c)
+
+	// only user with role admin can manage languages
+	if user.Role != entities.UserRoleAdmin {
+		return c.SendStatus(fiber.StatusForbidden)
+	}
+
+	languages, err := h.serviceKit.LanguageService.AllLanguages()
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
+	}
+
+	return c.Status(http.StatusOK).JSON(languages)
+}
+
+func (h *languageHandler) CreateLanguage(c *fiber.Ctx) error {
+	user := GetUserFromRequest(c)
+	dto := entities.ValidateLanguageCreateDTO(c)
+
+	// only user with role admin can manage languages
+	if user.Role != entities.UserRoleAdmin {
+		return c.SendStatus(fiber.StatusForbidden)
+	}
+
+	language, err := h.serviceKit.LanguageService.CreateLanguage(dto.ToLanguage())
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
+	}
+
+	return c.Status(http.StatusOK).JSON(language)
+}
+
+func (h *languageHandler) UpdateLanguage(c *fiber.Ctx) error {
+	user := GetUserFromRequest(c)
+	dto := entities.ValidateLanguageCreateDTO(c)
+	id := ParseIntParam(c, "id")
+
+	// only user with role admin can manage languages
+	if user.Role != entities.UserRoleAdmin {
+		return c.SendStatus(fiber.StatusForbidden)
+	}
+
+	language, err := h.serviceKit.LanguageService.GetLanguageByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
+	}
+
+	// keep the ID and whether the language is enabled
+	updated := dto.ToLanguage()
+	updated.ID = language.ID
+	updated.Enabled = language.Enabled
+
+	language, err = h.serviceKit.LanguageService.UpdateLanguage(updated)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
+	}
+
+	return c.Status(http.StatusOK).JSON(language)
+}
+
+func (h *languageHandler) EnableLanguage(c *fiber.Ctx) error {
+	return h.setLanguageEnabled(c, true)
+}
+
+func (h *languageHandler) DisableLanguage(c *fiber.Ctx) error {
+	return h.setLanguageEnabled(c, false)
+}
+
+func (h *languageHandler) setLanguageEnabled(c *fiber.Ctx, enabled bool) error {
+	user := GetUserFromRequest(c)
+	id := ParseIntParam(c, "id")
+
+	// only user with role admin can manage languages
+	if user.Role != entities.UserRoleAdmin {
+		return c.SendStatus(fiber.StatusForbidden)
+	}
+
+	language, err := h.serviceKit.LanguageService.GetLanguageByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
+	}
+
+	language, err = h.serviceKit.LanguageService.SetLanguageEnabled(language, enabled)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
+	}
+
+	return c.Status(http.StatusOK).JSON(language)
+}
+
+func NewLanguageHandler(serviceKit *services.ServiceKit) *languageHandler {
+	return &languageHandler{
+		serviceKit: serviceKit,
+	}
+}
@@ -1,12 +1,17 @@
 package controllers
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
-	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/spf13/viper"
 	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/repositories"
 	"github.com/wuttinanhi/code-judge-system/services"
 )
 
@@ -34,7 +39,10 @@ func (h *submissionHandler) SubmitSubmission(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
 	}
 
-	err = h.serviceKit.KafkaService.Produce(viper.GetString("KAFKA_SUBMISSION_PROCESS_TOPIC"), strconv.Itoa(int(submission.ID)))
+	err = h.serviceKit.KafkaService.ProduceJob(
+		viper.GetString("KAFKA_SUBMISSION_PROCESS_TOPIC"),
+		entities.NewJobEnvelope(entities.JobTypeSubmission, submission.ID),
+	)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(entities.HttpError{Message: "failed to add submission to queue"})
 	}
@@ -43,6 +51,7 @@ func (h *submissionHandler) SubmitSubmission(c *fiber.Ctx) error {
 }
 
 func (h *submissionHandler) GetSubmissionByID(c *fiber.Ctx) error {
+	user := GetUserFromRequest(c)
 	id := ParseIntParam(c, "id")
 
 	submission, err := h.serviceKit.SubmissionService.GetSubmissionByID(uint(id))
@@ -50,6 +59,8 @@ func (h *submissionHandler) GetSubmissionByID(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
 	}
 
+	h.serviceKit.SubmissionService.HideStderr(user, submission)
+
 	return c.Status(fiber.StatusOK).JSON(submission)
 }
 
@@ -61,10 +72,13 @@ func (h *submissionHandler) GetSubmissionByUser(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
 	}
 
+	h.serviceKit.SubmissionService.HideStderr(user, submissions...)
+
 	return c.Status(fiber.StatusOK).JSON(submissions)
 }
 
 func (h *submissionHandler) GetSubmissionByChallenge(c *fiber.Ctx) error {
+	user := GetUserFromRequest(c)
 	id := ParseIntParam(c, "id")
 
 	challenge, err := h.serviceKit.ChallengeService.FindChallengeByID(uint(id))
@@ -77,10 +91,13 @@ func (h *submissionHandler) GetSubmissionByChallenge(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
 	}
 
+	h.serviceKit.SubmissionService.HideStderr(user, submissions...)
+
 	return c.Status(fiber.StatusOK).JSON(submissions)
 }
 
 func (h *submissionHandler) Pagination(c *fiber.Ctx) error {
+	user := GetUserFromRequest(c)
 	options := ParsePaginationOptions(c)
 
 	userID := ParseIntQuery(c, "user_id")
@@ -95,9 +112,223 @@ func (h *submissionHandler) Pagination(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
 	}
 
+	h.serviceKit.SubmissionService.HideStderr(user, submission.Items...)
+
 	return c.Status(http.StatusOK).JSON(submission)
 }
 
+func (h *submissionHandler) RunCode(c *fiber.Ctx) error {
+	dto := entities.ValidateCodeRunCreateDTO(c)
+
+	user := GetUserFromRequest(c)
+
+	codeRun, err := h.serviceKit.CodeRunService.CreateCodeRun(&entities.CodeRun{
+		UserID:   user.ID,
+		Language: dto.Language,
+		Code:     dto.Code,
+		Stdin:    dto.Stdin,
+	})
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
+	}
+
+	err = h.serviceKit.KafkaService.ProduceJob(
+		viper.GetString("KAFKA_CODE_RUN_TOPIC"),
+		entities.NewJobEnvelope(entities.JobTypeCodeRun, codeRun.ID),
+	)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(entities.HttpError{Message: "failed to add code run to queue"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(codeRun)
+}
+
+func (h *submissionHandler) GetCodeRunByID(c *fiber.Ctx) error {
+	user := GetUserFromRequest(c)
+	id := ParseIntParam(c, "id")
+
+	codeRun, err := h.serviceKit.CodeRunService.GetCodeRunByID(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
+	}
+
+	// code runs are private to the user who started them
+	if codeRun.UserID != user.ID {
+		return c.SendStatus(fiber.StatusForbidden)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(codeRun)
+}
+
+// submissionStreamHeartbeat is how often an idle stream sends a comment
+// so proxies keep the connection open and closed clients are noticed.
+const submissionStreamHeartbeat = 15 * time.Second
+
+// StreamSubmission sends the progress of a submission as server-sent events.
+// The first event is the stored submission, then every event published by the
+// judge follows until the result event ends the stream.
+func (h *submissionHandler) StreamSubmission(c *fiber.Ctx) error {
+	id := ParseIntParam(c, "id")
+
+	// subscribe before loading the submission so no event in between is missed
+	events, unsubscribe := h.serviceKit.SubmissionEventService.Subscribe(uint(id))
+
+	submission, err := h.serviceKit.SubmissionService.GetSubmissionByID(uint(id))
+	if err != nil {
+		unsubscribe()
+		return c.Status(fiber.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
+	}
+	if submission.ID == 0 {
+		unsubscribe()
+		return c.Status(fiber.StatusNotFound).JSON(entities.HttpError{Message: "submission not found"})
+	}
+
+	snapshot := entities.NewSubmissionEvent(entities.SubmissionEventState, submission)
+	if submission.IsFinished() {
+		snapshot.Type = entities.SubmissionEventResult
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		err := writeSubmissionEvent(w, snapshot)
+		if err != nil || snapshot.IsFinal() {
+			return
+		}
+
+		heartbeat := time.NewTicker(submissionStreamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case event := <-events:
+				err = writeSubmissionEvent(w, event)
+				if err != nil || event.IsFinal() {
+					return
+				}
+			case <-heartbeat.C:
+				w.WriteString(": heartbeat\n\n")
+				if w.Flush() != nil {
+					return
+				}
+			}
+		}
+	})
+
+	return nil
+}
+
+// writeSubmissionEvent writes the event in the server-sent events format and flushes it.
+// A flush error means the client has gone away.
+func writeSubmissionEvent(w *bufio.Writer, event *entities.SubmissionEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return w.Flush()
+}
+
+func (h *submissionHandler) RejudgeSubmission(c *fiber.Ctx) error {
+	user := GetUserFromRequest(c)
+	dto := entities.ValidateSubmissionRejudgeDTO(c)
+	id := ParseIntParam(c, "id")
+
+	// only user with role admin or staff can rejudge submission
+	if user.Role != entities.UserRoleAdmin && user.Role != entities.UserRoleStaff {
+		return c.SendStatus(fiber.StatusForbidden)
+	}
+
+	submission, err := h.serviceKit.SubmissionService.GetSubmissionByID(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
+	}
+	if submission.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(entities.HttpError{Message: "submission not found"})
+	}
+
+	submission, err = h.serviceKit.SubmissionService.RejudgeSubmission(submission, user, dto.Reason)
+	if errors.Is(err, services.ErrSubmissionNotFinished) || errors.Is(err, repositories.ErrSubmissionStateChanged) {
+		return c.Status(fiber.StatusConflict).JSON(entities.HttpError{Message: err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
+	}
+
+	return h.enqueueRejudge(c, []*entities.Submission{submission}, dto.Reason)
+}
+
+func (h *submissionHandler) RejudgeChallenge(c *fiber.Ctx) error {
+	user := GetUserFromRequest(c)
+	dto := entities.ValidateSubmissionRejudgeDTO(c)
+	id := ParseIntParam(c, "id")
+
+	// only user with role admin or staff can rejudge submission
+	if user.Role != entities.UserRoleAdmin && user.Role != entities.UserRoleStaff {
+		return c.SendStatus(fiber.StatusForbidden)
+	}
+
+	challenge, err := h.serviceKit.ChallengeService.FindChallengeByID(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
+	}
+
+	submissions, err := h.serviceKit.SubmissionService.RejudgeChallenge(challenge, user, dto.Reason)
+	if err != nil {
+		// the submissions reset so far still need to be judged
+		h.enqueueRejudge(c, submissions, dto.Reason)
+		return c.Status(fiber.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
+	}
+
+	return h.enqueueRejudge(c, submissions, dto.Reason)
+}
+
+func (h *submissionHandler) RejudgeChallengeTestcase(c *fiber.Ctx) error {
+	user := GetUserFromRequest(c)
+	dto := entities.ValidateSubmissionRejudgeDTO(c)
+	id := ParseIntParam(c, "id")
+
+	// only user with role admin or staff can rejudge submission
+	if user.Role != entities.UserRoleAdmin && user.Role != entities.UserRoleStaff {
+		return c.SendStatus(fiber.StatusForbidden)
+	}
+
+	testcase, err := h.serviceKit.ChallengeService.FindTestcaseByID(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
+	}
+
+	submissions, err := h.serviceKit.SubmissionService.RejudgeChallengeTestcase(testcase, user, dto.Reason)
+	if err != nil {
+		// the submissions reset so far still need to be judged
+		h.enqueueRejudge(c, submissions, dto.Reason)
+		return c.Status(fiber.StatusBadRequest).JSON(entities.HttpError{Message: err.Error()})
+	}
+
+	return h.enqueueRejudge(c, submissions, dto.Reason)
+}
+
+// enqueueRejudge adds rejudged submissions back to the submission queue with the rejudge reason.
+func (h *submissionHandler) enqueueRejudge(c *fiber.Ctx, submissions []*entities.Submission, reason string) error {
+	for _, submission := range submissions {
+		err := h.serviceKit.KafkaService.ProduceJob(
+			viper.GetString("KAFKA_SUBMISSION_PROCESS_TOPIC"),
+			entities.NewRejudgeJobEnvelope(submission.ID, reason),
+		)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(entities.HttpError{Message: "failed to add submission to queue"})
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(entities.SubmissionRejudgeResponse{Total: len(submissions)})
+}
+
 func NewSubmissionHandler(serviceKit *services.ServiceKit) *submissionHandler {
 	return &submissionHandler{
 		serviceKit: serviceKit,
@@ -33,3 +33,16 @@ func UserMiddleware(serviceKit *services.ServiceKit) func(c *fiber.Ctx) error {
 		return c.Next()
 	}
 }
+
+// QueryTokenMiddleware accepts the access token from the "token" query parameter
+// for clients that can not set headers, such as the browser EventSource.
+func QueryTokenMiddleware() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		token := c.Query("token")
+		if c.Get("Authorization") == "" && token != "" {
+			c.Request().Header.Set("Authorization", "Bearer "+token)
+		}
+
+		return c.Next()
+	}
+}
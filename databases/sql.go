@@ -14,13 +14,29 @@ import (
 )
 
 func StartMigration(db *gorm.DB) error {
-	return db.AutoMigrate(
+	err := db.AutoMigrate(
 		&entities.ChallengeTestcase{},
+		&entities.ChallengeSubtask{},
+		&entities.ChallengeLanguageLimit{},
 		&entities.Challenge{},
 		&entities.SubmissionTestcase{},
+		&entities.SubmissionSubtaskResult{},
+		&entities.SubmissionVerdictHistory{},
 		&entities.Submission{},
 		&entities.User{},
+		&entities.CodeRun{},
+		&entities.Language{},
+		&entities.DeadLetterJob{},
 	)
+	if err != nil {
+		return err
+	}
+
+	// submissions judged before lifecycle states existed get the QUEUED default,
+	// only a pending verdict can still be queued
+	return db.Model(&entities.Submission{}).
+		Where("state = ? AND status <> ?", entities.SubmissionStateQueued, entities.SubmissionStatusPending).
+		Update("state", entities.SubmissionStateJudged).Error
 }
 
 func NewSQLiteDatabase() *gorm.DB {
@@ -2,14 +2,80 @@ package entities
 
 import "github.com/gofiber/fiber/v2"
 
+const (
+	// ChallengeCheckerExact compares output byte-for-byte.
+	ChallengeCheckerExact = "exact"
+	// ChallengeCheckerToken compares whitespace separated tokens.
+	ChallengeCheckerToken = "token"
+	// ChallengeCheckerLine compares line-by-line ignoring trailing spaces and trailing empty lines.
+	ChallengeCheckerLine = "line"
+	// ChallengeCheckerFloat compares tokens as floating point numbers within an epsilon.
+	ChallengeCheckerFloat = "float"
+	// ChallengeCheckerCustom runs a staff supplied testlib-style checker program.
+	ChallengeCheckerCustom = "custom"
+)
+
+const (
+	// ChallengeTypeStandard feeds the testcase input through stdin and checks stdout.
+	ChallengeTypeStandard = "standard"
+	// ChallengeTypeInteractive pipes the program to a staff supplied interactor
+	// and lets the interactor's exit code decide the verdict.
+	ChallengeTypeInteractive = "interactive"
+)
+
+// ChallengeCheckerDefaultEpsilon is used by the float checker when no epsilon is configured.
+const ChallengeCheckerDefaultEpsilon = 1e-6
+
 type Challenge struct {
-	ID          uint                 `json:"challenge_id" gorm:"primaryKey"`
-	Name        string               `json:"name"`
-	Description string               `json:"description"`
-	UserID      uint                 `json:"user_id"`
-	User        *User                `json:"user" gorm:"foreignKey:UserID"`
-	Testcases   []*ChallengeTestcase `json:"testcases" gorm:"foreignKey:ChallengeID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE"`
-	Submission  []*Submission        `json:"submission" gorm:"foreignKey:ChallengeID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE"`
+	ID                 uint                      `json:"challenge_id" gorm:"primaryKey"`
+	Name               string                    `json:"name"`
+	Description        string                    `json:"description"`
+	Type               string                    `json:"type" gorm:"default:standard"`
+	InteractorLanguage string                    `json:"interactor_language"`
+	InteractorCode     string                    `json:"interactor_code"`
+	Checker            string                    `json:"checker" gorm:"default:exact"`
+	CheckerAbsEpsilon  float64                   `json:"checker_abs_epsilon"`
+	CheckerRelEpsilon  float64                   `json:"checker_rel_epsilon"`
+	CheckerLanguage    string                    `json:"checker_language"`
+	CheckerCode        string                    `json:"checker_code"`
+	SerialTestcases    bool                      `json:"serial_testcases"`
+	UserID             uint                      `json:"user_id"`
+	User               *User                     `json:"user" gorm:"foreignKey:UserID"`
+	Testcases          []*ChallengeTestcase      `json:"testcases" gorm:"foreignKey:ChallengeID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE"`
+	Subtasks           []*ChallengeSubtask       `json:"subtasks" gorm:"foreignKey:ChallengeID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE"`
+	LanguageLimits     []*ChallengeLanguageLimit `json:"language_limits" gorm:"foreignKey:ChallengeID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE"`
+	Submission         []*Submission             `json:"submission" gorm:"foreignKey:ChallengeID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE"`
+}
+
+// ApplyLanguageLimit sets the multipliers of the sandbox to the override of its language
+// on this challenge, keeping the multipliers of the language where none is set.
+func (c *Challenge) ApplyLanguageLimit(sandbox *SandboxInstance) {
+	for _, limit := range c.LanguageLimits {
+		if limit.Language != sandbox.Language {
+			continue
+		}
+		if limit.TimeMultiplier > 0 {
+			sandbox.TimeMultiplier = limit.TimeMultiplier
+		}
+		if limit.MemoryMultiplier > 0 {
+			sandbox.MemoryMultiplier = limit.MemoryMultiplier
+		}
+		return
+	}
+}
+
+// MaxScore returns the sum of the subtask points, or the default score when
+// the challenge has no subtasks.
+func (c *Challenge) MaxScore() float64 {
+	if len(c.Subtasks) == 0 {
+		return ChallengeDefaultMaxScore
+	}
+
+	var total float64
+	for _, subtask := range c.Subtasks {
+		total += subtask.Points
+	}
+	return total
 }
 
 type ChallengeExtended struct {
@@ -30,9 +96,20 @@ type ChallengePaginationOptions struct {
 // }
 
 type ChallengeCreateWithTestcaseDTO struct {
-	Name        string                 `json:"name" validate:"required,min=3,max=255"`
-	Description string                 `json:"description" validate:"max=3000"`
-	Testcases   []ChallengeTestcaseDTO `json:"testcases" validate:"required"`
+	Name               string                      `json:"name" validate:"required,min=3,max=255"`
+	Description        string                      `json:"description" validate:"max=3000"`
+	Type               string                      `json:"type" validate:"omitempty,oneof=standard interactive"`
+	InteractorLanguage string                      `json:"interactor_language" validate:"required_if=Type interactive"`
+	InteractorCode     string                      `json:"interactor_code" validate:"required_if=Type interactive,max=65536"`
+	Checker            string                      `json:"checker" validate:"omitempty,oneof=exact token line float custom"`
+	CheckerAbsEpsilon  float64                     `json:"checker_abs_epsilon" validate:"min=0"`
+	CheckerRelEpsilon  float64                     `json:"checker_rel_epsilon" validate:"min=0"`
+	CheckerLanguage    string                      `json:"checker_language" validate:"required_if=Checker custom"`
+	CheckerCode        string                      `json:"checker_code" validate:"required_if=Checker custom,max=65536"`
+	SerialTestcases    bool                        `json:"serial_testcases"`
+	Testcases          []ChallengeTestcaseDTO      `json:"testcases" validate:"required"`
+	Subtasks           []ChallengeSubtaskDTO       `json:"subtasks" validate:"dive"`
+	LanguageLimits     []ChallengeLanguageLimitDTO `json:"language_limits" validate:"dive"`
 }
 
 func ValidateChallengeCreateWithTestcaseDTO(c *fiber.Ctx) ChallengeCreateWithTestcaseDTO {
@@ -57,10 +134,37 @@ func (c *ChallengeCreateWithTestcaseDTO) GetTestcases() []*ChallengeTestcase {
 	return testcases
 }
 
+func (c *ChallengeCreateWithTestcaseDTO) GetSubtasks() []*ChallengeSubtask {
+	subtasks := make([]*ChallengeSubtask, 0, len(c.Subtasks))
+	for _, subtask := range c.Subtasks {
+		subtasks = append(subtasks, subtask.ToSubtask())
+	}
+	return subtasks
+}
+
+func (c *ChallengeCreateWithTestcaseDTO) GetLanguageLimits() []*ChallengeLanguageLimit {
+	limits := make([]*ChallengeLanguageLimit, 0, len(c.LanguageLimits))
+	for _, limit := range c.LanguageLimits {
+		limits = append(limits, limit.ToLanguageLimit())
+	}
+	return limits
+}
+
 type ChallengeUpdateDTO struct {
-	Name        string                 `json:"name" validate:"required,min=3,max=255"`
-	Description string                 `json:"description" validate:"max=3000"`
-	Testcases   []ChallengeTestcaseDTO `json:"testcases" validate:"required"`
+	Name               string                      `json:"name" validate:"required,min=3,max=255"`
+	Description        string                      `json:"description" validate:"max=3000"`
+	Type               string                      `json:"type" validate:"omitempty,oneof=standard interactive"`
+	InteractorLanguage string                      `json:"interactor_language" validate:"required_if=Type interactive"`
+	InteractorCode     string                      `json:"interactor_code" validate:"required_if=Type interactive,max=65536"`
+	Checker            string                      `json:"checker" validate:"omitempty,oneof=exact token line float custom"`
+	CheckerAbsEpsilon  float64                     `json:"checker_abs_epsilon" validate:"min=0"`
+	CheckerRelEpsilon  float64                     `json:"checker_rel_epsilon" validate:"min=0"`
+	CheckerLanguage    string                      `json:"checker_language" validate:"required_if=Checker custom"`
+	CheckerCode        string                      `json:"checker_code" validate:"required_if=Checker custom,max=65536"`
+	SerialTestcases    bool                        `json:"serial_testcases"`
+	Testcases          []ChallengeTestcaseDTO      `json:"testcases" validate:"required"`
+	Subtasks           []ChallengeSubtaskDTO       `json:"subtasks" validate:"dive"`
+	LanguageLimits     []ChallengeLanguageLimitDTO `json:"language_limits" validate:"dive"`
 }
 
 func ValidateChallengeUpdateDTO(c *fiber.Ctx) ChallengeUpdateDTO {
@@ -84,3 +188,59 @@ func (c *ChallengeUpdateDTO) GetTestcases() []*ChallengeTestcase {
 	}
 	return testcases
 }
+
+func (c *ChallengeUpdateDTO) GetSubtasks() []*ChallengeSubtask {
+	subtasks := make([]*ChallengeSubtask, 0, len(c.Subtasks))
+	for _, subtask := range c.Subtasks {
+		subtasks = append(subtasks, subtask.ToSubtask())
+	}
+	return subtasks
+}
+
+func (c *ChallengeUpdateDTO) GetLanguageLimits() []*ChallengeLanguageLimit {
+	limits := make([]*ChallengeLanguageLimit, 0, len(c.LanguageLimits))
+	for _, limit := range c.LanguageLimits {
+		limits = append(limits, limit.ToLanguageLimit())
+	}
+	return limits
+}
+
+// ApplyChecker copies the checker settings from the DTO to the challenge.
+func (c *ChallengeCreateWithTestcaseDTO) ApplyChecker(challenge *Challenge) {
+	applyChecker(challenge, c.Checker, c.CheckerAbsEpsilon, c.CheckerRelEpsilon, c.CheckerLanguage, c.CheckerCode)
+}
+
+// ApplyChecker copies the checker settings from the DTO to the challenge.
+func (c *ChallengeUpdateDTO) ApplyChecker(challenge *Challenge) {
+	applyChecker(challenge, c.Checker, c.CheckerAbsEpsilon, c.CheckerRelEpsilon, c.CheckerLanguage, c.CheckerCode)
+}
+
+func applyChecker(challenge *Challenge, checker string, absEpsilon, relEpsilon float64, language, code string) {
+	if checker == "" {
+		checker = ChallengeCheckerExact
+	}
+	challenge.Checker = checker
+	challenge.CheckerAbsEpsilon = absEpsilon
+	challenge.CheckerRelEpsilon = relEpsilon
+	challenge.CheckerLanguage = language
+	challenge.CheckerCode = code
+}
+
+// ApplyType copies the challenge type and interactor settings from the DTO to the challenge.
+func (c *ChallengeCreateWithTestcaseDTO) ApplyType(challenge *Challenge) {
+	applyType(challenge, c.Type, c.InteractorLanguage, c.InteractorCode)
+}
+
+// ApplyType copies the challenge type and interactor settings from the DTO to the challenge.
+func (c *ChallengeUpdateDTO) ApplyType(challenge *Challenge) {
+	applyType(challenge, c.Type, c.InteractorLanguage, c.InteractorCode)
+}
+
+func applyType(challenge *Challenge, challengeType, language, code string) {
+	if challengeType == "" {
+		challengeType = ChallengeTypeStandard
+	}
+	challenge.Type = challengeType
+	challenge.InteractorLanguage = language
+	challenge.InteractorCode = code
+}
@@ -0,0 +1,26 @@
+package entities
+
+// ChallengeLanguageLimit overrides the time and memory multipliers of a language for one challenge.
+// A zero multiplier keeps the multiplier of the language.
+type ChallengeLanguageLimit struct {
+	ID               uint       `json:"challenge_language_limit_id" gorm:"primaryKey"`
+	Language         string     `json:"language"`
+	TimeMultiplier   float64    `json:"time_multiplier"`
+	MemoryMultiplier float64    `json:"memory_multiplier"`
+	ChallengeID      uint       `json:"challenge_id"`
+	Challenge        *Challenge `json:"challenge"`
+}
+
+type ChallengeLanguageLimitDTO struct {
+	Language         string  `json:"language" validate:"required,max=32"`
+	TimeMultiplier   float64 `json:"time_multiplier" validate:"min=0,max=10"`
+	MemoryMultiplier float64 `json:"memory_multiplier" validate:"min=0,max=10"`
+}
+
+func (t *ChallengeLanguageLimitDTO) ToLanguageLimit() *ChallengeLanguageLimit {
+	return &ChallengeLanguageLimit{
+		Language:         t.Language,
+		TimeMultiplier:   t.TimeMultiplier,
+		MemoryMultiplier: t.MemoryMultiplier,
+	}
+}
@@ -0,0 +1,42 @@
+package entities
+
+const (
+	// ChallengeSubtaskScoringAll awards the subtask points only when every testcase passes.
+	ChallengeSubtaskScoringAll = "all"
+	// ChallengeSubtaskScoringProportional awards points proportional to the passed testcases.
+	ChallengeSubtaskScoringProportional = "proportional"
+)
+
+// ChallengeDefaultMaxScore is the score of a challenge without subtasks.
+const ChallengeDefaultMaxScore float64 = 100
+
+type ChallengeSubtask struct {
+	ID          uint       `json:"subtask_id" gorm:"primaryKey"`
+	Number      uint       `json:"number"`
+	Name        string     `json:"name"`
+	Points      float64    `json:"points"`
+	ScoringRule string     `json:"scoring_rule" gorm:"default:all"`
+	ChallengeID uint       `json:"challenge_id"`
+	Challenge   *Challenge `json:"challenge"`
+}
+
+type ChallengeSubtaskDTO struct {
+	Number      uint    `json:"number" validate:"required,min=1"`
+	Name        string  `json:"name" validate:"max=255"`
+	Points      float64 `json:"points" validate:"min=0"`
+	ScoringRule string  `json:"scoring_rule" validate:"omitempty,oneof=all proportional"`
+}
+
+func (t *ChallengeSubtaskDTO) ToSubtask() *ChallengeSubtask {
+	scoringRule := t.ScoringRule
+	if scoringRule == "" {
+		scoringRule = ChallengeSubtaskScoringAll
+	}
+
+	return &ChallengeSubtask{
+		Number:      t.Number,
+		Name:        t.Name,
+		Points:      t.Points,
+		ScoringRule: scoringRule,
+	}
+}
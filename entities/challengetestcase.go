@@ -6,6 +6,7 @@ type ChallengeTestcase struct {
 	ExpectedOutput      string                `json:"expected_output"`
 	LimitMemory         uint                  `json:"limit_memory"`
 	LimitTimeMs         uint                  `json:"limit_time_ms"`
+	Subtask             uint                  `json:"subtask"`
 	SubmissionTestcases []*SubmissionTestcase `json:"submission_testcases"`
 	ChallengeID         uint                  `json:"challenge_id"`
 	Challenge           *Challenge            `json:"challenge"`
@@ -67,6 +68,7 @@ type ChallengeTestcaseDTO struct {
 	ExpectedOutput string `json:"expected_output" validate:"required,max=1024"`
 	LimitMemory    uint   `json:"limit_memory" validate:"required"`
 	LimitTimeMs    uint   `json:"limit_time_ms" validate:"required"`
+	Subtask        uint   `json:"subtask"`
 	Action         string `json:"action" validate:"required,oneof=create update delete"`
 }
 
@@ -77,6 +79,7 @@ func (t *ChallengeTestcaseDTO) ToTestcase() *ChallengeTestcase {
 		ExpectedOutput: t.ExpectedOutput,
 		LimitMemory:    t.LimitMemory,
 		LimitTimeMs:    t.LimitTimeMs,
+		Subtask:        t.Subtask,
 		ActionFlag:     t.Action,
 	}
 }
@@ -0,0 +1,60 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CodeRunStatusCompleted marks a code run that finished within its limits.
+// Failed runs use the matching submission verdict instead.
+const CodeRunStatusCompleted = "COMPLETED"
+
+const (
+	// CodeRunMemoryLimit is the memory limit of a custom input run.
+	CodeRunMemoryLimit = 256 * SandboxMemoryMB
+	// CodeRunTimeLimitMs is the time limit of a custom input run.
+	CodeRunTimeLimitMs uint = 2000
+	// CodeRunOutputLimit is the maximum number of bytes of stdout and stderr kept on a code run.
+	CodeRunOutputLimit = 64 * 1024
+)
+
+// CodeRun is a run of user code against custom stdin. It is never judged
+// and does not count as a submission.
+type CodeRun struct {
+	ID            uint      `json:"code_run_id" gorm:"primaryKey"`
+	Language      string    `json:"language"`
+	Code          string    `json:"code"`
+	Stdin         string    `json:"stdin"`
+	Status        string    `json:"status" gorm:"default:PENDING"`
+	Stdout        string    `json:"stdout"`
+	Stderr        string    `json:"stderr"`
+	ExitCode      int       `json:"exit_code"`
+	TimeMs        uint      `json:"time_ms"`
+	MemoryUsage   uint      `json:"memory_usage"`
+	CompileStdout string    `json:"compile_stdout"`
+	CompileStderr string    `json:"compile_stderr"`
+	UserID        uint      `json:"user_id"`
+	User          *User     `json:"user"`
+	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+type CodeRunCreateDTO struct {
+	Language string `json:"language" validate:"required"`
+	Code     string `json:"code" validate:"required,max=65536"`
+	Stdin    string `json:"stdin" validate:"max=65536"`
+}
+
+func ValidateCodeRunCreateDTO(c *fiber.Ctx) CodeRunCreateDTO {
+	var dto CodeRunCreateDTO
+
+	if err := c.BodyParser(&dto); err != nil {
+		panic(err)
+	}
+
+	if err := validate.Struct(&dto); err != nil {
+		panic(err)
+	}
+
+	return dto
+}
@@ -0,0 +1,18 @@
+package entities
+
+import "time"
+
+// DeadLetterJob is a job that was given up on, either because its message could not be
+// parsed or because every attempt failed. Message is kept as it was last produced so the
+// job can be inspected and replayed to Topic.
+type DeadLetterJob struct {
+	ID         uint       `json:"dead_letter_job_id" gorm:"primaryKey"`
+	Topic      string     `json:"topic"`
+	JobType    string     `json:"job_type"`
+	Message    string     `json:"message"`
+	Error      string     `json:"error"`
+	TraceID    string     `json:"trace_id"`
+	Attempt    int        `json:"attempt"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	ReplayedAt *time.Time `json:"replayed_at"`
+}
@@ -0,0 +1,125 @@
+package entities
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JobEnvelopeVersion is the version of the job envelope schema produced by this build.
+// Consumers reject envelopes of a newer version they cannot read.
+const JobEnvelopeVersion = 1
+
+// Job types carried on the job topics.
+const (
+	JobTypeSubmission = "submission"
+	JobTypeCodeRun    = "code_run"
+)
+
+// Job priorities, recorded on the job so rejudges can be told apart from new submissions.
+// Jobs are dispatched in the order they are consumed whatever their priority.
+const (
+	JobPriorityNormal = 0
+	JobPriorityLow    = -1
+)
+
+// JobEnvelope is a message on a job topic. ID is the ID of the submission or code run
+// of the job type. Attempt starts at 1 and TraceID follows the job through the logs.
+// Retried jobs wait until RetryAt and carry the error of the previous attempt.
+type JobEnvelope struct {
+	Version   int        `json:"version"`
+	Type      string     `json:"type"`
+	ID        uint       `json:"id"`
+	Priority  int        `json:"priority"`
+	Attempt   int        `json:"attempt"`
+	TraceID   string     `json:"trace_id"`
+	Reason    string     `json:"reason,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RetryAt   *time.Time `json:"retry_at,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// NewJobEnvelope creates the first attempt of a job of jobType with a new trace ID.
+func NewJobEnvelope(jobType string, id uint) *JobEnvelope {
+	return &JobEnvelope{
+		Version:   JobEnvelopeVersion,
+		Type:      jobType,
+		ID:        id,
+		Priority:  JobPriorityNormal,
+		Attempt:   1,
+		TraceID:   newTraceID(),
+		CreatedAt: time.Now(),
+	}
+}
+
+// NewRejudgeJobEnvelope creates a submission job for a rejudge with its reason, marked low priority.
+func NewRejudgeJobEnvelope(submissionID uint, reason string) *JobEnvelope {
+	job := NewJobEnvelope(JobTypeSubmission, submissionID)
+	job.Priority = JobPriorityLow
+	job.Reason = reason
+	return job
+}
+
+func newTraceID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Encode returns the envelope as the value of a message.
+func (j *JobEnvelope) Encode() (string, error) {
+	b, err := json.Marshal(j)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// NextAttempt prepares the job to be tried again after it failed with cause.
+// The backoff doubles with every failed attempt.
+func (j *JobEnvelope) NextAttempt(cause error, backoff time.Duration, now time.Time) {
+	retryAt := now.Add(backoff << (j.Attempt - 1))
+	j.RetryAt = &retryAt
+	j.LastError = cause.Error()
+	j.Attempt++
+}
+
+// ParseJobEnvelope decodes a message of a topic carrying jobs of jobType.
+// A bare numeric ID, the message format before the envelope, is read as a version 0
+// envelope of jobType so messages produced by older builds are still judged.
+func ParseJobEnvelope(message string, jobType string) (*JobEnvelope, error) {
+	message = strings.TrimSpace(message)
+
+	if id, err := strconv.ParseUint(message, 10, 64); err == nil {
+		if id == 0 {
+			return nil, errors.New("job: invalid id 0")
+		}
+		return &JobEnvelope{Type: jobType, ID: uint(id), Attempt: 1}, nil
+	}
+
+	var job JobEnvelope
+	err := json.Unmarshal([]byte(message), &job)
+	if err != nil {
+		return nil, fmt.Errorf("job: invalid envelope: %w", err)
+	}
+
+	if job.Version < 1 || job.Version > JobEnvelopeVersion {
+		return nil, fmt.Errorf("job: unsupported envelope version %d", job.Version)
+	}
+	if job.Type != jobType {
+		return nil, fmt.Errorf("job: expected type %s, got %q", jobType, job.Type)
+	}
+	if job.ID == 0 {
+		return nil, errors.New("job: invalid id 0")
+	}
+	if job.Attempt < 1 {
+		job.Attempt = 1
+	}
+
+	return &job, nil
+}
@@ -0,0 +1,137 @@
+package entities
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// LanguageDefaultSourceFile is the file name the code is written to when a language does not set one.
+const LanguageDefaultSourceFile = "code"
+
+// Language is a programming language programs can be written in.
+// Languages are stored in the database so they can be added or changed without a deploy.
+type Language struct {
+	ID               uint    `json:"language_id" gorm:"primaryKey"`
+	Language         string  `json:"language" gorm:"uniqueIndex;size:32"`
+	DisplayName      string  `json:"display_name"`
+	DockerImage      string  `json:"docker_image"`
+	SourceFile       string  `json:"source_file"`
+	CompileCmd       string  `json:"compile_cmd"`
+	RunCmd           string  `json:"run_cmd"`
+	CompileTimeout   uint    `json:"compile_timeout"`
+	TimeMultiplier   float64 `json:"time_multiplier" gorm:"default:1"`
+	MemoryMultiplier float64 `json:"memory_multiplier" gorm:"default:1"`
+	Enabled          bool    `json:"enabled"`
+}
+
+// ToInstruction returns the sandbox instruction to build and run programs of the language.
+func (l *Language) ToInstruction() *SandboxInstruction {
+	sourceFile := l.SourceFile
+	if sourceFile == "" {
+		sourceFile = LanguageDefaultSourceFile
+	}
+
+	return &SandboxInstruction{
+		Language:         l.Language,
+		DisplayName:      l.DisplayName,
+		DockerImage:      l.DockerImage,
+		SourceFile:       sourceFile,
+		CompileCmd:       l.CompileCmd,
+		RunCmd:           l.RunCmd,
+		CompileTimeout:   l.CompileTimeout,
+		TimeMultiplier:   l.TimeMultiplier,
+		MemoryMultiplier: l.MemoryMultiplier,
+	}
+}
+
+// NewLanguageFromInstruction creates an enabled language from a built-in instruction.
+func NewLanguageFromInstruction(instruction SandboxInstruction) *Language {
+	return &Language{
+		Language:         instruction.Language,
+		DisplayName:      instruction.DisplayName,
+		DockerImage:      instruction.DockerImage,
+		SourceFile:       instruction.SourceFile,
+		CompileCmd:       instruction.CompileCmd,
+		RunCmd:           instruction.RunCmd,
+		CompileTimeout:   instruction.CompileTimeout,
+		TimeMultiplier:   instruction.TimeMultiplier,
+		MemoryMultiplier: instruction.MemoryMultiplier,
+		Enabled:          true,
+	}
+}
+
+type LanguageCreateDTO struct {
+	Language         string  `json:"language" validate:"required,max=32,alphanum"`
+	DisplayName      string  `json:"display_name" validate:"required,max=64"`
+	DockerImage      string  `json:"docker_image" validate:"required,max=255"`
+	SourceFile       string  `json:"source_file" validate:"max=64"`
+	CompileCmd       string  `json:"compile_cmd"`
+	RunCmd           string  `json:"run_cmd" validate:"required"`
+	CompileTimeout   uint    `json:"compile_timeout" validate:"required"`
+	TimeMultiplier   float64 `json:"time_multiplier" validate:"omitempty,gt=0,lte=10"`
+	MemoryMultiplier float64 `json:"memory_multiplier" validate:"omitempty,gt=0,lte=10"`
+}
+
+// ToLanguage creates an enabled language from the DTO.
+func (dto *LanguageCreateDTO) ToLanguage() *Language {
+	language := &Language{
+		Language:         dto.Language,
+		DisplayName:      dto.DisplayName,
+		DockerImage:      dto.DockerImage,
+		SourceFile:       dto.SourceFile,
+		CompileCmd:       dto.CompileCmd,
+		RunCmd:           dto.RunCmd,
+		CompileTimeout:   dto.CompileTimeout,
+		TimeMultiplier:   dto.TimeMultiplier,
+		MemoryMultiplier: dto.MemoryMultiplier,
+		Enabled:          true,
+	}
+	if language.TimeMultiplier == 0 {
+		language.TimeMultiplier = 1
+	}
+	if language.MemoryMultiplier == 0 {
+		language.MemoryMultiplier = 1
+	}
+	return language
+}
+
+func ValidateLanguageCreateDTO(c *fiber.Ctx) LanguageCreateDTO {
+	var dto LanguageCreateDTO
+
+	if err := c.BodyParser(&dto); err != nil {
+		panic(err)
+	}
+
+	if err := validate.Struct(&dto); err != nil {
+		panic(err)
+	}
+
+	return dto
+}
+
+// LoadLanguageFile reads a JSON array of language definitions in the format of LanguageCreateDTO.
+func LoadLanguageFile(path string) ([]*Language, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var dtos []LanguageCreateDTO
+	err = json.Unmarshal(data, &dtos)
+	if err != nil {
+		return nil, err
+	}
+
+	languages := make([]*Language, len(dtos))
+	for i := range dtos {
+		err = validate.Struct(&dtos[i])
+		if err != nil {
+			return nil, err
+		}
+		languages[i] = dtos[i].ToLanguage()
+	}
+
+	return languages, nil
+}
@@ -1,6 +1,11 @@
 package entities
 
 import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
 	"github.com/docker/docker/api/types/volume"
 )
 
@@ -9,8 +14,137 @@ const (
 	SandboxMemoryGB uint = 1024 * SandboxMemoryMB
 )
 
+// SandboxDefaultOutputLimitKB is the output limit of a run when none is configured.
+const SandboxDefaultOutputLimitKB = 16 * 1024
+
+// SandboxCompileOutputLimit is the maximum number of bytes of compiler output kept on a submission.
+const SandboxCompileOutputLimit = 16 * 1024
+
+const (
+	// SandboxCheckerMemoryLimit is the memory limit of a custom checker run.
+	SandboxCheckerMemoryLimit = 256 * SandboxMemoryMB
+	// SandboxCheckerTimeLimitMs is the time limit of a custom checker run.
+	SandboxCheckerTimeLimitMs uint = 5000
+	// SandboxInteractorTimeGraceMs is added to the testcase time limit for the interactor
+	// so it can still report after the program used its full time.
+	SandboxInteractorTimeGraceMs uint = 1000
+)
+
+const (
+	// SandboxBackendDocker runs programs in Docker containers.
+	SandboxBackendDocker = "docker"
+	// SandboxBackendNsjail runs programs with nsjail on the judge host.
+	SandboxBackendNsjail = "nsjail"
+)
+
+// SandboxUser is the unprivileged user and group compile and run containers run as.
+// The numeric ID is used so it works in images without a passwd entry for it.
+const SandboxUser = "65534:65534"
+
+const (
+	// SandboxLabelRunID is the label of sandbox containers and volumes with the run ID of their sandbox.
+	SandboxLabelRunID = "code-judge-system.run-id"
+	// SandboxLabelSubmissionID is the label with the ID of the submission judged in the sandbox.
+	SandboxLabelSubmissionID = "code-judge-system.submission-id"
+	// SandboxLabelCreatedAt is the label with the RFC 3339 creation time. Every sandbox
+	// container and volume has it, so leftovers of dead judges can be found by it.
+	SandboxLabelCreatedAt = "code-judge-system.created-at"
+	// SandboxLabelPool is the label of pooled containers, which live as long as their pool
+	// and are removed by it, so they are never taken as left behind.
+	SandboxLabelPool = "code-judge-system.pool"
+)
+
+// SandboxLabels returns the labels of a sandbox container or volume created at createdAt.
+// Pooled containers serve many runs and have no run ID, code runs have no submission ID.
+func SandboxLabels(runID string, submissionID uint, createdAt time.Time) map[string]string {
+	labels := map[string]string{
+		SandboxLabelCreatedAt: createdAt.UTC().Format(time.RFC3339),
+	}
+	if runID != "" {
+		labels[SandboxLabelRunID] = runID
+	}
+	if submissionID != 0 {
+		labels[SandboxLabelSubmissionID] = strconv.FormatUint(uint64(submissionID), 10)
+	}
+	return labels
+}
+
+// SandboxNanoCPUs limits compile and run containers to a single core.
+const SandboxNanoCPUs int64 = 1_000_000_000
+
+// SandboxContainerLimits bound what a program can do in its container besides memory and time.
+type SandboxContainerLimits struct {
+	// PidsLimit is the maximum number of processes and threads.
+	PidsLimit int64
+	// TmpfsSize is the size of /tmp in bytes, the only writable path outside the mounted volumes.
+	TmpfsSize int64
+	// FileSizeLimit is the maximum size of a single written file in bytes.
+	FileSizeLimit int64
+	// OpenFilesLimit is the maximum number of open file descriptors.
+	OpenFilesLimit int64
+}
+
+// SandboxCompileLimits apply to compile containers, compilers need more room than programs.
+var SandboxCompileLimits = SandboxContainerLimits{
+	PidsLimit:      256,
+	TmpfsSize:      int64(512 * SandboxMemoryMB),
+	FileSizeLimit:  int64(256 * SandboxMemoryMB),
+	OpenFilesLimit: 1024,
+}
+
+// SandboxRunLimits apply to program, checker and interactor containers.
+var SandboxRunLimits = SandboxContainerLimits{
+	PidsLimit:      64,
+	TmpfsSize:      int64(64 * SandboxMemoryMB),
+	FileSizeLimit:  int64(64 * SandboxMemoryMB),
+	OpenFilesLimit: 256,
+}
+
+const sandboxTruncatedMarker = "\n... (output truncated)"
+
+// SandboxUsageMarker prefixes the line the run wrapper writes to stderr with the
+// CPU time in microseconds, the peak memory in bytes and the number of OOM kills
+// read from the container cgroup.
+const SandboxUsageMarker = "__CODE_JUDGE_SYSTEM_USAGE__"
+
+// SandboxUsageStartScript reads the CPU time and OOM kill counters of the container cgroup
+// before the program starts, so a container that runs several programs reports each one.
+// cgroup v2 is preferred; cgroup v1 is used as a fallback.
+const SandboxUsageStartScript = `if [ -f /sys/fs/cgroup/cpu.stat ]; then ` +
+	`while read key value; do [ "$key" = usage_usec ] && cpu0=$value; done < /sys/fs/cgroup/cpu.stat; ` +
+	`while read key value; do [ "$key" = oom_kill ] && oom0=$value; done < /sys/fs/cgroup/memory.events; ` +
+	`else ` +
+	`cpu0=$(($(cat /sys/fs/cgroup/cpuacct/cpuacct.usage 2>/dev/null || echo 0) / 1000)); ` +
+	`while read key value; do [ "$key" = oom_kill ] && oom0=$value; done 2>/dev/null < /sys/fs/cgroup/memory/memory.oom_control; ` +
+	`fi`
+
+// SandboxUsageScript reads the container cgroup after the program exits and reports the
+// counters relative to SandboxUsageStartScript. The peak memory is that of the container,
+// so it only belongs to the program in a container that ran nothing else.
+// cgroup v2 is preferred; cgroup v1 is used as a fallback.
+const SandboxUsageScript = `if [ -f /sys/fs/cgroup/cpu.stat ]; then ` +
+	`while read key value; do [ "$key" = usage_usec ] && cpu=$value; done < /sys/fs/cgroup/cpu.stat; ` +
+	`while read key value; do [ "$key" = oom_kill ] && oom=$value; done < /sys/fs/cgroup/memory.events; ` +
+	`mem=$(cat /sys/fs/cgroup/memory.peak 2>/dev/null || cat /sys/fs/cgroup/memory.current 2>/dev/null); ` +
+	`else ` +
+	`cpu=$(($(cat /sys/fs/cgroup/cpuacct/cpuacct.usage 2>/dev/null || echo 0) / 1000)); ` +
+	`while read key value; do [ "$key" = oom_kill ] && oom=$value; done 2>/dev/null < /sys/fs/cgroup/memory/memory.oom_control; ` +
+	`mem=$(cat /sys/fs/cgroup/memory/memory.max_usage_in_bytes 2>/dev/null); ` +
+	`fi; ` +
+	`printf '\n%s %s %s %s\n' ` + SandboxUsageMarker + ` "$((${cpu:-0} - ${cpu0:-0}))" "${mem:-0}" "$((${oom:-0} - ${oom0:-0}))" >&2`
+
+// SandboxUsage is the resource usage of a program reported by SandboxUsageScript.
+type SandboxUsage struct {
+	TimeMs      uint
+	MemoryUsage uint
+	// OOMKills is the number of processes the OOM killer killed while the program ran.
+	OOMKills uint
+}
+
 type SandboxInstance struct {
-	RunID           string
+	RunID string
+	// SubmissionID is the submission judged in the sandbox, 0 for other runs.
+	SubmissionID    uint
 	Language        string
 	ImageName       string
 	ProgramVolume   volume.Volume
@@ -18,62 +152,234 @@ type SandboxInstance struct {
 	CompileExitCode int
 	CompileStdout   string
 	CompileStderr   string
+	CompileTimeMs   uint
 	Code            string
+	// ProgramArchive is a tar archive of the compiled program for running it in pooled
+	// containers, nil when the program only runs from ProgramVolume.
+	ProgramArchive []byte
+	// ProgramDir is the host directory of the program for backends that run without Docker.
+	ProgramDir string
+	// TimeMultiplier and MemoryMultiplier scale the limits of program runs in this sandbox.
+	TimeMultiplier   float64
+	MemoryMultiplier float64
 }
 
 type SandboxRunResult struct {
-	Stdout   string
-	Stderr   string
-	ExitCode int
-	Timeout  bool
-	Err      error
+	Stdout    string
+	Stderr    string
+	ExitCode  int
+	Timeout   bool
+	OOMKilled bool
+	// OutputLimitExceeded is set when the program was stopped for writing too much output.
+	OutputLimitExceeded bool
+	// TimeMs is the CPU time used by the program, or the wall time when the usage is unknown.
+	TimeMs uint
+	// MemoryUsage is the peak memory usage in bytes, or zero when it is unknown.
+	MemoryUsage uint
+	Err         error
 }
 
+// SandboxMemoryPlaceholder in a run command is replaced with the memory limit of the testcase
+// in megabytes, before the memory multiplier, for runtimes such as the JVM that need their heap
+// size set explicitly.
+const SandboxMemoryPlaceholder = "{memory_mb}"
+
+// SandboxInstruction describes how to build and start a program for a language.
+// The code is written to /sandbox/SourceFile, and an empty CompileCmd skips compiling.
+// RunCmd starts the compiled program; the sandbox appends stdin redirection or arguments to it.
 type SandboxInstruction struct {
-	Language       string
-	DockerImage    string
-	CompileCmd     string
-	RunCmd         string
-	CompileTimeout uint
+	Language         string
+	DisplayName      string
+	DockerImage      string
+	SourceFile       string
+	CompileCmd       string
+	RunCmd           string
+	CompileTimeout   uint
+	TimeMultiplier   float64
+	MemoryMultiplier float64
+}
+
+// RunCommand returns the run command with SandboxMemoryPlaceholder replaced by memoryLimit.
+func (i *SandboxInstruction) RunCommand(memoryLimit uint) string {
+	memoryMB := strconv.FormatUint(uint64(memoryLimit/SandboxMemoryMB), 10)
+	return strings.ReplaceAll(i.RunCmd, SandboxMemoryPlaceholder, memoryMB)
+}
+
+// DefaultSandboxInstructions are the built-in languages the language table is seeded with.
+var DefaultSandboxInstructions = []SandboxInstruction{
+	PythonInstructionBook,
+	GoInstructionBook,
+	CInstructionBook,
+	CppInstructionBook,
+	JavaInstructionBook,
+	JavaScriptInstructionBook,
+	RustInstructionBook,
+	KotlinInstructionBook,
 }
 
-var LanguageInstructionMap = map[string]SandboxInstruction{
-	"python": PythonInstructionBook,
-	"go":     GoInstructionBook,
-	"c":      CInstructionBook,
+// TruncateOutput cuts output down to limit bytes and marks it as truncated.
+// A character split by the limit is dropped whole so the output stays valid UTF-8.
+func TruncateOutput(output string, limit int) string {
+	if len(output) <= limit {
+		return output
+	}
+
+	end := limit
+	for end > 0 && !utf8.RuneStart(output[end]) {
+		end--
+	}
+	return output[:end] + sandboxTruncatedMarker
 }
 
-func GetSandboxInstructionByLanguage(language string) *SandboxInstruction {
-	// check if language exist
-	instruction, ok := LanguageInstructionMap[language]
-	if !ok {
-		return nil
+// ParseSandboxUsage removes the usage line written by SandboxUsageScript from output
+// and returns the usage. Lines without the OOM kill count report no OOM kills.
+func ParseSandboxUsage(output string) (rest string, usage SandboxUsage, ok bool) {
+	start := strings.LastIndex(output, "\n"+SandboxUsageMarker+" ")
+	if start < 0 {
+		return output, usage, false
+	}
+
+	end := strings.Index(output[start+1:], "\n")
+	if end < 0 {
+		end = len(output)
+	} else {
+		end += start + 2
+	}
+
+	fields := strings.Fields(output[start+1 : end])
+	if len(fields) != 3 && len(fields) != 4 {
+		return output, usage, false
+	}
+
+	values := make([]uint64, len(fields)-1)
+	for i, field := range fields[1:] {
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return output, SandboxUsage{}, false
+		}
+		values[i] = value
 	}
-	return &instruction
+
+	usage.TimeMs = uint(values[0] / 1000)
+	usage.MemoryUsage = uint(values[1])
+	if len(values) == 3 {
+		usage.OOMKills = uint(values[2])
+	}
+
+	return output[:start] + output[end:], usage, true
 }
 
 var PythonInstructionBook = SandboxInstruction{
-	Language:       "python",
-	DockerImage:    "docker.io/library/python:3.10",
-	CompileCmd:     "cp /sandbox/code /sandbox/code.py",
-	RunCmd:         "python3 /sandbox/code.py < /stdin/stdin",
-	CompileTimeout: 1000,
+	Language:         "python",
+	DisplayName:      "Python 3.10",
+	DockerImage:      "docker.io/library/python:3.10",
+	SourceFile:       "code.py",
+	RunCmd:           "python3 /sandbox/code.py",
+	CompileTimeout:   1000,
+	TimeMultiplier:   3,
+	MemoryMultiplier: 1,
 }
 
 var GoInstructionBook = SandboxInstruction{
-	Language:       "go",
-	DockerImage:    "docker.io/library/golang:1.21",
-	CompileCmd:     "cd /sandbox && cp /sandbox/code /sandbox/main.go && go mod init sandbox && go build -o /sandbox/main",
-	RunCmd:         "/sandbox/main < /stdin/stdin",
-	CompileTimeout: 1000 * 60,
+	Language:         "go",
+	DisplayName:      "Go 1.21",
+	DockerImage:      "docker.io/library/golang:1.21",
+	SourceFile:       "main.go",
+	CompileCmd:       "cd /sandbox && go mod init sandbox && go build -o /sandbox/main",
+	RunCmd:           "/sandbox/main",
+	CompileTimeout:   1000 * 60,
+	TimeMultiplier:   1,
+	MemoryMultiplier: 1,
 }
 
 var CInstructionBook = SandboxInstruction{
-	Language:       "c",
-	DockerImage:    "docker.io/library/gcc:12.3.0",
-	CompileCmd:     "cp /sandbox/code /sandbox/main.c && gcc -o /sandbox/main /sandbox/main.c",
-	RunCmd:         "/sandbox/main < /stdin/stdin",
-	CompileTimeout: 1000 * 60,
+	Language:         "c",
+	DisplayName:      "C (GCC 12.3)",
+	DockerImage:      "docker.io/library/gcc:12.3.0",
+	SourceFile:       "main.c",
+	CompileCmd:       "gcc -o /sandbox/main /sandbox/main.c",
+	RunCmd:           "/sandbox/main",
+	CompileTimeout:   1000 * 60,
+	TimeMultiplier:   1,
+	MemoryMultiplier: 1,
+}
+
+var CppInstructionBook = SandboxInstruction{
+	Language:         "cpp",
+	DisplayName:      "C++17 (GCC 12.3)",
+	DockerImage:      "docker.io/library/gcc:12.3.0",
+	SourceFile:       "main.cpp",
+	CompileCmd:       "g++ -O2 -std=c++17 -pipe -o /sandbox/main /sandbox/main.cpp",
+	RunCmd:           "/sandbox/main",
+	CompileTimeout:   1000 * 60,
+	TimeMultiplier:   1,
+	MemoryMultiplier: 1,
+}
+
+// javaCompileCmd renames the source file after its public class as javac requires,
+// then records the top-level class with a main method for the run command.
+const javaCompileCmd = `cd /sandbox && ` +
+	`main=$(sed -n 's/^[[:space:]]*public[[:space:]]\{1,\}\(final[[:space:]]\{1,\}\)\{0,1\}class[[:space:]]\{1,\}\([A-Za-z_][A-Za-z0-9_]*\).*/\2/p' Main.java | head -n 1); ` +
+	`if [ -n "$main" ] && [ "$main" != Main ]; then mv Main.java "$main.java"; fi; ` +
+	`mkdir -p classes && javac -encoding UTF-8 -d classes *.java || exit 1; ` +
+	`for class in classes/*.class; do ` +
+	`name=$(basename "$class" .class); ` +
+	`case "$name" in *'$'*) continue;; esac; ` +
+	`if javap -cp classes "$name" | grep -q 'public static void main(java.lang.String'; then echo "$name" > main_class; exit 0; fi; ` +
+	`done; ` +
+	`echo "no class with a main method found" >&2; exit 1`
+
+// JavaInstructionBook sizes the heap to the memory limit of the testcase,
+// the memory multiplier leaves room for the JVM itself.
+var JavaInstructionBook = SandboxInstruction{
+	Language:         "java",
+	DisplayName:      "Java 17",
+	DockerImage:      "docker.io/library/eclipse-temurin:17-jdk",
+	SourceFile:       "Main.java",
+	CompileCmd:       javaCompileCmd,
+	RunCmd:           "java -Xmx" + SandboxMemoryPlaceholder + "m -Xss64m -XX:+UseSerialGC -cp /sandbox/classes $(cat /sandbox/main_class)",
+	CompileTimeout:   1000 * 60,
+	TimeMultiplier:   2,
+	MemoryMultiplier: 2,
+}
+
+// JavaScriptInstructionBook sizes the V8 heap to the memory limit of the testcase,
+// the memory multiplier leaves room for the runtime itself.
+var JavaScriptInstructionBook = SandboxInstruction{
+	Language:         "javascript",
+	DisplayName:      "JavaScript (Node.js 20)",
+	DockerImage:      "docker.io/library/node:20",
+	SourceFile:       "main.js",
+	RunCmd:           "node --max-old-space-size=" + SandboxMemoryPlaceholder + " --stack-size=65500 /sandbox/main.js",
+	CompileTimeout:   1000,
+	TimeMultiplier:   2,
+	MemoryMultiplier: 2,
+}
+
+var RustInstructionBook = SandboxInstruction{
+	Language:         "rust",
+	DisplayName:      "Rust 1.74",
+	DockerImage:      "docker.io/library/rust:1.74",
+	SourceFile:       "main.rs",
+	CompileCmd:       "rustc -O --edition 2021 -o /sandbox/main /sandbox/main.rs",
+	RunCmd:           "/sandbox/main",
+	CompileTimeout:   1000 * 60,
+	TimeMultiplier:   1,
+	MemoryMultiplier: 1,
+}
+
+// KotlinInstructionBook uses a community image as there is no official Kotlin image,
+// it can be replaced through the language admin API.
+var KotlinInstructionBook = SandboxInstruction{
+	Language:         "kotlin",
+	DisplayName:      "Kotlin 1.9",
+	DockerImage:      "docker.io/zenika/kotlin:1.9.20-jdk17",
+	SourceFile:       "main.kt",
+	CompileCmd:       "kotlinc /sandbox/main.kt -include-runtime -d /sandbox/main.jar",
+	RunCmd:           "java -Xmx" + SandboxMemoryPlaceholder + "m -Xss64m -XX:+UseSerialGC -jar /sandbox/main.jar",
+	CompileTimeout:   1000 * 60 * 2,
+	TimeMultiplier:   2,
+	MemoryMultiplier: 2,
 }
 
 var PythonCodeExample = `
@@ -94,6 +400,117 @@ import time
 time.sleep(2)
 `
 
+// PythonCheckerExample accepts any output whose integers sum to the same value as the answer.
+var PythonCheckerExample = `
+import sys
+
+output = open(sys.argv[2]).read().split()
+answer = open(sys.argv[3]).read().split()
+
+try:
+    if sum(map(int, output)) != sum(map(int, answer)):
+        print("sum differs")
+        sys.exit(1)
+except ValueError:
+    print("output is not an integer list")
+    sys.exit(2)
+`
+
+// PythonCodeStderrExample answers on stdout and writes debug output to stderr.
+var PythonCodeStderrExample = `
+import sys
+
+x = int(input())
+y = int(input())
+print("x =", x, "y =", y, file=sys.stderr)
+print(x + y)
+`
+
+// PythonCodeOutputFloodTestCode prints until it is stopped.
+var PythonCodeOutputFloodTestCode = `
+while True:
+    print("0" * 1023)
+`
+
+// PythonCodeForkBombTestCode forks until it fails and prints how many children it created.
+var PythonCodeForkBombTestCode = `
+import os
+import time
+
+children = 0
+try:
+    while True:
+        if os.fork() == 0:
+            time.sleep(5)
+            os._exit(0)
+        children += 1
+except OSError:
+    pass
+
+print(children)
+`
+
+// PythonCodeDiskFillerTestCode writes up to 1 GB to every writable looking path
+// and prints how many megabytes it managed to write to each one.
+var PythonCodeDiskFillerTestCode = `
+chunk = b"0" * 1024 * 1024
+
+for path in ["/tmp/fill", "/sandbox/fill", "/fill"]:
+    written = 0
+    try:
+        with open(path, "wb") as f:
+            while written < 1024:
+                f.write(chunk)
+                f.flush()
+                written += 1
+    except OSError:
+        pass
+    print(written)
+`
+
+// PythonCodeSelfKillTestCode kills itself with SIGKILL, which is not an out of memory kill.
+var PythonCodeSelfKillTestCode = `
+import os
+import signal
+
+os.kill(os.getpid(), signal.SIGKILL)
+`
+
+// PythonInteractorExample answers guesses of a hidden number read from the input file.
+var PythonInteractorExample = `
+import sys
+
+secret = int(open(sys.argv[1]).read())
+
+for _ in range(20):
+    line = sys.stdin.readline()
+    if not line:
+        sys.exit(1)
+    guess = int(line)
+    if guess == secret:
+        print("=", flush=True)
+        sys.exit(0)
+    print("<" if secret < guess else ">", flush=True)
+
+sys.exit(1)
+`
+
+// PythonInteractiveCodeExample finds the hidden number between 1 and 1000 with binary search.
+var PythonInteractiveCodeExample = `
+low, high = 1, 1000
+
+while low <= high:
+    mid = (low + high) // 2
+    print(mid, flush=True)
+    reply = input()
+    if reply == "=":
+        break
+    if reply == "<":
+        high = mid - 1
+    else:
+        low = mid + 1
+`
+
 var GoCodeExample = `
 package main
 
@@ -131,3 +548,58 @@ int main() {
 
     return 0;
 }`
+
+var CCodeCompileErrorExample = `
+#include <stdio.h>
+
+int main() {
+    printf("%d\n", x)
+    return 0;
+}`
+
+var CppCodeExample = `
+#include <iostream>
+
+int main() {
+    long long x, y;
+    std::cin >> x >> y;
+    std::cout << x + y << std::endl;
+    return 0;
+}`
+
+// JavaCodeExample uses a public class that is not named Main to check the main class handling.
+var JavaCodeExample = `
+import java.util.Scanner;
+
+public class Solution {
+    public static void main(String[] args) {
+        Scanner scanner = new Scanner(System.in);
+        long x = scanner.nextLong();
+        long y = scanner.nextLong();
+        System.out.println(x + y);
+    }
+}`
+
+var JavaScriptCodeExample = `
+const lines = require("fs").readFileSync(0, "utf8").trim().split("\n");
+const x = parseInt(lines[0]);
+const y = parseInt(lines[1]);
+console.log(x + y);
+`
+
+var RustCodeExample = `
+use std::io::{self, Read};
+
+fn main() {
+    let mut input = String::new();
+    io::stdin().read_to_string(&mut input).unwrap();
+    let sum: i64 = input.split_whitespace().map(|x| x.parse::<i64>().unwrap()).sum();
+    println!("{}", sum);
+}`
+
+var KotlinCodeExample = `
+fun main() {
+    val x = readLine()!!.trim().toLong()
+    val y = readLine()!!.trim().toLong()
+    println(x + y)
+}`
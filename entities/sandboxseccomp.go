@@ -0,0 +1,52 @@
+package entities
+
+// SandboxSeccompProfile is the seccomp profile of sandbox containers.
+// It replaces the Docker default profile, so it allows ordinary syscalls and denies
+// the ones a program never needs: kernel and system administration, tracing other
+// processes, keyrings, BPF and creating or joining namespaces.
+// clone3 fails with ENOSYS so the C library falls back to clone, which is denied with CLONE_NEWUSER.
+// Other namespaces need CAP_SYS_ADMIN, which is dropped, unless created inside a new user namespace.
+const SandboxSeccompProfile = `{
+	"defaultAction": "SCMP_ACT_ALLOW",
+	"architectures": ["SCMP_ARCH_X86_64", "SCMP_ARCH_X86", "SCMP_ARCH_X32", "SCMP_ARCH_AARCH64", "SCMP_ARCH_ARM"],
+	"syscalls": [
+		{
+			"names": [
+				"acct", "add_key", "bpf", "chroot", "clock_adjtime", "clock_settime", "create_module",
+				"delete_module", "fanotify_init", "finit_module", "fsconfig", "fsmount", "fsopen", "fspick",
+				"get_kernel_syms", "get_mempolicy", "init_module", "ioperm", "iopl", "kcmp", "kexec_file_load",
+				"kexec_load", "keyctl", "lookup_dcookie", "mbind", "mount", "mount_setattr", "move_mount",
+				"move_pages", "name_to_handle_at", "nfsservctl", "open_by_handle_at", "open_tree",
+				"perf_event_open", "pivot_root", "process_vm_readv", "process_vm_writev", "ptrace",
+				"query_module", "quotactl", "reboot", "request_key", "set_mempolicy", "setdomainname",
+				"sethostname", "setns", "settimeofday", "stime", "swapoff", "swapon", "syslog", "umount",
+				"umount2", "unshare", "uselib", "userfaultfd", "ustat", "vhangup", "vm86", "vm86old"
+			],
+			"action": "SCMP_ACT_ERRNO",
+			"errnoRet": 1
+		},
+		{
+			"names": ["clone3"],
+			"action": "SCMP_ACT_ERRNO",
+			"errnoRet": 38
+		},
+		{
+			"names": ["clone"],
+			"action": "SCMP_ACT_ERRNO",
+			"errnoRet": 1,
+			"args": [
+				{"index": 0, "value": 268435456, "valueTwo": 268435456, "op": "SCMP_CMP_MASKED_EQ"}
+			]
+		}
+	]
+}`
+
+// SandboxNsjailSeccompPolicy is the kafel seccomp policy of programs run by nsjail.
+// It denies the syscalls of SandboxSeccompProfile that are known on every architecture
+// nsjail supports; nsjail already runs programs in their own user namespace without capabilities.
+const SandboxNsjailSeccompPolicy = `ERRNO(1) {
+	acct, add_key, bpf, chroot, delete_module, finit_module, init_module, kexec_load, keyctl,
+	mount, perf_event_open, pivot_root, process_vm_readv, process_vm_writev, ptrace, quotactl,
+	reboot, request_key, setdomainname, sethostname, setns, settimeofday, swapoff, swapon,
+	syslog, umount2, unshare
+} DEFAULT ALLOW`
@@ -1,24 +1,83 @@
 package entities
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
 
 const (
-	SubmissionStatusPending  = "PENDING"
-	SubmissionStatusCorrect  = "CORRECT"
-	SubmissionStatusWrong    = "WRONG"
-	SubmissionStatusNotSolve = "NOTSOLVE"
+	SubmissionStatusPending             = "PENDING"
+	SubmissionStatusCorrect             = "CORRECT"
+	SubmissionStatusWrong               = "WRONG"
+	SubmissionStatusTimeLimitExceeded   = "TIME_LIMIT_EXCEEDED"
+	SubmissionStatusMemoryLimitExceeded = "MEMORY_LIMIT_EXCEEDED"
+	SubmissionStatusRuntimeError        = "RUNTIME_ERROR"
+	SubmissionStatusCompilationError    = "COMPILATION_ERROR"
+	SubmissionStatusOutputLimitExceeded = "OUTPUT_LIMIT_EXCEEDED"
+	SubmissionStatusSystemError         = "SYSTEM_ERROR"
+	SubmissionStatusNotSolve            = "NOTSOLVE"
 )
 
+// SubmissionVerdicts lists every final status a judged testcase or submission can have.
+// CORRECT and WRONG are kept as the values for Accepted and Wrong Answer.
+var SubmissionVerdicts = []string{
+	SubmissionStatusCorrect,
+	SubmissionStatusWrong,
+	SubmissionStatusTimeLimitExceeded,
+	SubmissionStatusMemoryLimitExceeded,
+	SubmissionStatusRuntimeError,
+	SubmissionStatusCompilationError,
+	SubmissionStatusOutputLimitExceeded,
+	SubmissionStatusSystemError,
+}
+
+// Submission lifecycle states. The state tracks where a submission is in the judge,
+// the status holds the verdict.
+const (
+	SubmissionStateQueued    = "QUEUED"
+	SubmissionStateCompiling = "COMPILING"
+	SubmissionStateRunning   = "RUNNING"
+	SubmissionStateJudged    = "JUDGED"
+	SubmissionStateFailed    = "FAILED"
+)
+
+// SubmissionStateTransitions lists the states a submission can move to from each state.
+// A submission that is compiling or running can be queued again when its judge died.
+var SubmissionStateTransitions = map[string][]string{
+	SubmissionStateQueued:    {SubmissionStateQueued, SubmissionStateCompiling, SubmissionStateFailed},
+	SubmissionStateCompiling: {SubmissionStateQueued, SubmissionStateRunning, SubmissionStateJudged, SubmissionStateFailed},
+	SubmissionStateRunning:   {SubmissionStateQueued, SubmissionStateJudged, SubmissionStateFailed},
+	SubmissionStateJudged:    {SubmissionStateQueued},
+	SubmissionStateFailed:    {SubmissionStateQueued},
+}
+
 type Submission struct {
-	ID                  uint                  `json:"submission_id" gorm:"primaryKey"`
-	Language            string                `json:"language"`
-	Code                string                `json:"code"`
-	Status              string                `json:"status" gorm:"default:PENDING"`
-	UserID              uint                  `json:"user_id"`
-	User                *User                 `json:"user"`
-	ChallengeID         uint                  `json:"challenge_id"`
-	Challenge           *Challenge            `json:"challenge"`
-	SubmissionTestcases []*SubmissionTestcase `json:"submission_testcases" gorm:"foreignKey:SubmissionID;constraint:OnDelete:CASCADE"`
+	ID                  uint                        `json:"submission_id" gorm:"primaryKey"`
+	Language            string                      `json:"language"`
+	Code                string                      `json:"code"`
+	Status              string                      `json:"status" gorm:"default:PENDING"`
+	State               string                      `json:"state" gorm:"default:QUEUED"`
+	QueuedAt            *time.Time                  `json:"queued_at"`
+	StartedAt           *time.Time                  `json:"started_at"`
+	FinishedAt          *time.Time                  `json:"finished_at"`
+	JudgedBy            string                      `json:"judged_by"`
+	CompileStdout       string                      `json:"compile_stdout"`
+	CompileStderr       string                      `json:"compile_stderr"`
+	CompileExitCode     int                         `json:"compile_exit_code"`
+	CompileTimeMs       uint                        `json:"compile_time_ms"`
+	Score               float64                     `json:"score"`
+	MaxScore            float64                     `json:"max_score"`
+	MaxTimeMs           uint                        `json:"max_time_ms"`
+	MaxMemoryUsage      uint                        `json:"max_memory_usage"`
+	UserID              uint                        `json:"user_id"`
+	User                *User                       `json:"user"`
+	ChallengeID         uint                        `json:"challenge_id"`
+	Challenge           *Challenge                  `json:"challenge"`
+	SubmissionTestcases []*SubmissionTestcase       `json:"submission_testcases" gorm:"foreignKey:SubmissionID;constraint:OnDelete:CASCADE"`
+	SubtaskResults      []*SubmissionSubtaskResult  `json:"subtask_results" gorm:"foreignKey:SubmissionID;constraint:OnDelete:CASCADE"`
+	VerdictHistory      []*SubmissionVerdictHistory `json:"verdict_history" gorm:"foreignKey:SubmissionID;constraint:OnDelete:CASCADE"`
 }
 
 type SubmissionCreateDTO struct {
@@ -43,7 +102,7 @@ func ValidateSubmissionCreateDTO(c *fiber.Ctx) SubmissionCreateDTO {
 
 func (s *Submission) IsCorrect() bool {
 	for _, testcase := range s.SubmissionTestcases {
-		if testcase.Status == SubmissionStatusWrong || testcase.Status == SubmissionStatusNotSolve {
+		if testcase.Status != SubmissionStatusCorrect {
 			return false
 		}
 	}
@@ -51,6 +110,94 @@ func (s *Submission) IsCorrect() bool {
 	return true
 }
 
+// StderrVisibleTo reports whether user may see the stderr of the testcases under policy.
+func (s *Submission) StderrVisibleTo(user *User, policy string) bool {
+	isStaff := user.Role == UserRoleAdmin || user.Role == UserRoleStaff
+
+	switch policy {
+	case SubmissionStderrVisibilityAll:
+		return true
+	case SubmissionStderrVisibilityOwner:
+		return isStaff || s.UserID == user.ID
+	case SubmissionStderrVisibilityStaff:
+		return isStaff
+	}
+	return false
+}
+
+// IsFinished reports whether the submission is not waiting for or being judged.
+func (s *Submission) IsFinished() bool {
+	return s.State == SubmissionStateJudged || s.State == SubmissionStateFailed
+}
+
+// TransitionTo moves the submission to state and records the time of the transition.
+func (s *Submission) TransitionTo(state string, now time.Time) error {
+	from := s.State
+	if from == "" {
+		from = SubmissionStateQueued
+	}
+
+	allowed := false
+	for _, next := range SubmissionStateTransitions[from] {
+		if next == state {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("invalid submission state transition from %s to %s", from, state)
+	}
+
+	switch state {
+	case SubmissionStateQueued:
+		s.QueuedAt = &now
+		s.StartedAt = nil
+		s.FinishedAt = nil
+		s.JudgedBy = ""
+	case SubmissionStateCompiling:
+		s.StartedAt = &now
+		s.FinishedAt = nil
+	case SubmissionStateJudged, SubmissionStateFailed:
+		s.FinishedAt = &now
+	}
+
+	s.State = state
+	return nil
+}
+
+// UpdateMaxUsage sets the maximum time and memory usage over every testcase.
+func (s *Submission) UpdateMaxUsage() {
+	s.MaxTimeMs = 0
+	s.MaxMemoryUsage = 0
+	for _, testcase := range s.SubmissionTestcases {
+		if testcase.TimeMs > s.MaxTimeMs {
+			s.MaxTimeMs = testcase.TimeMs
+		}
+		if testcase.MemoryUsage > s.MaxMemoryUsage {
+			s.MaxMemoryUsage = testcase.MemoryUsage
+		}
+	}
+}
+
+// Verdict returns the status of the first testcase that is not correct,
+// or CORRECT when every testcase passed. A system error on any testcase wins,
+// as the submission is then judged again instead of being finished.
+func (s *Submission) Verdict() string {
+	for _, testcase := range s.SubmissionTestcases {
+		if testcase.Status == SubmissionStatusSystemError {
+			return SubmissionStatusSystemError
+		}
+	}
+
+	for _, testcase := range s.SubmissionTestcases {
+		if testcase.Status != SubmissionStatusCorrect {
+			return testcase.Status
+		}
+	}
+
+	return SubmissionStatusCorrect
+}
+
 type SubmissionPaginationOptions struct {
 	PaginationOptions
 	User      *User
@@ -0,0 +1,52 @@
+package entities
+
+// Submission event types sent while a submission is judged.
+const (
+	SubmissionEventState    = "state"
+	SubmissionEventTestcase = "testcase"
+	SubmissionEventResult   = "result"
+)
+
+// SubmissionEvent is a progress update of a submission published by the judge.
+// Testcase events carry the judged testcase without its output to keep events small.
+type SubmissionEvent struct {
+	Type         string              `json:"type"`
+	SubmissionID uint                `json:"submission_id"`
+	State        string              `json:"state"`
+	Status       string              `json:"status"`
+	Score        float64             `json:"score"`
+	MaxScore     float64             `json:"max_score"`
+	Testcase     *SubmissionTestcase `json:"testcase,omitempty"`
+}
+
+// IsFinal reports whether no more events follow this event.
+func (e *SubmissionEvent) IsFinal() bool {
+	return e.Type == SubmissionEventResult
+}
+
+// NewSubmissionEvent creates an event of type with the current state of the submission.
+func NewSubmissionEvent(eventType string, submission *Submission) *SubmissionEvent {
+	return &SubmissionEvent{
+		Type:         eventType,
+		SubmissionID: submission.ID,
+		State:        submission.State,
+		Status:       submission.Status,
+		Score:        submission.Score,
+		MaxScore:     submission.MaxScore,
+	}
+}
+
+// NewSubmissionTestcaseEvent creates an event for a judged testcase of the submission.
+func NewSubmissionTestcaseEvent(submission *Submission, testcase *SubmissionTestcase) *SubmissionEvent {
+	event := NewSubmissionEvent(SubmissionEventTestcase, submission)
+	event.Testcase = &SubmissionTestcase{
+		ID:                  testcase.ID,
+		SubmissionID:        testcase.SubmissionID,
+		ChallengeTestcaseID: testcase.ChallengeTestcaseID,
+		Status:              testcase.Status,
+		Note:                testcase.Note,
+		TimeMs:              testcase.TimeMs,
+		MemoryUsage:         testcase.MemoryUsage,
+	}
+	return event
+}
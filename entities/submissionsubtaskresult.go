@@ -0,0 +1,14 @@
+package entities
+
+type SubmissionSubtaskResult struct {
+	ID           uint        `json:"submission_subtask_result_id" gorm:"primaryKey"`
+	SubmissionID uint        `json:"submission_id"`
+	Submission   *Submission `json:"submission"`
+	Number       uint        `json:"number"`
+	Name         string      `json:"name"`
+	Status       string      `json:"status"`
+	Passed       int         `json:"passed"`
+	Total        int         `json:"total"`
+	Score        float64     `json:"score"`
+	MaxScore     float64     `json:"max_score"`
+}
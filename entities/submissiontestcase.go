@@ -1,12 +1,41 @@
 package entities
 
+// SubmissionTestcaseOutputPreviewLimit is the maximum number of bytes of stdout and of stderr
+// kept on a testcase. The full stdout is only used for judging.
+const SubmissionTestcaseOutputPreviewLimit = 4 * 1024
+
+// Stderr visibility policies decide who sees the stderr of submission testcases.
+// Stderr is never judged, it only helps the user debug the program.
+const (
+	// SubmissionStderrVisibilityAll shows stderr to everyone who can see the submission.
+	SubmissionStderrVisibilityAll = "all"
+	// SubmissionStderrVisibilityOwner shows stderr to the user who submitted and to staff.
+	SubmissionStderrVisibilityOwner = "owner"
+	// SubmissionStderrVisibilityStaff shows stderr to staff only.
+	SubmissionStderrVisibilityStaff = "staff"
+	// SubmissionStderrVisibilityNone does not store stderr at all.
+	SubmissionStderrVisibilityNone = "none"
+)
+
+// IsSubmissionStderrVisibility reports whether policy is a valid stderr visibility policy.
+func IsSubmissionStderrVisibility(policy string) bool {
+	switch policy {
+	case SubmissionStderrVisibilityAll, SubmissionStderrVisibilityOwner, SubmissionStderrVisibilityStaff, SubmissionStderrVisibilityNone:
+		return true
+	}
+	return false
+}
+
 type SubmissionTestcase struct {
 	ID                  uint               `json:"submission_testcase_id" gorm:"primaryKey"`
 	Status              string             `json:"status" gorm:"default:PENDING"`
 	Output              string             `json:"output"`
+	Stderr              string             `json:"stderr"`
 	SubmissionID        uint               `json:"submission_id"`
 	Submission          *Submission        `json:"submission"`
 	ChallengeTestcaseID uint               `json:"challenge_testcase_id"`
 	ChallengeTestcase   *ChallengeTestcase `json:"challenge_testcase" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
 	Note                string             `json:"note"`
+	TimeMs              uint               `json:"time_ms"`
+	MemoryUsage         uint               `json:"memory_usage"`
 }
@@ -0,0 +1,46 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SubmissionVerdictHistory keeps the result a submission had before it was rejudged.
+type SubmissionVerdictHistory struct {
+	ID           uint        `json:"submission_verdict_history_id" gorm:"primaryKey"`
+	SubmissionID uint        `json:"submission_id"`
+	Submission   *Submission `json:"submission"`
+	Status       string      `json:"status"`
+	Score        float64     `json:"score"`
+	MaxScore     float64     `json:"max_score"`
+	Reason       string      `json:"reason"`
+	RejudgedByID uint        `json:"rejudged_by_id"`
+	RejudgedBy   *User       `json:"rejudged_by" gorm:"foreignKey:RejudgedByID"`
+	CreatedAt    time.Time   `json:"created_at" gorm:"autoCreateTime"`
+}
+
+type SubmissionRejudgeDTO struct {
+	Reason string `json:"reason" validate:"max=255"`
+}
+
+func ValidateSubmissionRejudgeDTO(c *fiber.Ctx) SubmissionRejudgeDTO {
+	var dto SubmissionRejudgeDTO
+
+	// the body is optional
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&dto); err != nil {
+			panic(err)
+		}
+	}
+
+	if err := validate.Struct(&dto); err != nil {
+		panic(err)
+	}
+
+	return dto
+}
+
+type SubmissionRejudgeResponse struct {
+	Total int `json:"total"`
+}
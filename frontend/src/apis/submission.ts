@@ -20,4 +20,42 @@ export class SubmissionService {
       }),
     });
   }
+
+  static async run(
+    accessToken: string,
+    code: string,
+    language: string,
+    stdin: string
+  ) {
+    return fetch(API_URL + "/submission/run", {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+        Authorization: `Bearer ${accessToken}`,
+      },
+      body: JSON.stringify({
+        language: language,
+        code: code,
+        stdin: stdin,
+      }),
+    });
+  }
+
+  static async rejudge(accessToken: string, submissionID: number) {
+    return fetch(API_URL + `/submission/rejudge/${submissionID}`, {
+      method: "POST",
+      headers: {
+        Authorization: `Bearer ${accessToken}`,
+      },
+    });
+  }
+
+  static async rejudgeChallenge(accessToken: string, challengeID: number) {
+    return fetch(API_URL + `/submission/rejudge/challenge/${challengeID}`, {
+      method: "POST",
+      headers: {
+        Authorization: `Bearer ${accessToken}`,
+      },
+    });
+  }
 }
@@ -28,6 +28,9 @@ export function ChallengeEditor(props: ChallengeEditorProps) {
   const [testcases, setTestcases] = useState<ITestcaseModify[]>([]);
   const [challengeName, setChallengeName] = useState("");
   const [challengeDescription, setChallengeDescription] = useState("");
+  const [judgeSettings, setJudgeSettings] = useState<
+    Partial<ChallengeUpdateDTO>
+  >({});
 
   const [deleteButtonDisabled, setDeleteButtonDisabled] = useState(false);
   const [deleteDialogOpen, setDeleteDialogOpen] = useState(false);
@@ -66,6 +69,7 @@ export function ChallengeEditor(props: ChallengeEditorProps) {
       challenge_id: props.editChallengeID,
       name: challengeName,
       description: challengeDescription,
+      ...judgeSettings,
       testcases: testcases,
     } as ChallengeUpdateDTO;
 
@@ -127,6 +131,19 @@ export function ChallengeEditor(props: ChallengeEditorProps) {
         } else {
           setChallengeName(data.name);
           setChallengeDescription(data.description);
+          setJudgeSettings({
+            type: data.type,
+            interactor_language: data.interactor_language,
+            interactor_code: data.interactor_code,
+            checker: data.checker,
+            checker_abs_epsilon: data.checker_abs_epsilon,
+            checker_rel_epsilon: data.checker_rel_epsilon,
+            checker_language: data.checker_language,
+            checker_code: data.checker_code,
+            serial_testcases: data.serial_testcases,
+            subtasks: data.subtasks,
+            language_limits: data.language_limits,
+          });
           setTestcases(data.testcases);
         }
       }
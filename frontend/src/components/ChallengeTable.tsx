@@ -14,7 +14,9 @@ import TableHead from "@mui/material/TableHead";
 import TableRow from "@mui/material/TableRow";
 import { useState } from "react";
 import { useNavigate } from "react-router-dom";
+import { toast } from "react-toastify";
 import { useDebounce } from "use-debounce";
+import { SubmissionService } from "../apis/submission";
 import { EUserRole } from "../apis/user";
 import { useUser } from "../contexts/user.provider";
 import { usePaginationChallenge } from "../swrs/challenge";
@@ -145,6 +147,21 @@ function ChallengeTableRow({ challenge }: ChallengeTableRowProps) {
   const navigate = useNavigate();
   const { user } = useUser();
 
+  const onRejudge = async () => {
+    if (!user) return;
+
+    const response = await SubmissionService.rejudgeChallenge(
+      user.accessToken,
+      challenge.challenge_id
+    );
+    const data = await response.json();
+    if (response.ok) {
+      toast.success(`${data.total} submissions queued for rejudge`);
+    } else {
+      toast.error(data.message);
+    }
+  };
+
   return (
     <>
       <TableRow
@@ -174,6 +191,9 @@ function ChallengeTableRow({ challenge }: ChallengeTableRowProps) {
                 >
                   Edit
                 </Button>
+                <Button variant="outlined" color="warning" onClick={onRejudge}>
+                  Rejudge
+                </Button>
               </>
             ) : null}
 
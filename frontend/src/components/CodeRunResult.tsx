@@ -0,0 +1,61 @@
+import { Paper, Typography } from "@mui/material";
+import { formatMemory } from "../helpers/format-memory";
+import { useCodeRun } from "../swrs/submission";
+import { SubmissionStatusLabel } from "../types/submission";
+
+interface CodeRunResultProps {
+  codeRunID: number;
+}
+
+export function CodeRunResult(props: CodeRunResultProps) {
+  const { data, isError } = useCodeRun(props.codeRunID);
+
+  if (isError) return <Typography>Failed to load run result</Typography>;
+  if (!data || data.status === "PENDING")
+    return <Typography>Running...</Typography>;
+
+  const compileOutput = data.compile_stdout + data.compile_stderr;
+
+  return (
+    <Paper sx={{ padding: 2 }}>
+      <Typography variant="subtitle1" align="left">
+        {data.status === "COMPLETED"
+          ? "Completed"
+          : SubmissionStatusLabel[data.status]}{" "}
+        (exit code {data.exit_code}, {data.time_ms} ms,{" "}
+        {formatMemory(data.memory_usage)})
+      </Typography>
+
+      {data.status === "COMPILATION_ERROR" ? (
+        <OutputBlock title="Compiler Output" output={compileOutput} />
+      ) : (
+        <>
+          <OutputBlock title="Stdout" output={data.stdout} />
+          <OutputBlock title="Stderr" output={data.stderr} />
+        </>
+      )}
+    </Paper>
+  );
+}
+
+function OutputBlock(props: { title: string; output: string }) {
+  return (
+    <>
+      <Typography variant="subtitle2" align="left" mt={2}>
+        {props.title}
+      </Typography>
+      <Typography
+        variant="body2"
+        component="pre"
+        sx={{
+          backgroundColor: "black",
+          color: "white",
+          padding: 2,
+          overflowX: "auto",
+        }}
+      >
+        {props.output === "" ? "<EMPTY OUTPUT>" : props.output}
+      </Typography>
+    </>
+  );
+}
@@ -1,14 +1,42 @@
+import AccessTimeIcon from "@mui/icons-material/AccessTime";
+import BuildIcon from "@mui/icons-material/Build";
 import CancelIcon from "@mui/icons-material/Cancel";
 import CheckBoxIcon from "@mui/icons-material/CheckBox";
+import ErrorIcon from "@mui/icons-material/Error";
 import HourglassEmptyIcon from "@mui/icons-material/HourglassEmpty";
+import MemoryIcon from "@mui/icons-material/Memory";
+import ReportProblemIcon from "@mui/icons-material/ReportProblem";
+import SubjectIcon from "@mui/icons-material/Subject";
+import { Tooltip } from "@mui/material";
+import { SubmissionStatusLabel } from "../types/submission";
 
-export function ShowStatusIcon(status: string) {
+function StatusIcon(status: string) {
   switch (status) {
     case "CORRECT":
       return <CheckBoxIcon color="success" />;
     case "PENDING":
       return <HourglassEmptyIcon color="warning" />;
+    case "TIME_LIMIT_EXCEEDED":
+      return <AccessTimeIcon color="error" />;
+    case "MEMORY_LIMIT_EXCEEDED":
+      return <MemoryIcon color="error" />;
+    case "RUNTIME_ERROR":
+      return <ErrorIcon color="error" />;
+    case "COMPILATION_ERROR":
+      return <BuildIcon color="error" />;
+    case "OUTPUT_LIMIT_EXCEEDED":
+      return <SubjectIcon color="error" />;
+    case "SYSTEM_ERROR":
+      return <ReportProblemIcon color="warning" />;
     default:
       return <CancelIcon color="error" />;
   }
 }
+
+export function ShowStatusIcon(status: string) {
+  return (
+    <Tooltip title={SubmissionStatusLabel[status] ?? status}>
+      {StatusIcon(status)}
+    </Tooltip>
+  );
+}
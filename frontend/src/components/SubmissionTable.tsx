@@ -1,6 +1,3 @@
-import CancelIcon from "@mui/icons-material/Cancel";
-import CheckBoxIcon from "@mui/icons-material/CheckBox";
-import HourglassEmptyIcon from "@mui/icons-material/HourglassEmpty";
 import RemoveRedEyeIcon from "@mui/icons-material/RemoveRedEye";
 import { Box, Button, TablePagination, TextField } from "@mui/material";
 import Paper from "@mui/material/Paper";
@@ -15,17 +12,8 @@ import { useNavigate } from "react-router-dom";
 import { useDebounce } from "use-debounce";
 import { usePaginationSubmission } from "../swrs/submission";
 import { Submission } from "../types/submission";
-
-function ShowStatusIcon(status: string) {
-  switch (status) {
-    case "CORRECT":
-      return <CheckBoxIcon color="success" />;
-    case "PENDING":
-      return <HourglassEmptyIcon color="warning" />;
-    default:
-      return <CancelIcon color="error" />;
-  }
-}
+import { formatMemory } from "../helpers/format-memory";
+import { ShowStatusIcon } from "./StatusIcon";
 
 export function SubmissionTable() {
   const [page, setPage] = useState(0);
@@ -105,6 +93,9 @@ export function SubmissionTable() {
                 <TableCell>Challenge Name</TableCell>
                 <TableCell align="right">Language</TableCell>
                 <TableCell align="right">Created By</TableCell>
+                <TableCell align="right">Time</TableCell>
+                <TableCell align="right">Memory</TableCell>
+                <TableCell align="right">Score</TableCell>
                 <TableCell align="right">Status</TableCell>
                 <TableCell align="right">Action</TableCell>
               </TableRow>
@@ -154,6 +145,15 @@ function SubmissionTableRow(props: SubmissionTableRowProps) {
       <TableCell component="th" scope="row" align="right">
         {props.submission.user.displayname}
       </TableCell>
+      <TableCell component="th" scope="row" align="right">
+        {props.submission.max_time_ms} ms
+      </TableCell>
+      <TableCell component="th" scope="row" align="right">
+        {formatMemory(props.submission.max_memory_usage)}
+      </TableCell>
+      <TableCell component="th" scope="row" align="right">
+        {props.submission.score} / {props.submission.max_score}
+      </TableCell>
       <TableCell align="right">
         {ShowStatusIcon(props.submission.status)}
       </TableCell>
@@ -55,6 +55,28 @@ export function Testcase(props: TestcaseProps) {
         </Card>
       ) : null}
 
+      {props.testcase.stderr ? (
+        <Card sx={{ flexGrow: 1, flexBasis: "50%", width: "full" }}>
+          <CardContent>
+            <Typography
+              sx={{ fontSize: 14, marginBottom: 2 }}
+              color="text.secondary"
+              gutterBottom
+            >
+              Stderr
+            </Typography>
+
+            <Typography
+              variant="subtitle1"
+              component="div"
+              sx={{ backgroundColor: "black", color: "white", padding: 2 }}
+            >
+              {props.testcase.stderr}
+            </Typography>
+          </CardContent>
+        </Card>
+      ) : null}
+
       <Card sx={{ flexGrow: 1, flexBasis: "50%", width: "full" }}>
         <CardContent>
           <Typography
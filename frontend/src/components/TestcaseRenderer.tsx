@@ -6,6 +6,7 @@ import {
   Typography,
 } from "@mui/material";
 import { ITestcase } from "../types/testcase";
+import { formatMemory } from "../helpers/format-memory";
 import { ShowStatusIcon } from "./StatusIcon";
 import { Testcase } from "./Testcase";
 
@@ -20,6 +21,11 @@ export function TestcaseRenderer(props: TestcaseRendererProps) {
         <AccordionSummary expandIcon={<ExpandMoreIcon />}>
           <Typography>Testcase #{testcase.testcase_id}</Typography>
           {testcase.correct ? ShowStatusIcon(testcase.correct) : null}
+          {testcase.time_ms !== undefined && (
+            <Typography sx={{ ml: 2, color: "text.secondary" }}>
+              {testcase.time_ms} ms / {formatMemory(testcase.memory_usage)}
+            </Typography>
+          )}
         </AccordionSummary>
         <AccordionDetails>
           <Testcase testcase={testcase} />
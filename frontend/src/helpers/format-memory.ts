@@ -0,0 +1,9 @@
+export function formatMemory(bytes: number | undefined) {
+  if (!bytes) {
+    return "-";
+  }
+  if (bytes < 1024 * 1024) {
+    return `${(bytes / 1024).toFixed(0)} KB`;
+  }
+  return `${(bytes / 1024 / 1024).toFixed(1)} MB`;
+}
@@ -8,6 +8,7 @@ import {
   MenuItem,
   Paper,
   Select,
+  TextField,
   Typography,
 } from "@mui/material";
 import { useState } from "react";
@@ -17,11 +18,14 @@ import { Prism as SyntaxHighlighter } from "react-syntax-highlighter";
 import { vscDarkPlus } from "react-syntax-highlighter/dist/esm/styles/prism";
 import { toast } from "react-toastify";
 import { SubmissionService } from "../apis/submission";
+import { CodeRunResult } from "../components/CodeRunResult";
 import { Navbar } from "../components/Navbar";
 import { TestcaseRenderer } from "../components/TestcaseRenderer";
 import { useUser } from "../contexts/user.provider";
 import { handleBadRequest } from "../helpers/badrequest-toast";
 import { useChallenge } from "../swrs/challenge";
+import { useLanguages } from "../swrs/language";
+import { CodeRun } from "../types/coderun";
 import { SubmissionSubmitResponse } from "../types/submission";
 import { ITestcase } from "../types/testcase";
 
@@ -30,6 +34,7 @@ export default function SolvePage() {
   const id = parseInt(params.id as any);
 
   const { data, isError, isLoading } = useChallenge(id);
+  const { data: languages } = useLanguages();
   const { user } = useUser();
 
   const [language, setLanguage] = useState("python");
@@ -37,6 +42,10 @@ export default function SolvePage() {
 
   const [submitButtonDisabled, setSubmitButtonDisabled] = useState(false);
 
+  const [stdin, setStdin] = useState("");
+  const [codeRunID, setCodeRunID] = useState<number>();
+  const [runButtonDisabled, setRunButtonDisabled] = useState(false);
+
   if (isLoading) return <div>Loading...</div>;
   if (isError) return <div>Error</div>;
   if (!user) return <div>Not logged in</div>;
@@ -65,6 +74,28 @@ export default function SolvePage() {
     setSubmitButtonDisabled(false);
   };
 
+  const onRun = async () => {
+    setRunButtonDisabled(true);
+    const response = await SubmissionService.run(
+      user.accessToken,
+      code,
+      language,
+      stdin
+    );
+
+    if (response.ok) {
+      const data: CodeRun = await response.json();
+      setCodeRunID(data.code_run_id);
+    } else if (response.status === 429) {
+      toast.error("Too many runs, please wait a moment");
+    } else {
+      const data = await response.json();
+      handleBadRequest(data);
+    }
+
+    setRunButtonDisabled(false);
+  };
+
   return (
     <>
       <Navbar />
@@ -152,11 +183,23 @@ export default function SolvePage() {
               value={language}
               onChange={(e) => setLanguage(e.target.value as string)}
             >
-              <MenuItem value="python">Python</MenuItem>
-              <MenuItem value="c">C</MenuItem>
-              <MenuItem value="go">Go</MenuItem>
+              {(languages ?? []).map((item) => (
+                <MenuItem key={item.language_id} value={item.language}>
+                  {item.display_name}
+                </MenuItem>
+              ))}
             </Select>
 
+            <Button
+              variant="outlined"
+              color="primary"
+              size="large"
+              onClick={onRun}
+              disabled={runButtonDisabled}
+            >
+              Run
+            </Button>
+
             <Button
               variant="contained"
               color="primary"
@@ -167,6 +210,22 @@ export default function SolvePage() {
               Submit
             </Button>
           </Box>
+
+          <TextField
+            label="Custom Input"
+            multiline
+            minRows={3}
+            fullWidth
+            value={stdin}
+            onChange={(e) => setStdin(e.target.value)}
+            sx={{ mt: 3 }}
+          />
+
+          {codeRunID && (
+            <Box mt={3}>
+              <CodeRunResult codeRunID={codeRunID} />
+            </Box>
+          )}
         </Box>
       </Box>
 
@@ -5,27 +5,59 @@ import {
   CssBaseline,
   Divider,
   Paper,
+  Table,
+  TableBody,
+  TableCell,
+  TableHead,
+  TableRow,
   Typography,
 } from "@mui/material";
 import ReactMarkdown from "react-markdown";
 import { useNavigate, useParams } from "react-router-dom";
 import { Prism as SyntaxHighlighter } from "react-syntax-highlighter";
 import { vscDarkPlus } from "react-syntax-highlighter/dist/esm/styles/prism";
+import { toast } from "react-toastify";
+import { SubmissionService } from "../apis/submission";
+import { EUserRole } from "../apis/user";
 import { Navbar } from "../components/Navbar";
+import { ShowStatusIcon } from "../components/StatusIcon";
 import { TestcaseRenderer } from "../components/TestcaseRenderer";
-import { useSubmission } from "../swrs/submission";
+import { useUser } from "../contexts/user.provider";
+import { useSubmission, useSubmissionStream } from "../swrs/submission";
 import { ITestcase } from "../types/testcase";
+import { formatMemory } from "../helpers/format-memory";
 
 export default function SubmissionViewPage() {
   const navigate = useNavigate();
   const params = useParams<{ id: string }>();
   const id = parseInt(params.id as any);
 
-  const { data, isError, isLoading } = useSubmission(id);
+  const { data, isError, isLoading, mutate } = useSubmission(id);
+  const { user } = useUser();
+
+  const isJudging =
+    !!data && data.state !== "JUDGED" && data.state !== "FAILED";
+  useSubmissionStream(id, isJudging);
 
   if (isLoading) return <div>Loading...</div>;
   if (isError) return <div>Error</div>;
 
+  const canRejudge =
+    user && (user.role === EUserRole.ADMIN || user.role === EUserRole.STAFF);
+
+  const onRejudge = async () => {
+    if (!user) return;
+
+    const response = await SubmissionService.rejudge(user.accessToken, id);
+    if (response.ok) {
+      toast.success("Submission queued for rejudge");
+      mutate();
+    } else {
+      const data = await response.json();
+      toast.error(data.message);
+    }
+  };
+
   return (
     <Container sx={{ width: "100%" }} disableGutters>
       <CssBaseline />
@@ -39,13 +71,21 @@ export default function SubmissionViewPage() {
               Submission #{data.submission_id} {data.challenge.name}
             </Typography>
 
-            <Button
-              variant="contained"
-              color="primary"
-              onClick={() => navigate(`/solve/${data.challenge_id}`)}
-            >
-              Go to Challenge
-            </Button>
+            <Box display="flex" gap={1}>
+              {canRejudge && (
+                <Button variant="contained" color="warning" onClick={onRejudge}>
+                  Rejudge
+                </Button>
+              )}
+
+              <Button
+                variant="contained"
+                color="primary"
+                onClick={() => navigate(`/solve/${data.challenge_id}`)}
+              >
+                Go to Challenge
+              </Button>
+            </Box>
           </Box>
           <Divider sx={{ my: 3 }} />
 
@@ -72,6 +112,116 @@ export default function SubmissionViewPage() {
           />
         </Paper>
 
+        <Paper sx={{ padding: 3, mt: 5 }}>
+          <Typography variant="h6" align="left">
+            Score {data.score} / {data.max_score}
+          </Typography>
+          <Typography variant="subtitle1" align="left">
+            Max time {data.max_time_ms} ms, max memory{" "}
+            {formatMemory(data.max_memory_usage)}
+          </Typography>
+          <Typography variant="subtitle1" align="left">
+            State {data.state}
+            {data.judged_by && ` by ${data.judged_by}`}
+            {data.queued_at &&
+              `, queued at ${new Date(data.queued_at).toLocaleString()}`}
+            {data.finished_at &&
+              `, finished at ${new Date(data.finished_at).toLocaleString()}`}
+          </Typography>
+
+          {data.subtask_results && data.subtask_results.length > 0 && (
+            <>
+              <Divider sx={{ my: 3 }} />
+              <Table size="small">
+                <TableHead>
+                  <TableRow>
+                    <TableCell>Subtask</TableCell>
+                    <TableCell align="right">Passed</TableCell>
+                    <TableCell align="right">Score</TableCell>
+                    <TableCell align="right">Status</TableCell>
+                  </TableRow>
+                </TableHead>
+                <TableBody>
+                  {data.subtask_results.map((result) => (
+                    <TableRow key={result.number}>
+                      <TableCell>
+                        #{result.number} {result.name}
+                      </TableCell>
+                      <TableCell align="right">
+                        {result.passed} / {result.total}
+                      </TableCell>
+                      <TableCell align="right">
+                        {result.score} / {result.max_score}
+                      </TableCell>
+                      <TableCell align="right">
+                        {ShowStatusIcon(result.status)}
+                      </TableCell>
+                    </TableRow>
+                  ))}
+                </TableBody>
+              </Table>
+            </>
+          )}
+        </Paper>
+
+        {data.verdict_history && data.verdict_history.length > 0 && (
+          <Paper sx={{ padding: 3, mt: 5 }}>
+            <Typography variant="h6" align="left">
+              Verdict History
+            </Typography>
+            <Divider sx={{ my: 3 }} />
+            <Table size="small">
+              <TableHead>
+                <TableRow>
+                  <TableCell>Rejudged At</TableCell>
+                  <TableCell>Reason</TableCell>
+                  <TableCell align="right">Score</TableCell>
+                  <TableCell align="right">Previous Status</TableCell>
+                </TableRow>
+              </TableHead>
+              <TableBody>
+                {data.verdict_history.map((history) => (
+                  <TableRow key={history.submission_verdict_history_id}>
+                    <TableCell>
+                      {new Date(history.created_at).toLocaleString()}
+                    </TableCell>
+                    <TableCell>{history.reason}</TableCell>
+                    <TableCell align="right">
+                      {history.score} / {history.max_score}
+                    </TableCell>
+                    <TableCell align="right">
+                      {ShowStatusIcon(history.status)}
+                    </TableCell>
+                  </TableRow>
+                ))}
+              </TableBody>
+            </Table>
+          </Paper>
+        )}
+
+        {data.status === "COMPILATION_ERROR" && (
+          <Paper sx={{ padding: 3, mt: 5 }}>
+            <Typography variant="h6" align="left">
+              Compilation Error (exit code {data.compile_exit_code},{" "}
+              {data.compile_time_ms} ms)
+            </Typography>
+            <Divider sx={{ my: 3 }} />
+
+            <Typography
+              variant="subtitle1"
+              component="pre"
+              sx={{
+                backgroundColor: "black",
+                color: "white",
+                padding: 2,
+                overflowX: "auto",
+              }}
+            >
+              {data.compile_stdout + data.compile_stderr}
+            </Typography>
+          </Paper>
+        )}
+
         <Paper sx={{ padding: 3, mt: 5 }}>
           <Typography variant="h6" align="left">
             Testcase Results
@@ -85,7 +235,10 @@ export default function SubmissionViewPage() {
                 correct: t.status,
                 input: t.challenge_testcase.input,
                 output: t.output === "" ? "<EMPTY OUTPUT>" : t.output,
+                stderr: t.stderr,
                 expected_output: t.challenge_testcase.expected_output,
+                time_ms: t.time_ms,
+                memory_usage: t.memory_usage,
               } as ITestcase;
             })}
           />
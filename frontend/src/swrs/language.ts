@@ -0,0 +1,13 @@
+import useSWR from "swr";
+import { Language } from "../types/language";
+import { fetcher } from "./fetcher";
+
+export function useLanguages() {
+  const { data, error, isLoading } = useSWR(() => "/languages", fetcher);
+
+  return {
+    data: data as Language[] | undefined,
+    isLoading,
+    isError: error,
+  };
+}
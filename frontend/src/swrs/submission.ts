@@ -1,6 +1,9 @@
-import useSWR from "swr";
+import { useEffect } from "react";
+import useSWR, { useSWRConfig } from "swr";
+import { API_URL } from "../apis/API_URL";
 import { PaginationResult } from "../types/pagination";
-import { Submission } from "../types/submission";
+import { CodeRun } from "../types/coderun";
+import { Submission, SubmissionEvent } from "../types/submission";
 import { fetcherWithAuth } from "./fetcher";
 
 export function usePaginationSubmission(
@@ -38,7 +41,7 @@ export function usePaginationSubmission(
 }
 
 export function useSubmission(submissionID: any) {
-  const { data, error, isLoading } = useSWR(
+  const { data, error, isLoading, mutate } = useSWR(
     () => `/submission/get/${submissionID}`,
     fetcherWithAuth
   );
@@ -47,5 +50,76 @@ export function useSubmission(submissionID: any) {
     data: data as Submission,
     isLoading,
     isError: error,
+    mutate,
+  };
+}
+
+// useSubmissionStream listens to the live events of a submission while it is
+// judged. Testcase events update the cached submission in place, state and
+// result events refetch it.
+export function useSubmissionStream(submissionID: any, enabled: boolean) {
+  const { mutate } = useSWRConfig();
+
+  useEffect(() => {
+    if (!submissionID || !enabled) return;
+
+    const key = `/submission/get/${submissionID}`;
+    const token = localStorage.getItem("accessToken");
+    const source = new EventSource(
+      `${API_URL}/submission/stream/${submissionID}?token=${token}`
+    );
+
+    const onTestcase = (message: MessageEvent) => {
+      const event: SubmissionEvent = JSON.parse(message.data);
+      if (!event.testcase) return;
+
+      mutate(
+        key,
+        (submission?: Submission) =>
+          submission && {
+            ...submission,
+            state: event.state,
+            submission_testcases: submission.submission_testcases.map(
+              (testcase) =>
+                testcase.submission_testcase_id ===
+                event.testcase!.submission_testcase_id
+                  ? { ...testcase, ...event.testcase, output: testcase.output }
+                  : testcase
+            ),
+          },
+        { revalidate: false }
+      );
+    };
+
+    const onState = () => mutate(key);
+
+    const onResult = () => {
+      source.close();
+      mutate(key);
+    };
+
+    source.addEventListener("testcase", onTestcase);
+    source.addEventListener("state", onState);
+    source.addEventListener("result", onResult);
+
+    return () => source.close();
+  }, [submissionID, enabled, mutate]);
+}
+
+export function useCodeRun(codeRunID: number | undefined) {
+  const { data, error, isLoading } = useSWR(
+    () => (codeRunID ? `/submission/run/${codeRunID}` : null),
+    fetcherWithAuth,
+    {
+      // poll until the consumer has finished the run
+      refreshInterval: (latest) =>
+        latest && latest.status !== "PENDING" ? 0 : 1000,
+    }
+  );
+
+  return {
+    data: data as CodeRun | undefined,
+    isLoading,
+    isError: error,
   };
 }
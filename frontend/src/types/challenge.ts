@@ -3,12 +3,46 @@
 import { ITestcaseModify } from "./testcase";
 import { User } from "./user";
 
+export type ChallengeType = "standard" | "interactive";
+
+export type ChallengeChecker = "exact" | "token" | "line" | "float" | "custom";
+
+export type ChallengeSubtaskScoringRule = "all" | "proportional";
+
+export interface ChallengeSubtask {
+  subtask_id: number;
+  number: number;
+  name: string;
+  points: number;
+  scoring_rule: ChallengeSubtaskScoringRule;
+  challenge_id: number;
+}
+
+export interface ChallengeLanguageLimit {
+  challenge_language_limit_id: number;
+  language: string;
+  time_multiplier: number;
+  memory_multiplier: number;
+  challenge_id: number;
+}
+
 export interface Challenge {
   challenge_id: number;
   name: string;
   description: string;
+  type: ChallengeType;
+  interactor_language: string;
+  interactor_code: string;
+  checker: ChallengeChecker;
+  checker_abs_epsilon: number;
+  checker_rel_epsilon: number;
+  checker_language: string;
+  checker_code: string;
+  serial_testcases: boolean;
   user_id: number;
   testcases: ChallengeTestcase[];
+  subtasks: ChallengeSubtask[];
+  language_limits: ChallengeLanguageLimit[];
   submission: null;
   user: User;
   submission_status: string;
@@ -20,6 +54,7 @@ export interface ChallengeTestcase {
   expected_output: string;
   limit_memory: number;
   limit_time_ms: number;
+  subtask: number;
   submission_testcases: null;
   challenge_id: number;
   challenge: null;
@@ -29,5 +64,19 @@ export interface ChallengeUpdateDTO {
   challenge_id: number;
   name: string;
   description: string;
+  type?: ChallengeType;
+  interactor_language?: string;
+  interactor_code?: string;
+  checker?: ChallengeChecker;
+  checker_abs_epsilon?: number;
+  checker_rel_epsilon?: number;
+  checker_language?: string;
+  checker_code?: string;
+  serial_testcases?: boolean;
+  subtasks?: Omit<ChallengeSubtask, "subtask_id" | "challenge_id">[];
+  language_limits?: Omit<
+    ChallengeLanguageLimit,
+    "challenge_language_limit_id" | "challenge_id"
+  >[];
   testcases: ITestcaseModify[];
 }
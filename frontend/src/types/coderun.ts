@@ -0,0 +1,20 @@
+import { SubmissionStatus } from "./submission";
+
+export type CodeRunStatus = SubmissionStatus | "COMPLETED";
+
+export interface CodeRun {
+  code_run_id: number;
+  language: string;
+  code: string;
+  stdin: string;
+  status: CodeRunStatus;
+  stdout: string;
+  stderr: string;
+  exit_code: number;
+  time_ms: number;
+  memory_usage: number;
+  compile_stdout: string;
+  compile_stderr: string;
+  user_id: number;
+  created_at: string;
+}
@@ -0,0 +1,13 @@
+export interface Language {
+  language_id: number;
+  language: string;
+  display_name: string;
+  docker_image: string;
+  source_file: string;
+  compile_cmd: string;
+  run_cmd: string;
+  compile_timeout: number;
+  time_multiplier: number;
+  memory_multiplier: number;
+  enabled: boolean;
+}
@@ -3,22 +3,107 @@
 import { Challenge, ChallengeTestcase } from "./challenge";
 import { User } from "./user";
 
+export type SubmissionStatus =
+  | "PENDING"
+  | "CORRECT"
+  | "WRONG"
+  | "TIME_LIMIT_EXCEEDED"
+  | "MEMORY_LIMIT_EXCEEDED"
+  | "RUNTIME_ERROR"
+  | "COMPILATION_ERROR"
+  | "OUTPUT_LIMIT_EXCEEDED"
+  | "SYSTEM_ERROR";
+
+export const SubmissionStatusLabel: Record<string, string> = {
+  PENDING: "Pending",
+  CORRECT: "Accepted",
+  WRONG: "Wrong Answer",
+  TIME_LIMIT_EXCEEDED: "Time Limit Exceeded",
+  MEMORY_LIMIT_EXCEEDED: "Memory Limit Exceeded",
+  RUNTIME_ERROR: "Runtime Error",
+  COMPILATION_ERROR: "Compilation Error",
+  OUTPUT_LIMIT_EXCEEDED: "Output Limit Exceeded",
+  SYSTEM_ERROR: "System Error",
+  NOTSOLVE: "Not Solved",
+};
+
+export type SubmissionState =
+  | "QUEUED"
+  | "COMPILING"
+  | "RUNNING"
+  | "JUDGED"
+  | "FAILED";
+
 export interface Submission {
   submission_id: number;
   language: string;
   source_code: string;
-  status: string;
+  status: SubmissionStatus;
+  state: SubmissionState;
+  queued_at: string | null;
+  started_at: string | null;
+  finished_at: string | null;
+  judged_by: string;
+  compile_stdout: string;
+  compile_stderr: string;
+  compile_exit_code: number;
+  compile_time_ms: number;
+  score: number;
+  max_score: number;
+  max_time_ms: number;
+  max_memory_usage: number;
   user_id: number;
   user: User;
   challenge_id: number;
   challenge: Challenge;
   submission_testcases: SubmissionTestcase[];
+  subtask_results: SubmissionSubtaskResult[];
+  verdict_history: SubmissionVerdictHistory[];
+}
+
+export type SubmissionEventType = "state" | "testcase" | "result";
+
+export interface SubmissionEvent {
+  type: SubmissionEventType;
+  submission_id: number;
+  state: SubmissionState;
+  status: SubmissionStatus;
+  score: number;
+  max_score: number;
+  testcase?: SubmissionTestcase;
+}
+
+export interface SubmissionVerdictHistory {
+  submission_verdict_history_id: number;
+  submission_id: number;
+  status: SubmissionStatus;
+  score: number;
+  max_score: number;
+  reason: string;
+  rejudged_by_id: number;
+  created_at: string;
+}
+
+export interface SubmissionSubtaskResult {
+  submission_subtask_result_id: number;
+  submission_id: number;
+  number: number;
+  name: string;
+  status: SubmissionStatus;
+  passed: number;
+  total: number;
+  score: number;
+  max_score: number;
 }
 
 export interface SubmissionTestcase {
   submission_testcase_id: number;
-  status: string;
+  status: SubmissionStatus;
   output: string;
+  stderr: string;
+  note: string;
+  time_ms: number;
+  memory_usage: number;
   submission_id: number;
   submission: null;
   challenge_testcase_id: number;
@@ -27,8 +112,12 @@ export interface SubmissionTestcase {
 
 export interface SubmissionTestcase {
   submission_testcase_id: number;
-  status: string;
+  status: SubmissionStatus;
   output: string;
+  stderr: string;
+  note: string;
+  time_ms: number;
+  memory_usage: number;
   submission_id: number;
   submission: null;
   challenge_testcase_id: number;
@@ -39,7 +128,7 @@ export interface SubmissionSubmitResponse {
   submission_id: number;
   language: string;
   source_code: string;
-  status: string;
+  status: SubmissionStatus;
   user_id: number;
   user: null;
   challenge_id: number;
@@ -2,10 +2,13 @@ export interface ITestcase {
   testcase_id: any | undefined;
   input: string | undefined;
   output: string | undefined;
+  stderr?: string;
   expected_output: string | undefined;
   limit_memory: number;
   limit_time_ms: number;
   correct: string | undefined;
+  time_ms?: number;
+  memory_usage?: number;
 }
 
 export interface ITestcaseModify {
@@ -14,5 +17,6 @@ export interface ITestcaseModify {
   expected_output: string | undefined;
   limit_memory: number;
   limit_time_ms: number;
+  subtask?: number;
   action: "create" | "update" | "delete";
 }
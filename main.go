@@ -1,28 +1,117 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/spf13/viper"
 	"github.com/wuttinanhi/code-judge-system/configs"
 	"github.com/wuttinanhi/code-judge-system/consumers"
 	"github.com/wuttinanhi/code-judge-system/controllers"
 	"github.com/wuttinanhi/code-judge-system/databases"
+	"github.com/wuttinanhi/code-judge-system/entities"
 	"github.com/wuttinanhi/code-judge-system/services"
+	"gorm.io/gorm"
 )
 
 func main() {
 	configs.LoadConfig()
 
+	if len(os.Args) > 1 && os.Args[1] == "cleanup" {
+		cleanup(os.Args[2:])
+		return
+	}
+
 	db := databases.NewMySQLDatabase()
 	serviceKit := services.CreateServiceKit(db)
 
+	// read env var "SHUTDOWN_TIMEOUT_MS" for the wait for requests and jobs in progress on shutdown
+	// if SHUTDOWN_TIMEOUT_MS is empty, wait 30 seconds
+	shutdownTimeoutMs := viper.GetInt("SHUTDOWN_TIMEOUT_MS")
+	if shutdownTimeoutMs <= 0 {
+		shutdownTimeoutMs = 30000
+	}
+	shutdownTimeout := time.Duration(shutdownTimeoutMs) * time.Millisecond
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	APP_MODE := viper.GetString("APP_MODE")
 
 	if APP_MODE == "CONSUMER" {
-		consumers.StartSubmissionConsumer(serviceKit)
+		err := consumers.StartConsumers(ctx, serviceKit, shutdownTimeout)
+		shutdown(serviceKit, db)
+		if err != nil {
+			log.Fatal(err)
+		}
 		return
 	}
 
 	rateLimitStorage := controllers.GetRedisStorage()
 	api := controllers.SetupAPI(serviceKit, rateLimitStorage)
-	api.Listen(":3000")
+
+	go func() {
+		err := api.Listen(":3000")
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down API...")
+
+	err := api.ShutdownWithTimeout(shutdownTimeout)
+	if err != nil {
+		log.Println("Failed to shut down API:", err)
+	}
+	shutdown(serviceKit, db)
+}
+
+// shutdown removes the sandboxes that are left and closes the connections to Kafka and the database.
+func shutdown(serviceKit *services.ServiceKit, db *gorm.DB) {
+	err := serviceKit.SandboxService.Close()
+	if err != nil {
+		log.Println("Failed to clean up sandboxes:", err)
+	}
+
+	err = serviceKit.KafkaService.Close()
+	if err != nil {
+		log.Println("Failed to close Kafka:", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err == nil {
+		err = sqlDB.Close()
+	}
+	if err != nil {
+		log.Println("Failed to close database:", err)
+	}
+
+	log.Println("Shut down")
+}
+
+// cleanup removes the sandbox containers and volumes left behind by dead judges.
+// Only Docker sandboxes can be found by their labels, so it fails with any other backend.
+// Usage: app cleanup [-max-age duration]
+func cleanup(args []string) {
+	flags := flag.NewFlagSet("cleanup", flag.ExitOnError)
+	maxAge := flags.Duration("max-age", consumers.ReadSandboxReaperMaxAge(), "remove Docker sandboxes created longer ago than this")
+	flags.Parse(args)
+
+	backend := services.ReadSandboxBackend()
+	if backend != entities.SandboxBackendDocker {
+		log.Fatal("cleanup only removes Docker sandboxes, SANDBOX_BACKEND is ", backend)
+	}
+
+	reaper := services.NewSandboxReaper(services.NewDockerservice(), nil)
+	containers, volumes, err := reaper.Reap(*maxAge)
+	log.Println("Removed", containers, "sandbox containers and", volumes, "sandbox volumes")
+	if err != nil {
+		log.Fatal("Failed to remove sandboxes: ", err)
+	}
 }
@@ -81,11 +81,39 @@ func (r *challengeRepository) UpdateChallengeWithTestcase(challenge *entities.Ch
 			}
 		}
 
-		err = tx.Session(&gorm.Session{FullSaveAssociations: false}).Omit("Testcases").Save(challenge).Error
+		err = tx.Session(&gorm.Session{FullSaveAssociations: false}).Omit("Testcases", "Subtasks", "LanguageLimits").Save(challenge).Error
 		if err != nil {
 			return err
 		}
 
+		// subtasks are replaced as a whole
+		err = tx.Where(&entities.ChallengeSubtask{ChallengeID: challenge.ID}).Delete(&entities.ChallengeSubtask{}).Error
+		if err != nil {
+			return err
+		}
+		for _, subtask := range challenge.Subtasks {
+			subtask.ID = 0
+			subtask.ChallengeID = challenge.ID
+			err = tx.Create(subtask).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		// language limits are replaced as a whole
+		err = tx.Where(&entities.ChallengeLanguageLimit{ChallengeID: challenge.ID}).Delete(&entities.ChallengeLanguageLimit{}).Error
+		if err != nil {
+			return err
+		}
+		for _, limit := range challenge.LanguageLimits {
+			limit.ID = 0
+			limit.ChallengeID = challenge.ID
+			err = tx.Create(limit).Error
+			if err != nil {
+				return err
+			}
+		}
+
 		// limit testcases to 100 per challenge
 		var totalTestcases int64
 		err = tx.
@@ -141,7 +169,7 @@ LEFT JOIN (
 	SELECT
 		MAX(id) as id,
 		challenge_id,
-		MAX(status) as submission_status
+		MAX(CASE WHEN status IN ("CORRECT", "PENDING") THEN status ELSE "WRONG" END) as submission_status
 	FROM submissions
 	WHERE user_id = ?
 	GROUP BY challenge_id
@@ -214,7 +242,13 @@ func (r *challengeRepository) DeleteChallenge(challenge *entities.Challenge) err
 
 // FindChallengeByID implements ChallengeRepository.
 func (r *challengeRepository) FindChallengeByID(id uint) (challenge *entities.Challenge, err error) {
-	result := r.db.Preload("Testcases").First(&challenge, id)
+	result := r.db.
+		Preload("Testcases").
+		Preload("Subtasks", func(db *gorm.DB) *gorm.DB {
+			return db.Order("number ASC")
+		}).
+		Preload("LanguageLimits").
+		First(&challenge, id)
 	cleanActionFlag(challenge)
 	return challenge, result.Error
 }
@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"gorm.io/gorm"
+)
+
+type CodeRunRepository interface {
+	// CreateCodeRun creates a new code run.
+	CreateCodeRun(codeRun *entities.CodeRun) (*entities.CodeRun, error)
+	// UpdateCodeRun updates a code run.
+	UpdateCodeRun(codeRun *entities.CodeRun) (*entities.CodeRun, error)
+	// GetCodeRunByID returns a code run by given ID.
+	GetCodeRunByID(id uint) (*entities.CodeRun, error)
+}
+
+type codeRunRepository struct {
+	db *gorm.DB
+}
+
+// CreateCodeRun implements CodeRunRepository.
+func (r *codeRunRepository) CreateCodeRun(codeRun *entities.CodeRun) (*entities.CodeRun, error) {
+	result := r.db.Create(codeRun)
+	return codeRun, result.Error
+}
+
+// UpdateCodeRun implements CodeRunRepository.
+func (r *codeRunRepository) UpdateCodeRun(codeRun *entities.CodeRun) (*entities.CodeRun, error) {
+	result := r.db.Save(codeRun)
+	return codeRun, result.Error
+}
+
+// GetCodeRunByID implements CodeRunRepository.
+func (r *codeRunRepository) GetCodeRunByID(id uint) (*entities.CodeRun, error) {
+	var codeRun *entities.CodeRun
+	result := r.db.First(&codeRun, id)
+	return codeRun, result.Error
+}
+
+func NewCodeRunRepository(db *gorm.DB) CodeRunRepository {
+	return &codeRunRepository{db: db}
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"strings"
+
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DeadLetterJobRepository interface {
+	// CreateDeadLetterJob creates a new dead letter job.
+	CreateDeadLetterJob(job *entities.DeadLetterJob) (*entities.DeadLetterJob, error)
+	// UpdateDeadLetterJob updates a dead letter job.
+	UpdateDeadLetterJob(job *entities.DeadLetterJob) (*entities.DeadLetterJob, error)
+	// GetDeadLetterJobByID returns a dead letter job by given ID.
+	GetDeadLetterJobByID(id uint) (*entities.DeadLetterJob, error)
+	// Pagination returns a list of dead letter jobs by given page and limit,
+	// searching the topic, the trace ID and the error.
+	Pagination(options *entities.PaginationOptions) (result *entities.PaginationResult[*entities.DeadLetterJob], err error)
+}
+
+type deadLetterJobRepository struct {
+	db *gorm.DB
+}
+
+// CreateDeadLetterJob implements DeadLetterJobRepository.
+func (r *deadLetterJobRepository) CreateDeadLetterJob(job *entities.DeadLetterJob) (*entities.DeadLetterJob, error) {
+	result := r.db.Create(job)
+	return job, result.Error
+}
+
+// UpdateDeadLetterJob implements DeadLetterJobRepository.
+func (r *deadLetterJobRepository) UpdateDeadLetterJob(job *entities.DeadLetterJob) (*entities.DeadLetterJob, error) {
+	result := r.db.Save(job)
+	return job, result.Error
+}
+
+// GetDeadLetterJobByID implements DeadLetterJobRepository.
+func (r *deadLetterJobRepository) GetDeadLetterJobByID(id uint) (*entities.DeadLetterJob, error) {
+	var job *entities.DeadLetterJob
+	result := r.db.First(&job, id)
+	return job, result.Error
+}
+
+// Pagination implements DeadLetterJobRepository.
+func (r *deadLetterJobRepository) Pagination(options *entities.PaginationOptions) (result *entities.PaginationResult[*entities.DeadLetterJob], err error) {
+	offset := (options.Page - 1) * options.Limit
+	desc := strings.ToUpper(options.Order) == "DESC"
+	search := "%" + options.Search + "%"
+
+	findQuery := r.db.Model(&entities.DeadLetterJob{}).
+		Where("topic LIKE ? OR trace_id LIKE ? OR error LIKE ?", search, search, search).
+		Limit(options.Limit).
+		Offset(offset).
+		Order(clause.OrderByColumn{Column: clause.Column{Name: options.Sort}, Desc: desc})
+
+	var jobs []*entities.DeadLetterJob
+	if err := findQuery.Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+
+	var total int64
+	countQuery := r.db.Model(&entities.DeadLetterJob{}).
+		Where("topic LIKE ? OR trace_id LIKE ? OR error LIKE ?", search, search, search).
+		Count(&total)
+	if err := countQuery.Error; err != nil {
+		return nil, err
+	}
+
+	return &entities.PaginationResult[*entities.DeadLetterJob]{
+		Total: int(total),
+		Items: jobs,
+	}, nil
+}
+
+func NewDeadLetterJobRepository(db *gorm.DB) DeadLetterJobRepository {
+	return &deadLetterJobRepository{db: db}
+}
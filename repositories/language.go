@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"gorm.io/gorm"
+)
+
+type LanguageRepository interface {
+	// CreateLanguage creates a new language.
+	CreateLanguage(language *entities.Language) (*entities.Language, error)
+	// UpdateLanguage updates a language.
+	UpdateLanguage(language *entities.Language) (*entities.Language, error)
+	// GetLanguageByID returns a language by given ID.
+	GetLanguageByID(id uint) (*entities.Language, error)
+	// GetLanguageByName returns a language by given name, or nil when it does not exist.
+	GetLanguageByName(name string) (*entities.Language, error)
+	// AllLanguages returns every language ordered by name.
+	AllLanguages() ([]*entities.Language, error)
+	// EnabledLanguages returns every enabled language ordered by name.
+	EnabledLanguages() ([]*entities.Language, error)
+}
+
+type languageRepository struct {
+	db *gorm.DB
+}
+
+// CreateLanguage implements LanguageRepository.
+func (r *languageRepository) CreateLanguage(language *entities.Language) (*entities.Language, error) {
+	result := r.db.Create(language)
+	return language, result.Error
+}
+
+// UpdateLanguage implements LanguageRepository.
+func (r *languageRepository) UpdateLanguage(language *entities.Language) (*entities.Language, error) {
+	result := r.db.Save(language)
+	return language, result.Error
+}
+
+// GetLanguageByID implements LanguageRepository.
+func (r *languageRepository) GetLanguageByID(id uint) (*entities.Language, error) {
+	var language *entities.Language
+	result := r.db.First(&language, id)
+	return language, result.Error
+}
+
+// GetLanguageByName implements LanguageRepository.
+func (r *languageRepository) GetLanguageByName(name string) (*entities.Language, error) {
+	var languages []*entities.Language
+	result := r.db.Where("language = ?", name).Limit(1).Find(&languages)
+	if result.Error != nil || len(languages) == 0 {
+		return nil, result.Error
+	}
+	return languages[0], nil
+}
+
+// AllLanguages implements LanguageRepository.
+func (r *languageRepository) AllLanguages() ([]*entities.Language, error) {
+	var languages []*entities.Language
+	result := r.db.Order("language").Find(&languages)
+	return languages, result.Error
+}
+
+// EnabledLanguages implements LanguageRepository.
+func (r *languageRepository) EnabledLanguages() ([]*entities.Language, error) {
+	var languages []*entities.Language
+	result := r.db.Where("enabled = ?", true).Order("language").Find(&languages)
+	return languages, result.Error
+}
+
+func NewLanguageRepository(db *gorm.DB) LanguageRepository {
+	return &languageRepository{db: db}
+}
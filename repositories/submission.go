@@ -1,14 +1,20 @@
 package repositories
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/wuttinanhi/code-judge-system/entities"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrSubmissionStateChanged is returned when the stored submission is no longer in the
+// state it was changed from, e.g. because it was queued again while it was judged.
+var ErrSubmissionStateChanged = errors.New("submission state changed")
+
 type SubmissionRepository interface {
 	CreateSubmission(submission *entities.Submission) (*entities.Submission, error)
 	DeleteSubmission(submission *entities.Submission) error
@@ -20,6 +26,11 @@ type SubmissionRepository interface {
 	// CreateSubmissionWithTestcase(submission *entities.Submission, submissionTestcases []entities.SubmissionTestcase) (*entities.Submission, error)
 	UpdateSubmission(submission *entities.Submission) (*entities.Submission, error)
 	UpdateSubmissionTestcase(submissionTestcase *entities.SubmissionTestcase) (*entities.SubmissionTestcase, error)
+	ReplaceSubtaskResults(submission *entities.Submission, results []*entities.SubmissionSubtaskResult) error
+	GetSubmissionByChallengeTestcase(testcase *entities.ChallengeTestcase) ([]*entities.Submission, error)
+	ResetSubmission(submission *entities.Submission, from string, testcases []*entities.SubmissionTestcase, history *entities.SubmissionVerdictHistory) error
+	UpdateSubmissionState(submission *entities.Submission, from string) error
+	FinishSubmission(submission *entities.Submission, from string) error
 	Pagination(options *entities.SubmissionPaginationOptions) (result *entities.PaginationResult[*entities.Submission], err error)
 }
 
@@ -57,6 +68,7 @@ func (r *submissionRepository) Pagination(options *entities.SubmissionPagination
 		Model(&entities.Submission{}).
 		Preload("User").
 		Preload("SubmissionTestcases").
+		Preload("SubtaskResults").
 		Preload("Challenge").
 		Joins("LEFT JOIN users ON submissions.user_id = users.id").
 		Joins("LEFT JOIN challenges ON submissions.challenge_id = challenges.id").
@@ -116,8 +128,13 @@ func (r *submissionRepository) CreateSubmission(submission *entities.Submission)
 }
 
 // UpdateSubmissionTestcase implements SubmissionRepository.
+// Only an existing testcase is updated, so a judge that is still running after a rejudge
+// replaced the testcases cannot bring the old ones back.
 func (r *submissionRepository) UpdateSubmissionTestcase(submissionTestcase *entities.SubmissionTestcase) (*entities.SubmissionTestcase, error) {
-	result := r.db.Save(submissionTestcase)
+	result := r.db.Model(submissionTestcase).
+		Select("*").
+		Omit(clause.Associations).
+		Updates(submissionTestcase)
 	return submissionTestcase, result.Error
 }
 
@@ -127,6 +144,137 @@ func (r *submissionRepository) UpdateSubmission(submission *entities.Submission)
 	return submission, result.Error
 }
 
+// ReplaceSubtaskResults implements SubmissionRepository.
+func (r *submissionRepository) ReplaceSubtaskResults(submission *entities.Submission, results []*entities.SubmissionSubtaskResult) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return replaceSubtaskResults(tx, submission, results)
+	})
+}
+
+func replaceSubtaskResults(tx *gorm.DB, submission *entities.Submission, results []*entities.SubmissionSubtaskResult) error {
+	err := tx.
+		Where(&entities.SubmissionSubtaskResult{SubmissionID: submission.ID}).
+		Delete(&entities.SubmissionSubtaskResult{}).Error
+	if err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		result.ID = 0
+		result.SubmissionID = submission.ID
+		if err := tx.Create(result).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateSubmissionState implements SubmissionRepository.
+// The state is only changed while the stored state is still from.
+func (r *submissionRepository) UpdateSubmissionState(submission *entities.Submission, from string) error {
+	result := r.db.Model(submission).
+		Where("state = ?", from).
+		Select("state", "queued_at", "started_at", "finished_at", "judged_by").
+		Updates(submission)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSubmissionStateChanged
+	}
+	return nil
+}
+
+// FinishSubmission implements SubmissionRepository.
+// The submission and its subtask results are saved together, only while the stored state
+// is still from, so a judge that was too slow does not overwrite a queued submission.
+func (r *submissionRepository) FinishSubmission(submission *entities.Submission, from string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(submission).
+			Where("state = ?", from).
+			Select("*").
+			Omit(clause.Associations).
+			Updates(submission)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSubmissionStateChanged
+		}
+
+		return replaceSubtaskResults(tx, submission, submission.SubtaskResults)
+	})
+}
+
+// GetSubmissionByChallengeTestcase implements SubmissionRepository.
+func (r *submissionRepository) GetSubmissionByChallengeTestcase(testcase *entities.ChallengeTestcase) ([]*entities.Submission, error) {
+	var submissions []*entities.Submission
+	result := r.db.
+		Where("id IN (?)", r.db.
+			Model(&entities.SubmissionTestcase{}).
+			Select("submission_id").
+			Where(&entities.SubmissionTestcase{ChallengeTestcaseID: testcase.ID}),
+		).
+		Find(&submissions)
+	return submissions, result.Error
+}
+
+// ResetSubmission implements SubmissionRepository.
+// The previous result is stored as history and the testcases are replaced in one transaction,
+// only while the stored state is still from.
+func (r *submissionRepository) ResetSubmission(submission *entities.Submission, from string, testcases []*entities.SubmissionTestcase, history *entities.SubmissionVerdictHistory) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(submission).
+			Where("state = ?", from).
+			Select("*").
+			Omit(clause.Associations).
+			Updates(submission)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSubmissionStateChanged
+		}
+
+		history.SubmissionID = submission.ID
+		if err := tx.Create(history).Error; err != nil {
+			return err
+		}
+
+		err := tx.
+			Where(&entities.SubmissionTestcase{SubmissionID: submission.ID}).
+			Delete(&entities.SubmissionTestcase{}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.
+			Where(&entities.SubmissionSubtaskResult{SubmissionID: submission.ID}).
+			Delete(&entities.SubmissionSubtaskResult{}).Error
+		if err != nil {
+			return err
+		}
+
+		for _, testcase := range testcases {
+			testcase.SubmissionID = submission.ID
+			if err := tx.Create(testcase).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	submission.SubmissionTestcases = testcases
+	submission.SubtaskResults = nil
+
+	return nil
+}
+
 // CreateSubmissionWithTestcase implements SubmissionRepository.
 func (r *submissionRepository) CreateSubmissionWithTestcase(submission *entities.Submission, testcaes []entities.SubmissionTestcase) (*entities.Submission, error) {
 	result := r.db.Transaction(func(tx *gorm.DB) error {
@@ -173,6 +321,8 @@ func (r *submissionRepository) GetSubmissionByID(submissionID uint) (*entities.S
 		Preload("Challenge").
 		Preload("SubmissionTestcases").
 		Preload("SubmissionTestcases.ChallengeTestcase").
+		Preload("SubtaskResults").
+		Preload("VerdictHistory").
 		Find(&submission, submissionID)
 	return submission, result.Error
 }
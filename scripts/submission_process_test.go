@@ -1,6 +1,7 @@
 package scripts_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/wuttinanhi/code-judge-system/configs"
@@ -23,5 +24,5 @@ func TestSubmissionProcess(t *testing.T) {
 	// fmt.Println(submission.ID)
 	// fmt.Println(len(submission.SubmissionTestcases))
 
-	testServiceKit.SubmissionService.ProcessSubmission(submission)
+	testServiceKit.SubmissionService.ProcessSubmission(context.Background(), submission)
 }
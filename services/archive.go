@@ -0,0 +1,87 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+)
+
+// createArchive creates a tar archive of files keyed by their path relative to
+// the directory the archive is extracted into.
+func createArchive(files map[string]string) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range names {
+		err := tw.WriteHeader(&tar.Header{
+			Name:   name,
+			Mode:   0644,
+			Size:   int64(len(files[name])),
+			Format: tar.FormatGNU,
+		})
+		if err != nil {
+			return nil, err
+		}
+		_, err = tw.Write([]byte(files[name]))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := tw.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// stripArchivePrefix rewrites a tar archive so its entries are relative to dir,
+// e.g. an archive of /sandbox copied from a container has every entry under "sandbox/".
+func stripArchivePrefix(archive []byte, dir string) ([]byte, error) {
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+
+	var buf bytes.Buffer
+	tr := tar.NewReader(bytes.NewReader(archive))
+	tw := tar.NewWriter(&buf)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := strings.TrimPrefix(header.Name, prefix)
+		if name == header.Name || name == "" {
+			// skip the directory itself
+			continue
+		}
+		header.Name = name
+		if header.Typeflag == tar.TypeLink {
+			header.Linkname = strings.TrimPrefix(header.Linkname, prefix)
+		}
+
+		err = tw.WriteHeader(header)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(tw, tr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := tw.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -22,6 +22,10 @@ type ChallengeService interface {
 	UpdateChallengeWithTestcase(challenge *entities.Challenge) (err error)
 	CountAllChallengesByUser(user *entities.User) (total int64, err error)
 	ValidateTestcases(testcases []*entities.ChallengeTestcase) (err error)
+	ValidateChecker(challenge *entities.Challenge) (err error)
+	ValidateInteractor(challenge *entities.Challenge) (err error)
+	ValidateSubtasks(challenge *entities.Challenge) (err error)
+	ValidateLanguageLimits(challenge *entities.Challenge) (err error)
 }
 
 type challengeService struct {
@@ -48,6 +52,99 @@ func (s *challengeService) ValidateTestcases(testcases []*entities.ChallengeTest
 	return
 }
 
+// ValidateChecker implements ChallengeService.
+func (s *challengeService) ValidateChecker(challenge *entities.Challenge) (err error) {
+	switch challenge.Checker {
+	case "", entities.ChallengeCheckerExact, entities.ChallengeCheckerToken, entities.ChallengeCheckerLine:
+		return nil
+	case entities.ChallengeCheckerFloat:
+		if challenge.CheckerAbsEpsilon < 0 || challenge.CheckerRelEpsilon < 0 {
+			return fmt.Errorf("checker epsilon must not be negative")
+		}
+		return nil
+	case entities.ChallengeCheckerCustom:
+		if challenge.CheckerCode == "" {
+			return fmt.Errorf("custom checker code is required")
+		}
+		if s.sandboxService.ValidateLanguage(challenge.CheckerLanguage) != nil {
+			return fmt.Errorf("checker language %s not supported", challenge.CheckerLanguage)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("checker %s not supported", challenge.Checker)
+}
+
+// ValidateInteractor implements ChallengeService.
+func (s *challengeService) ValidateInteractor(challenge *entities.Challenge) (err error) {
+	switch challenge.Type {
+	case "", entities.ChallengeTypeStandard:
+		return nil
+	case entities.ChallengeTypeInteractive:
+		if challenge.InteractorCode == "" {
+			return fmt.Errorf("interactor code is required")
+		}
+		if s.sandboxService.ValidateLanguage(challenge.InteractorLanguage) != nil {
+			return fmt.Errorf("interactor language %s not supported", challenge.InteractorLanguage)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("challenge type %s not supported", challenge.Type)
+}
+
+// ValidateSubtasks implements ChallengeService.
+func (s *challengeService) ValidateSubtasks(challenge *entities.Challenge) (err error) {
+	subtaskNumbers := make(map[uint]bool)
+	for _, subtask := range challenge.Subtasks {
+		if subtask.Number == 0 {
+			return fmt.Errorf("subtask number must start at 1")
+		}
+		if subtaskNumbers[subtask.Number] {
+			return fmt.Errorf("subtask #%d: duplicate subtask number", subtask.Number)
+		}
+		if subtask.Points < 0 {
+			return fmt.Errorf("subtask #%d: points must not be negative", subtask.Number)
+		}
+		switch subtask.ScoringRule {
+		case "", entities.ChallengeSubtaskScoringAll, entities.ChallengeSubtaskScoringProportional:
+		default:
+			return fmt.Errorf("subtask #%d: scoring rule %s not supported", subtask.Number, subtask.ScoringRule)
+		}
+		subtaskNumbers[subtask.Number] = true
+	}
+
+	for _, testcase := range challenge.Testcases {
+		if testcase.ActionFlag == "delete" || testcase.Subtask == 0 {
+			continue
+		}
+		if !subtaskNumbers[testcase.Subtask] {
+			return fmt.Errorf("testcase #%d: subtask %d does not exist", testcase.ID, testcase.Subtask)
+		}
+	}
+
+	return nil
+}
+
+// ValidateLanguageLimits implements ChallengeService.
+func (s *challengeService) ValidateLanguageLimits(challenge *entities.Challenge) (err error) {
+	languages := make(map[string]bool)
+	for _, limit := range challenge.LanguageLimits {
+		if languages[limit.Language] {
+			return fmt.Errorf("language limit %s: duplicate language", limit.Language)
+		}
+		if s.sandboxService.ValidateLanguage(limit.Language) != nil {
+			return fmt.Errorf("language limit %s: language not supported", limit.Language)
+		}
+		if limit.TimeMultiplier < 0 || limit.MemoryMultiplier < 0 {
+			return fmt.Errorf("language limit %s: multipliers must not be negative", limit.Language)
+		}
+		languages[limit.Language] = true
+	}
+
+	return nil
+}
+
 // CountAllChallengesByUser implements ChallengeService.
 func (s *challengeService) CountAllChallengesByUser(user *entities.User) (total int64, err error) {
 	total, err = s.challengeRepo.CountAllChallengesByUser(user)
@@ -60,6 +157,22 @@ func (s *challengeService) UpdateChallengeWithTestcase(challenge *entities.Chall
 	if err != nil {
 		return err
 	}
+	err = s.ValidateChecker(challenge)
+	if err != nil {
+		return err
+	}
+	err = s.ValidateInteractor(challenge)
+	if err != nil {
+		return err
+	}
+	err = s.ValidateSubtasks(challenge)
+	if err != nil {
+		return err
+	}
+	err = s.ValidateLanguageLimits(challenge)
+	if err != nil {
+		return err
+	}
 	err = s.challengeRepo.UpdateChallengeWithTestcase(challenge)
 	return
 }
@@ -76,6 +189,22 @@ func (s *challengeService) CreateChallenge(challenge *entities.Challenge) (*enti
 	if err != nil {
 		return nil, err
 	}
+	err = s.ValidateChecker(challenge)
+	if err != nil {
+		return nil, err
+	}
+	err = s.ValidateInteractor(challenge)
+	if err != nil {
+		return nil, err
+	}
+	err = s.ValidateSubtasks(challenge)
+	if err != nil {
+		return nil, err
+	}
+	err = s.ValidateLanguageLimits(challenge)
+	if err != nil {
+		return nil, err
+	}
 	challenge, err = s.challengeRepo.CreateChallenge(challenge)
 	return challenge, err
 }
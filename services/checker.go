@@ -0,0 +1,232 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/wuttinanhi/code-judge-system/entities"
+)
+
+// Checker decides whether the output of a testcase run is correct.
+type Checker interface {
+	Check(input, expectedOutput, output string) (status string, note string)
+	CleanUp() error
+}
+
+type exactChecker struct{}
+
+// Check implements Checker.
+func (c *exactChecker) Check(input, expectedOutput, output string) (string, string) {
+	if output != expectedOutput {
+		return entities.SubmissionStatusWrong, ""
+	}
+	return entities.SubmissionStatusCorrect, ""
+}
+
+// CleanUp implements Checker.
+func (c *exactChecker) CleanUp() error {
+	return nil
+}
+
+type tokenChecker struct{}
+
+// Check implements Checker.
+func (c *tokenChecker) Check(input, expectedOutput, output string) (string, string) {
+	expectedTokens := strings.Fields(expectedOutput)
+	outputTokens := strings.Fields(output)
+
+	if len(expectedTokens) != len(outputTokens) {
+		return entities.SubmissionStatusWrong, fmt.Sprintf("expected %d tokens, got %d", len(expectedTokens), len(outputTokens))
+	}
+
+	for i := range expectedTokens {
+		if expectedTokens[i] != outputTokens[i] {
+			return entities.SubmissionStatusWrong, fmt.Sprintf("token #%d differs", i+1)
+		}
+	}
+
+	return entities.SubmissionStatusCorrect, ""
+}
+
+// CleanUp implements Checker.
+func (c *tokenChecker) CleanUp() error {
+	return nil
+}
+
+type lineChecker struct{}
+
+// splitLines splits output into lines without trailing spaces and drops trailing empty lines.
+func splitLines(output string) []string {
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Check implements Checker.
+func (c *lineChecker) Check(input, expectedOutput, output string) (string, string) {
+	expectedLines := splitLines(expectedOutput)
+	outputLines := splitLines(output)
+
+	if len(expectedLines) != len(outputLines) {
+		return entities.SubmissionStatusWrong, fmt.Sprintf("expected %d lines, got %d", len(expectedLines), len(outputLines))
+	}
+
+	for i := range expectedLines {
+		if expectedLines[i] != outputLines[i] {
+			return entities.SubmissionStatusWrong, fmt.Sprintf("line #%d differs", i+1)
+		}
+	}
+
+	return entities.SubmissionStatusCorrect, ""
+}
+
+// CleanUp implements Checker.
+func (c *lineChecker) CleanUp() error {
+	return nil
+}
+
+type floatChecker struct {
+	absEpsilon float64
+	relEpsilon float64
+}
+
+// Check implements Checker.
+// Tokens that are not numbers on both sides are compared as strings.
+func (c *floatChecker) Check(input, expectedOutput, output string) (string, string) {
+	expectedTokens := strings.Fields(expectedOutput)
+	outputTokens := strings.Fields(output)
+
+	if len(expectedTokens) != len(outputTokens) {
+		return entities.SubmissionStatusWrong, fmt.Sprintf("expected %d tokens, got %d", len(expectedTokens), len(outputTokens))
+	}
+
+	for i := range expectedTokens {
+		expected, expectedErr := strconv.ParseFloat(expectedTokens[i], 64)
+		actual, actualErr := strconv.ParseFloat(outputTokens[i], 64)
+
+		if expectedErr != nil || actualErr != nil {
+			if expectedTokens[i] != outputTokens[i] {
+				return entities.SubmissionStatusWrong, fmt.Sprintf("token #%d differs", i+1)
+			}
+			continue
+		}
+
+		diff := math.Abs(expected - actual)
+		if diff <= c.absEpsilon || diff <= c.relEpsilon*math.Abs(expected) {
+			continue
+		}
+
+		return entities.SubmissionStatusWrong, fmt.Sprintf("token #%d differs by %g", i+1, diff)
+	}
+
+	return entities.SubmissionStatusCorrect, ""
+}
+
+// CleanUp implements Checker.
+func (c *floatChecker) CleanUp() error {
+	return nil
+}
+
+type customChecker struct {
+	sandboxService SandboxService
+	sandbox        *entities.SandboxInstance
+}
+
+// Check implements Checker.
+// Exit codes follow testlib: 0 is OK, 1 is wrong answer, 2 is presentation error
+// and everything else is treated as a checker failure.
+func (c *customChecker) Check(input, expectedOutput, output string) (string, string) {
+	result := c.sandboxService.RunChecker(
+		c.sandbox,
+		input,
+		output,
+		expectedOutput,
+		entities.SandboxCheckerMemoryLimit,
+		entities.SandboxCheckerTimeLimitMs,
+	)
+	if result.Err != nil {
+		return entities.SubmissionStatusSystemError, "checker: " + result.Err.Error()
+	}
+	if result.Timeout || result.OOMKilled || result.OutputLimitExceeded {
+		return entities.SubmissionStatusSystemError, "checker: exceeded resource limit"
+	}
+
+	note := entities.TruncateOutput(strings.TrimSpace(result.Stdout+result.Stderr), 1024)
+
+	switch result.ExitCode {
+	case 0:
+		return entities.SubmissionStatusCorrect, note
+	case 1, 2:
+		return entities.SubmissionStatusWrong, note
+	}
+
+	return entities.SubmissionStatusSystemError, fmt.Sprintf("checker: exit code %d", result.ExitCode)
+}
+
+// CleanUp implements Checker.
+func (c *customChecker) CleanUp() error {
+	return c.sandboxService.CleanUp(c.sandbox)
+}
+
+func NewExactChecker() Checker {
+	return &exactChecker{}
+}
+
+func NewTokenChecker() Checker {
+	return &tokenChecker{}
+}
+
+func NewLineChecker() Checker {
+	return &lineChecker{}
+}
+
+func NewFloatChecker(absEpsilon, relEpsilon float64) Checker {
+	if absEpsilon == 0 && relEpsilon == 0 {
+		absEpsilon = entities.ChallengeCheckerDefaultEpsilon
+		relEpsilon = entities.ChallengeCheckerDefaultEpsilon
+	}
+
+	return &floatChecker{
+		absEpsilon: absEpsilon,
+		relEpsilon: relEpsilon,
+	}
+}
+
+// NewCustomChecker compiles the checker program in its own sandbox.
+func NewCustomChecker(sandboxService SandboxService, language, code string) (Checker, error) {
+	sandbox, err := createCompiledSandbox(sandboxService, language, code)
+	if err != nil {
+		return nil, errors.New("failed to compile checker: " + err.Error())
+	}
+
+	return &customChecker{
+		sandboxService: sandboxService,
+		sandbox:        sandbox,
+	}, nil
+}
+
+// NewChallengeChecker returns the checker configured on the challenge.
+func NewChallengeChecker(challenge *entities.Challenge, sandboxService SandboxService) (Checker, error) {
+	switch challenge.Checker {
+	case "", entities.ChallengeCheckerExact:
+		return NewExactChecker(), nil
+	case entities.ChallengeCheckerToken:
+		return NewTokenChecker(), nil
+	case entities.ChallengeCheckerLine:
+		return NewLineChecker(), nil
+	case entities.ChallengeCheckerFloat:
+		return NewFloatChecker(challenge.CheckerAbsEpsilon, challenge.CheckerRelEpsilon), nil
+	case entities.ChallengeCheckerCustom:
+		return NewCustomChecker(sandboxService, challenge.CheckerLanguage, challenge.CheckerCode)
+	}
+
+	return nil, fmt.Errorf("checker %s not supported", challenge.Checker)
+}
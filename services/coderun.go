@@ -0,0 +1,93 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/repositories"
+)
+
+type CodeRunService interface {
+	CreateCodeRun(codeRun *entities.CodeRun) (*entities.CodeRun, error)
+	GetCodeRunByID(id uint) (*entities.CodeRun, error)
+	ProcessCodeRun(codeRun *entities.CodeRun) (*entities.CodeRun, error)
+}
+
+type codeRunService struct {
+	codeRunRepository repositories.CodeRunRepository
+	sandboxService    SandboxService
+}
+
+// CreateCodeRun implements CodeRunService.
+func (s *codeRunService) CreateCodeRun(codeRun *entities.CodeRun) (*entities.CodeRun, error) {
+	err := s.sandboxService.ValidateLanguage(codeRun.Language)
+	if err != nil {
+		return nil, err
+	}
+
+	codeRun.Status = entities.SubmissionStatusPending
+	return s.codeRunRepository.CreateCodeRun(codeRun)
+}
+
+// GetCodeRunByID implements CodeRunService.
+func (s *codeRunService) GetCodeRunByID(id uint) (*entities.CodeRun, error) {
+	return s.codeRunRepository.GetCodeRunByID(id)
+}
+
+// ProcessCodeRun implements CodeRunService.
+func (s *codeRunService) ProcessCodeRun(codeRun *entities.CodeRun) (*entities.CodeRun, error) {
+	sandbox, err := s.sandboxService.CreateSandbox(codeRun.Language, codeRun.Code)
+	if err != nil {
+		codeRun.Status = entities.SubmissionStatusSystemError
+		s.codeRunRepository.UpdateCodeRun(codeRun)
+		return nil, errors.New("failed to create sandbox")
+	}
+	defer s.sandboxService.CleanUp(sandbox)
+
+	compile := s.sandboxService.CompileSandbox(sandbox)
+
+	codeRun.CompileStdout = entities.TruncateOutput(sandbox.CompileStdout, entities.SandboxCompileOutputLimit)
+	codeRun.CompileStderr = entities.TruncateOutput(sandbox.CompileStderr, entities.SandboxCompileOutputLimit)
+
+	if compile.Err != nil {
+		// like a submission, too much compiler output is the user's fault
+		if compile.Timeout || compile.OutputLimitExceeded || compile.ExitCode != 0 {
+			codeRun.Status = entities.SubmissionStatusCompilationError
+		} else {
+			codeRun.Status = entities.SubmissionStatusSystemError
+		}
+		return s.codeRunRepository.UpdateCodeRun(codeRun)
+	}
+
+	result := s.sandboxService.Run(sandbox, codeRun.Stdin, entities.CodeRunMemoryLimit, entities.CodeRunTimeLimitMs)
+
+	codeRun.Stdout = entities.TruncateOutput(result.Stdout, entities.CodeRunOutputLimit)
+	codeRun.Stderr = entities.TruncateOutput(result.Stderr, entities.CodeRunOutputLimit)
+	codeRun.ExitCode = result.ExitCode
+	codeRun.TimeMs = result.TimeMs
+	codeRun.MemoryUsage = result.MemoryUsage
+
+	switch {
+	case result.Err != nil:
+		codeRun.Status = entities.SubmissionStatusSystemError
+	case result.Timeout:
+		codeRun.Status = entities.SubmissionStatusTimeLimitExceeded
+	case result.OOMKilled:
+		codeRun.Status = entities.SubmissionStatusMemoryLimitExceeded
+	case result.OutputLimitExceeded:
+		codeRun.Status = entities.SubmissionStatusOutputLimitExceeded
+	case result.ExitCode != 0:
+		codeRun.Status = entities.SubmissionStatusRuntimeError
+	default:
+		codeRun.Status = entities.CodeRunStatusCompleted
+	}
+
+	return s.codeRunRepository.UpdateCodeRun(codeRun)
+}
+
+func NewCodeRunService(codeRunRepository repositories.CodeRunRepository, sandboxService SandboxService) CodeRunService {
+	return &codeRunService{
+		codeRunRepository: codeRunRepository,
+		sandboxService:    sandboxService,
+	}
+}
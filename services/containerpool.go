@@ -0,0 +1,313 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/wuttinanhi/code-judge-system/entities"
+)
+
+// ContainerPoolConfig configures the warm container pool.
+type ContainerPoolConfig struct {
+	// Size is the number of idle containers kept warm per image, zero disables the pool.
+	Size int
+	// MaxUses is the number of runs a container serves before it is replaced, zero means no limit.
+	// The peak memory of a run is that of the container, so it is only exact with one use.
+	MaxUses int
+	// MaxAge is how long a container is kept before it is replaced, zero means no limit.
+	MaxAge time.Duration
+	// HealthInterval is how often idle containers are checked, zero disables the checks.
+	HealthInterval time.Duration
+}
+
+// PooledContainer is a started container of a ContainerPool.
+// Programs are copied into its /sandbox and /stdin volumes and run with exec.
+type PooledContainer struct {
+	ID        string
+	Image     string
+	Uses      int
+	CreatedAt time.Time
+}
+
+// ContainerPool keeps started sandbox containers per image, so testcases run without
+// waiting for a container to be created and started.
+type ContainerPool interface {
+	Warm(image string)
+	Acquire(image string) (*PooledContainer, error)
+	Release(container *PooledContainer)
+	Close() error
+}
+
+// containerPoolResetTimeoutMs is how long resetting or checking a container may take.
+const containerPoolResetTimeoutMs = 5000
+
+type containerPool struct {
+	dockerService DockerService
+	config        ContainerPoolConfig
+	mutex         sync.Mutex
+	idle          map[string][]*PooledContainer
+	creating      map[string]int
+	closed        bool
+	healthOnce    sync.Once
+}
+
+// Warm implements ContainerPool.
+// It creates containers in the background until the image has Size idle containers.
+func (p *containerPool) Warm(image string) {
+	p.healthOnce.Do(func() {
+		if p.config.HealthInterval > 0 {
+			go p.checkHealthLoop()
+		}
+	})
+
+	p.mutex.Lock()
+	missing := p.config.Size - len(p.idle[image]) - p.creating[image]
+	if p.closed || missing <= 0 {
+		p.mutex.Unlock()
+		return
+	}
+	p.creating[image] += missing
+	p.mutex.Unlock()
+
+	for i := 0; i < missing; i++ {
+		go func() {
+			container, err := p.create(image)
+
+			p.mutex.Lock()
+			p.creating[image]--
+			p.mutex.Unlock()
+
+			if err != nil {
+				log.Println("failed to warm container of image", image, "with error:", err)
+				return
+			}
+			p.putIdle(container)
+		}()
+	}
+}
+
+// Acquire implements ContainerPool.
+// An idle container is handed out when there is one, otherwise a new one is created.
+func (p *containerPool) Acquire(image string) (*PooledContainer, error) {
+	var container *PooledContainer
+
+	p.mutex.Lock()
+	if idle := p.idle[image]; len(idle) > 0 {
+		container = idle[len(idle)-1]
+		p.idle[image] = idle[:len(idle)-1]
+	}
+	p.mutex.Unlock()
+
+	// replace the handed out container in the background
+	p.Warm(image)
+
+	if container == nil {
+		var err error
+		container, err = p.create(image)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	container.Uses++
+	return container, nil
+}
+
+// Release implements ContainerPool.
+// The container is reset and returned to the pool in the background, or removed when
+// it reached its maximum uses or age.
+func (p *containerPool) Release(container *PooledContainer) {
+	go func() {
+		if p.expired(container) {
+			p.remove(container)
+			p.Warm(container.Image)
+			return
+		}
+
+		err := p.reset(container)
+		if err != nil {
+			log.Println("failed to reset container", container.ID, "with error:", err)
+			p.remove(container)
+			p.Warm(container.Image)
+			return
+		}
+
+		p.putIdle(container)
+	}()
+}
+
+// Close implements ContainerPool.
+// Idle containers are removed, containers in use are removed when they are released.
+func (p *containerPool) Close() error {
+	p.mutex.Lock()
+	p.closed = true
+	idle := p.idle
+	p.idle = map[string][]*PooledContainer{}
+	p.mutex.Unlock()
+
+	var errs []error
+	for _, containers := range idle {
+		for _, container := range containers {
+			errs = append(errs, p.dockerService.RemoveContainer(container.ID))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// create starts a container that idles until programs are run in it with exec.
+// The container runs as root so killing every process of SandboxUser after a run keeps
+// it alive, and its init process reaps the killed processes.
+func (p *containerPool) create(image string) (*PooledContainer, error) {
+	labels := entities.SandboxLabels("", 0, time.Now())
+	labels[entities.SandboxLabelPool] = "true"
+
+	resp, err := p.dockerService.CreateContainer(ContainerConfig{
+		Name:    fmt.Sprintf("code-judge-system-pool-%s", generateID()),
+		Image:   image,
+		Command: []string{"sleep", "infinity"},
+		Mounts: []mount.Mount{
+			{Type: mount.TypeVolume, Target: "/sandbox"},
+			{Type: mount.TypeVolume, Target: "/stdin"},
+		},
+		MemoryLimit: int64(entities.SandboxCheckerMemoryLimit),
+		Limits:      entities.SandboxRunLimits,
+		User:        "0:0",
+		Init:        true,
+		Labels:      labels,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.dockerService.StartContainer(resp.ID)
+	if err != nil {
+		p.dockerService.RemoveContainer(resp.ID)
+		return nil, err
+	}
+
+	return &PooledContainer{
+		ID:        resp.ID,
+		Image:     image,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// reset kills the processes left by the last run and removes its files,
+// so nothing of one program is visible to the next.
+func (p *containerPool) reset(container *PooledContainer) error {
+	result, err := p.dockerService.ExecContainer(
+		container.ID,
+		entities.SandboxUser,
+		[]string{"/bin/sh", "-c", "kill -9 -1; rm -rf /tmp/* /tmp/.[!.]*"},
+		containerPoolResetTimeoutMs,
+		entities.SandboxCompileOutputLimit,
+	)
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 || result.Timeout {
+		return fmt.Errorf("failed to clean /tmp: %s", result.Stderr)
+	}
+
+	// the copied files are owned by root
+	result, err = p.dockerService.ExecContainer(
+		container.ID,
+		"0:0",
+		[]string{"/bin/sh", "-c", "rm -rf /sandbox/* /sandbox/.[!.]* /stdin/* /stdin/.[!.]*"},
+		containerPoolResetTimeoutMs,
+		entities.SandboxCompileOutputLimit,
+	)
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 || result.Timeout {
+		return fmt.Errorf("failed to clean volumes: %s", result.Stderr)
+	}
+
+	return nil
+}
+
+// healthy reports whether a command can still be run in the container.
+func (p *containerPool) healthy(container *PooledContainer) bool {
+	result, err := p.dockerService.ExecContainer(
+		container.ID,
+		entities.SandboxUser,
+		[]string{"true"},
+		containerPoolResetTimeoutMs,
+		entities.SandboxCompileOutputLimit,
+	)
+	return err == nil && result.ExitCode == 0 && !result.Timeout
+}
+
+// expired reports whether the container reached its maximum uses or age.
+func (p *containerPool) expired(container *PooledContainer) bool {
+	if p.config.MaxUses > 0 && container.Uses >= p.config.MaxUses {
+		return true
+	}
+	if p.config.MaxAge > 0 && time.Since(container.CreatedAt) >= p.config.MaxAge {
+		return true
+	}
+	return false
+}
+
+// putIdle returns the container to the pool, or removes it when the pool is full or closed.
+func (p *containerPool) putIdle(container *PooledContainer) {
+	p.mutex.Lock()
+	if p.closed || len(p.idle[container.Image]) >= p.config.Size {
+		p.mutex.Unlock()
+		p.remove(container)
+		return
+	}
+	p.idle[container.Image] = append(p.idle[container.Image], container)
+	p.mutex.Unlock()
+}
+
+func (p *containerPool) remove(container *PooledContainer) {
+	err := p.dockerService.RemoveContainer(container.ID)
+	if err != nil {
+		log.Println("failed to remove container", container.ID, "with error:", err)
+	}
+}
+
+// checkHealthLoop replaces idle containers that expired or stopped responding.
+func (p *containerPool) checkHealthLoop() {
+	ticker := time.NewTicker(p.config.HealthInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		// take the idle containers out so they are not handed out while checked
+		p.mutex.Lock()
+		if p.closed {
+			p.mutex.Unlock()
+			return
+		}
+		idle := p.idle
+		p.idle = map[string][]*PooledContainer{}
+		p.mutex.Unlock()
+
+		for image, containers := range idle {
+			for _, container := range containers {
+				if p.expired(container) || !p.healthy(container) {
+					log.Println("replacing pooled container", container.ID)
+					p.remove(container)
+					continue
+				}
+				p.putIdle(container)
+			}
+			p.Warm(image)
+		}
+	}
+}
+
+func NewContainerPool(dockerService DockerService, config ContainerPoolConfig) ContainerPool {
+	return &containerPool{
+		dockerService: dockerService,
+		config:        config,
+		idle:          map[string][]*PooledContainer{},
+		creating:      map[string]int{},
+	}
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/repositories"
+)
+
+type DeadLetterService interface {
+	DeadLetter(deadLetterTopic string, job *entities.DeadLetterJob) (*entities.DeadLetterJob, error)
+	GetDeadLetterJobByID(id uint) (*entities.DeadLetterJob, error)
+	Pagination(options *entities.PaginationOptions) (result *entities.PaginationResult[*entities.DeadLetterJob], err error)
+	Replay(job *entities.DeadLetterJob) (*entities.DeadLetterJob, error)
+}
+
+type deadLetterService struct {
+	deadLetterJobRepository repositories.DeadLetterJobRepository
+	kafkaService            KafkaService
+}
+
+// DeadLetter implements DeadLetterService.
+// The message is produced to the dead letter topic for other tools and stored for the admin API.
+func (s *deadLetterService) DeadLetter(deadLetterTopic string, job *entities.DeadLetterJob) (*entities.DeadLetterJob, error) {
+	err := s.kafkaService.Produce(deadLetterTopic, job.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.deadLetterJobRepository.CreateDeadLetterJob(job)
+}
+
+// GetDeadLetterJobByID implements DeadLetterService.
+func (s *deadLetterService) GetDeadLetterJobByID(id uint) (*entities.DeadLetterJob, error) {
+	return s.deadLetterJobRepository.GetDeadLetterJobByID(id)
+}
+
+// Pagination implements DeadLetterService.
+func (s *deadLetterService) Pagination(options *entities.PaginationOptions) (result *entities.PaginationResult[*entities.DeadLetterJob], err error) {
+	return s.deadLetterJobRepository.Pagination(options)
+}
+
+// Replay implements DeadLetterService.
+// The job is produced to its topic again as a first attempt. Messages that are not a job
+// can not be replayed.
+func (s *deadLetterService) Replay(job *entities.DeadLetterJob) (*entities.DeadLetterJob, error) {
+	if job.ReplayedAt != nil {
+		return nil, errors.New("job was replayed already")
+	}
+
+	envelope, err := entities.ParseJobEnvelope(job.Message, job.JobType)
+	if err != nil {
+		return nil, fmt.Errorf("job can not be replayed: %w", err)
+	}
+
+	// a legacy message is replayed as an envelope
+	if envelope.Version == 0 {
+		envelope = entities.NewJobEnvelope(job.JobType, envelope.ID)
+	}
+	envelope.Attempt = 1
+	envelope.RetryAt = nil
+	envelope.LastError = ""
+
+	err = s.kafkaService.ProduceJob(job.Topic, envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	job.ReplayedAt = &now
+	return s.deadLetterJobRepository.UpdateDeadLetterJob(job)
+}
+
+func NewDeadLetterService(deadLetterJobRepository repositories.DeadLetterJobRepository, kafkaService KafkaService) DeadLetterService {
+	return &deadLetterService{
+		deadLetterJobRepository: deadLetterJobRepository,
+		kafkaService:            kafkaService,
+	}
+}
@@ -4,34 +4,79 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	units "github.com/docker/go-units"
+	"github.com/wuttinanhi/code-judge-system/entities"
 )
 
 type DockerService interface {
 	PullImage(imageName string) error
 	ImageExist(imageName string) (bool, error)
-	GetLog(containerID string, showStdout, showStderr bool) (string, error)
+	CaptureLog(containerID string, limit int) (stdout, stderr string, exceeded bool, err error)
 	GetContainerExitCode(containerID string) (int, error)
-	CreateVolume(name string) (volume.Volume, error)
+	GetContainerState(containerID string) (*types.ContainerState, error)
+	CreateVolume(name string, labels map[string]string) (volume.Volume, error)
 	DeleteVolume(v volume.Volume) error
+	ListVolumes(label string) ([]*volume.Volume, error)
 	CopyToContainer(containerID, targetPath string, content []byte) error
-	CreateContainer(imageName string, command []string, volumes []mount.Mount, memoryLimit int64, containerName string) (response container.CreateResponse, err error)
+	CopyArchiveToContainer(containerID, targetDir string, archive []byte) error
+	CopyFromContainer(containerID, sourcePath string) (archive []byte, err error)
+	CreateContainer(config ContainerConfig) (response container.CreateResponse, err error)
+	AttachContainer(containerID string) (types.HijackedResponse, error)
 	StartContainer(containerID string) error
 	StopContainer(containerID string) error
+	UpdateContainerMemory(containerID string, memoryLimit int64) error
+	ExecContainer(containerID, user string, command []string, timeout uint, outputLimit int) (*ExecResult, error)
 	RemoveContainer(containerID string) error
+	ListContainers(label string) ([]types.Container, error)
 	WaitContainer(containerID string, timeout uint) string
 }
 
+// ContainerConfig describes a sandbox container.
+type ContainerConfig struct {
+	Name        string
+	Image       string
+	Command     []string
+	Mounts      []mount.Mount
+	MemoryLimit int64
+	// Limits bound processes, /tmp and files of the container.
+	Limits entities.SandboxContainerLimits
+	// Helper containers only prepare volumes for the others, they run as root
+	// with a writable root filesystem so they can make the volumes writable for SandboxUser.
+	Helper bool
+	// User overrides the user of the container, programs run as SandboxUser by default.
+	User string
+	// Init runs an init process in the container that reaps orphaned processes.
+	Init bool
+	// Interactive keeps stdin open until it is closed by the attached process,
+	// so the container can be piped to another process.
+	Interactive bool
+	// Labels are set on the container, see entities.SandboxLabels.
+	Labels map[string]string
+}
+
+// ExecResult is the outcome of a command run in a running container.
+type ExecResult struct {
+	Stdout              string
+	Stderr              string
+	ExitCode            int
+	Timeout             bool
+	OutputLimitExceeded bool
+}
+
 type dockerService struct {
 	ctx          context.Context
 	DockerClient *client.Client
@@ -84,22 +129,34 @@ func (s dockerService) ImageExist(imageName string) (bool, error) {
 	return true, nil
 }
 
-func (s dockerService) GetLog(containerID string, showStdout, showStderr bool) (string, error) {
+// CaptureLog implements DockerService.
+// It follows the output of a started container until the container stops. When stdout
+// and stderr together grow past limit bytes the container is stopped and the output
+// captured so far is returned with exceeded set.
+// Containers have no TTY, so the log is multiplexed and the output is kept byte for byte.
+func (s dockerService) CaptureLog(containerID string, limit int) (stdout, stderr string, exceeded bool, err error) {
 	logs, err := s.DockerClient.ContainerLogs(s.ctx, containerID, types.ContainerLogsOptions{
-		ShowStdout: showStdout,
-		ShowStderr: showStderr,
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
 	})
 	if err != nil {
-		return "", err
+		return "", "", false, err
 	}
 	defer logs.Close()
 
-	var buf bytes.Buffer
-	io.Copy(&buf, logs)
-	logsString := buf.String()
-	logsString = strings.ReplaceAll(logsString, "\r\n", "\n")
+	var stdoutBuf, stderrBuf bytes.Buffer
+	limiter := newOutputLimiter(limit)
+	stdoutWriter := limiter.Writer(&stdoutBuf)
+	stderrWriter := limiter.Writer(&stderrBuf)
 
-	return logsString, nil
+	_, err = stdcopy.StdCopy(stdoutWriter, stderrWriter, logs)
+	if limiter.Exceeded() {
+		s.StopContainer(containerID)
+		err = nil
+	}
+
+	return stdoutBuf.String(), stderrBuf.String(), limiter.Exceeded(), err
 }
 
 func (s dockerService) GetContainerExitCode(containerID string) (int, error) {
@@ -110,11 +167,20 @@ func (s dockerService) GetContainerExitCode(containerID string) (int, error) {
 	return resp.State.ExitCode, nil
 }
 
-func (s dockerService) CreateVolume(name string) (volume.Volume, error) {
+func (s dockerService) GetContainerState(containerID string) (*types.ContainerState, error) {
+	resp, err := s.DockerClient.ContainerInspect(s.ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	return resp.State, nil
+}
+
+func (s dockerService) CreateVolume(name string, labels map[string]string) (volume.Volume, error) {
 	log.Println("creating volume", name)
 	volume, err := s.DockerClient.VolumeCreate(s.ctx, volume.CreateOptions{
 		Name:   name,
 		Driver: "local",
+		Labels: labels,
 	})
 	return volume, err
 }
@@ -148,31 +214,91 @@ func (s dockerService) CopyToContainer(containerID, targetPath string, data []by
 	return err
 }
 
-func (s dockerService) CreateContainer(imageName string, command []string, volumes []mount.Mount, memoryLimit int64, containerName string) (response container.CreateResponse, err error) {
+// CopyArchiveToContainer implements DockerService.
+// The tar archive is extracted into targetDir, which must be on a volume of a container
+// with a read-only root filesystem.
+func (s dockerService) CopyArchiveToContainer(containerID, targetDir string, archive []byte) error {
+	return s.DockerClient.CopyToContainer(s.ctx, containerID, targetDir, bytes.NewReader(archive), types.CopyToContainerOptions{
+		AllowOverwriteDirWithFile: false,
+		CopyUIDGID:                false,
+	})
+}
+
+// CopyFromContainer implements DockerService.
+// It returns a tar archive of sourcePath, the entries are prefixed with its base name.
+func (s dockerService) CopyFromContainer(containerID, sourcePath string) ([]byte, error) {
+	reader, _, err := s.DockerClient.CopyFromContainer(s.ctx, containerID, sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// CreateContainer implements DockerService.
+// Containers run on a single core without network, capabilities or privilege escalation.
+// Containers other than helpers have a read-only root filesystem and run as SandboxUser
+// unless another user is given.
+func (s dockerService) CreateContainer(config ContainerConfig) (response container.CreateResponse, err error) {
+	user := entities.SandboxUser
+	if config.User != "" {
+		user = config.User
+	}
+	if config.Helper {
+		user = "0:0"
+	}
+
+	// without a TTY stdout and stderr stay apart and line endings are kept
 	response, err = s.DockerClient.ContainerCreate(s.ctx, &container.Config{
-		Image:           imageName,
+		Image:           config.Image,
 		NetworkDisabled: true,
-		Tty:             true,
+		Tty:             false,
 		AttachStdout:    true,
 		AttachStderr:    true,
 		AttachStdin:     true,
 		OpenStdin:       true,
-		Env:             []string{"PYTHONUNBUFFERED=1"},
-		Entrypoint:      command,
+		StdinOnce:       config.Interactive,
+		Env:             []string{"PYTHONUNBUFFERED=1", "HOME=/tmp"},
+		Entrypoint:      config.Command,
+		User:            user,
+		Labels:          config.Labels,
 	},
 		&container.HostConfig{
-			Mounts: volumes,
+			Mounts: config.Mounts,
 			Resources: container.Resources{
-				Memory: memoryLimit,
+				Memory:    config.MemoryLimit,
+				NanoCPUs:  entities.SandboxNanoCPUs,
+				PidsLimit: &config.Limits.PidsLimit,
+				Ulimits: []*units.Ulimit{
+					{Name: "fsize", Soft: config.Limits.FileSizeLimit, Hard: config.Limits.FileSizeLimit},
+					{Name: "nofile", Soft: config.Limits.OpenFilesLimit, Hard: config.Limits.OpenFilesLimit},
+				},
+			},
+			Init:           &config.Init,
+			CapDrop:        []string{"ALL"},
+			SecurityOpt:    []string{"no-new-privileges", "seccomp=" + entities.SandboxSeccompProfile},
+			ReadonlyRootfs: !config.Helper,
+			Tmpfs: map[string]string{
+				"/tmp": fmt.Sprintf("rw,nosuid,nodev,size=%d", config.Limits.TmpfsSize),
 			},
 		},
 		nil,
 		nil,
-		containerName,
+		config.Name,
 	)
 	return
 }
 
+func (s dockerService) AttachContainer(containerID string) (types.HijackedResponse, error) {
+	return s.DockerClient.ContainerAttach(s.ctx, containerID, types.ContainerAttachOptions{
+		Stream: true,
+		Stdin:  true,
+		Stdout: true,
+		Stderr: true,
+	})
+}
+
 func (s dockerService) StartContainer(containerID string) error {
 	err := s.DockerClient.ContainerStart(s.ctx, containerID, types.ContainerStartOptions{})
 	return err
@@ -187,14 +313,149 @@ func (s dockerService) StopContainer(containerID string) error {
 	return err
 }
 
+// UpdateContainerMemory implements DockerService.
+// Swap is limited to the same amount, so the container cannot swap.
+func (s dockerService) UpdateContainerMemory(containerID string, memoryLimit int64) error {
+	_, err := s.DockerClient.ContainerUpdate(s.ctx, containerID, container.UpdateConfig{
+		Resources: container.Resources{
+			Memory:     memoryLimit,
+			MemorySwap: memoryLimit,
+		},
+	})
+	return err
+}
+
+const (
+	// execPollInterval is how often a finished exec is checked for its exit code.
+	execPollInterval = 10 * time.Millisecond
+	// execWaitTimeout is how long an exec may take to be reported finished after its output ended.
+	execWaitTimeout = 10 * time.Second
+)
+
+// ExecContainer implements DockerService.
+// The command runs as user in the running container. When it runs longer than timeout
+// milliseconds or writes more than outputLimit bytes, every process of the user in the
+// container except its init process is killed.
+func (s dockerService) ExecContainer(containerID, user string, command []string, timeout uint, outputLimit int) (*ExecResult, error) {
+	exec, err := s.DockerClient.ContainerExecCreate(s.ctx, containerID, types.ExecConfig{
+		User:         user,
+		Cmd:          command,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := s.DockerClient.ContainerExecAttach(s.ctx, exec.ID, types.ExecStartCheck{})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var stdout, stderr bytes.Buffer
+	limiter := newOutputLimiter(outputLimit)
+	copyDone := make(chan struct{})
+	go func() {
+		defer close(copyDone)
+		stdcopy.StdCopy(limiter.Writer(&stdout), limiter.Writer(&stderr), conn.Reader)
+	}()
+
+	result := &ExecResult{}
+	select {
+	case <-copyDone:
+	case <-time.After(time.Duration(timeout) * time.Millisecond):
+		result.Timeout = true
+	}
+
+	if result.Timeout || limiter.Exceeded() {
+		err = s.killUserProcesses(containerID, user)
+		if err != nil {
+			return nil, err
+		}
+	}
+	conn.Close()
+	<-copyDone
+
+	result.ExitCode, err = s.waitExec(exec.ID)
+	if err != nil {
+		return nil, err
+	}
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	result.OutputLimitExceeded = limiter.Exceeded()
+
+	return result, nil
+}
+
+// killUserProcesses kills every process of user in the container except its init process.
+func (s dockerService) killUserProcesses(containerID, user string) error {
+	exec, err := s.DockerClient.ContainerExecCreate(s.ctx, containerID, types.ExecConfig{
+		User: user,
+		Cmd:  []string{"/bin/sh", "-c", "kill -9 -1"},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = s.DockerClient.ContainerExecStart(s.ctx, exec.ID, types.ExecStartCheck{Detach: true})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.waitExec(exec.ID)
+	return err
+}
+
+// waitExec waits for an exec to finish and returns its exit code.
+func (s dockerService) waitExec(execID string) (int, error) {
+	deadline := time.Now().Add(execWaitTimeout)
+	for {
+		inspect, err := s.DockerClient.ContainerExecInspect(s.ctx, execID)
+		if err != nil {
+			return 0, err
+		}
+		if !inspect.Running {
+			return inspect.ExitCode, nil
+		}
+		if time.Now().After(deadline) {
+			return 0, errors.New("exec did not finish")
+		}
+		time.Sleep(execPollInterval)
+	}
+}
+
+// RemoveContainer implements DockerService.
+// Anonymous volumes of the container are removed with it, named volumes are kept.
 func (s dockerService) RemoveContainer(containerID string) error {
 	err := s.DockerClient.ContainerRemove(s.ctx, containerID, types.ContainerRemoveOptions{
-		RemoveVolumes: false,
+		RemoveVolumes: true,
 		Force:         true,
 	})
 	return err
 }
 
+// ListContainers implements DockerService.
+// It returns the running and stopped containers that have the label.
+func (s dockerService) ListContainers(label string) ([]types.Container, error) {
+	return s.DockerClient.ContainerList(s.ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", label)),
+	})
+}
+
+// ListVolumes implements DockerService.
+// It returns the volumes that have the label.
+func (s dockerService) ListVolumes(label string) ([]*volume.Volume, error) {
+	resp, err := s.DockerClient.VolumeList(s.ctx, volume.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", label)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Volumes, nil
+}
+
 func NewDockerservice() DockerService {
 	ctx := context.Background()
 	dockerClient, err := client.NewClientWithOpts(client.WithHost("unix:///var/run/docker.sock"))
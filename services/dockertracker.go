@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/volume"
+)
+
+// trackedDockerService remembers the containers and volumes created through it that were
+// not removed yet, so a sandbox service can remove those of interrupted runs on shutdown.
+type trackedDockerService struct {
+	DockerService
+	mutex      sync.Mutex
+	containers map[string]bool
+	volumes    map[string]volume.Volume
+}
+
+// CreateContainer implements DockerService.
+func (d *trackedDockerService) CreateContainer(config ContainerConfig) (container.CreateResponse, error) {
+	resp, err := d.DockerService.CreateContainer(config)
+	if err != nil {
+		return resp, err
+	}
+
+	d.mutex.Lock()
+	d.containers[resp.ID] = true
+	d.mutex.Unlock()
+	return resp, nil
+}
+
+// RemoveContainer implements DockerService.
+func (d *trackedDockerService) RemoveContainer(containerID string) error {
+	err := d.DockerService.RemoveContainer(containerID)
+	if err != nil {
+		return err
+	}
+
+	d.mutex.Lock()
+	delete(d.containers, containerID)
+	d.mutex.Unlock()
+	return nil
+}
+
+// CreateVolume implements DockerService.
+func (d *trackedDockerService) CreateVolume(name string, labels map[string]string) (volume.Volume, error) {
+	v, err := d.DockerService.CreateVolume(name, labels)
+	if err != nil {
+		return v, err
+	}
+
+	d.mutex.Lock()
+	d.volumes[v.Name] = v
+	d.mutex.Unlock()
+	return v, nil
+}
+
+// DeleteVolume implements DockerService.
+func (d *trackedDockerService) DeleteVolume(v volume.Volume) error {
+	err := d.DockerService.DeleteVolume(v)
+	if err != nil {
+		return err
+	}
+
+	d.mutex.Lock()
+	delete(d.volumes, v.Name)
+	d.mutex.Unlock()
+	return nil
+}
+
+// tracksContainer reports whether the container was created through d and not removed yet.
+func (d *trackedDockerService) tracksContainer(containerID string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.containers[containerID]
+}
+
+// tracksVolume reports whether the volume was created through d and not deleted yet.
+func (d *trackedDockerService) tracksVolume(name string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	_, ok := d.volumes[name]
+	return ok
+}
+
+// removeAll removes every tracked container and then every tracked volume,
+// as volumes can only be removed once no container uses them.
+func (d *trackedDockerService) removeAll() error {
+	d.mutex.Lock()
+	containerIDs := make([]string, 0, len(d.containers))
+	for containerID := range d.containers {
+		containerIDs = append(containerIDs, containerID)
+	}
+	volumes := make([]volume.Volume, 0, len(d.volumes))
+	for _, v := range d.volumes {
+		volumes = append(volumes, v)
+	}
+	d.mutex.Unlock()
+
+	var errs []error
+	for _, containerID := range containerIDs {
+		errs = append(errs, d.RemoveContainer(containerID))
+	}
+	for _, v := range volumes {
+		errs = append(errs, d.DeleteVolume(v))
+	}
+	return errors.Join(errs...)
+}
+
+func newTrackedDockerService(dockerService DockerService) *trackedDockerService {
+	return &trackedDockerService{
+		DockerService: dockerService,
+		containers:    map[string]bool{},
+		volumes:       map[string]volume.Volume{},
+	}
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/wuttinanhi/code-judge-system/entities"
+)
+
+// JobDispatcher runs process for messages on a fixed number of workers. A job is never run
+// by two workers at the same time, so a message of a job that is running waits until the
+// job is done, e.g. a rejudge that arrives while the submission is judged. The job and the
+// retry topic of a job type share a dispatcher, so this holds across both topics.
+type JobDispatcher interface {
+	// Dispatch queues the message for the next free worker. It waits while twice as many
+	// messages as there are workers are queued, so fetching does not run ahead of the workers.
+	Dispatch(message *KafkaMessage)
+	// Close waits until every queued message is processed and stops the workers.
+	Close()
+}
+
+// dispatchedMessage is a queued message, hasJob is false when the message is not a job.
+type dispatchedMessage struct {
+	message *KafkaMessage
+	jobID   uint
+	hasJob  bool
+}
+
+type jobDispatcher struct {
+	mutex    sync.Mutex
+	cond     *sync.Cond
+	wg       sync.WaitGroup
+	jobType  string
+	process  func(message *KafkaMessage)
+	capacity int
+	// queue holds the messages a worker can take, waiting those of the running jobs.
+	queue   []*dispatchedMessage
+	waiting map[uint][]*dispatchedMessage
+	running map[uint]bool
+	queued  int
+	closed  bool
+}
+
+// Dispatch implements JobDispatcher.
+func (d *jobDispatcher) Dispatch(message *KafkaMessage) {
+	dispatched := &dispatchedMessage{message: message}
+	job, err := entities.ParseJobEnvelope(message.Value, d.jobType)
+	if err == nil {
+		dispatched.jobID = job.ID
+		dispatched.hasJob = true
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for d.queued >= d.capacity {
+		d.cond.Wait()
+	}
+	d.queued++
+
+	if dispatched.hasJob && d.running[dispatched.jobID] {
+		d.waiting[dispatched.jobID] = append(d.waiting[dispatched.jobID], dispatched)
+		return
+	}
+	if dispatched.hasJob {
+		d.running[dispatched.jobID] = true
+	}
+	d.queue = append(d.queue, dispatched)
+	d.cond.Broadcast()
+}
+
+// Close implements JobDispatcher.
+func (d *jobDispatcher) Close() {
+	d.mutex.Lock()
+	d.closed = true
+	d.cond.Broadcast()
+	d.mutex.Unlock()
+
+	d.wg.Wait()
+}
+
+// work processes queued messages until the dispatcher is closed and nothing is queued.
+func (d *jobDispatcher) work() {
+	defer d.wg.Done()
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for {
+		for len(d.queue) == 0 && !d.closed {
+			d.cond.Wait()
+		}
+		if len(d.queue) == 0 {
+			return
+		}
+
+		dispatched := d.queue[0]
+		d.queue = d.queue[1:]
+
+		d.mutex.Unlock()
+		d.process(dispatched.message)
+		d.mutex.Lock()
+
+		d.queued--
+		if dispatched.hasJob {
+			d.done(dispatched.jobID)
+		}
+		d.cond.Broadcast()
+	}
+}
+
+// done releases a finished job, its next waiting message goes to the front of the queue.
+func (d *jobDispatcher) done(jobID uint) {
+	waiting := d.waiting[jobID]
+	if len(waiting) == 0 {
+		delete(d.running, jobID)
+		return
+	}
+
+	if len(waiting) == 1 {
+		delete(d.waiting, jobID)
+	} else {
+		d.waiting[jobID] = waiting[1:]
+	}
+	d.queue = append([]*dispatchedMessage{waiting[0]}, d.queue...)
+}
+
+// NewJobDispatcher creates a dispatcher that runs process for the messages of jobType on
+// workers goroutines. Messages that are not a job are processed without waiting for others.
+func NewJobDispatcher(jobType string, workers int, process func(message *KafkaMessage)) JobDispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+
+	d := &jobDispatcher{
+		jobType:  jobType,
+		process:  process,
+		capacity: 2 * workers,
+		waiting:  map[uint][]*dispatchedMessage{},
+		running:  map[uint]bool{},
+	}
+	d.cond = sync.NewCond(&d.mutex)
+
+	d.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go d.work()
+	}
+	return d
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/wuttinanhi/code-judge-system/entities"
+)
+
+// JobTopics are the topics of a job type. Failed jobs wait on the retry topic and jobs
+// that are given up on go to the dead letter topic.
+type JobTopics struct {
+	Topic           string
+	RetryTopic      string
+	DeadLetterTopic string
+}
+
+// JobHandler runs a job. A job whose handler returns an error is retried, unless ctx is
+// done, which stops the job to be run again once its message is consumed again.
+type JobHandler func(ctx context.Context, job *entities.JobEnvelope) error
+
+// JobProcessor runs the jobs of consumed messages with at-least-once delivery.
+type JobProcessor interface {
+	// Process runs the job of a message from the job or the retry topic and commits the
+	// message once the job is done, retried or dead lettered. An error means the message
+	// could not be handed on or the job was stopped by ctx, and was not committed.
+	Process(ctx context.Context, message *KafkaMessage) error
+}
+
+type jobProcessor struct {
+	kafkaService      KafkaService
+	deadLetterService DeadLetterService
+	topics            JobTopics
+	jobType           string
+	maxAttempts       int
+	retryBackoff      time.Duration
+	handler           JobHandler
+}
+
+// Process implements JobProcessor.
+func (p *jobProcessor) Process(ctx context.Context, message *KafkaMessage) error {
+	job, err := entities.ParseJobEnvelope(message.Value, p.jobType)
+	if err != nil {
+		// a message that is not a job never succeeds, so it is not retried
+		log.Println("Rejecting", p.jobType, "message:", message.Value, err)
+		err = p.deadLetter(message.Value, nil, err)
+	} else {
+		err = p.run(ctx, job)
+	}
+	if err != nil {
+		return err
+	}
+
+	return message.Commit()
+}
+
+// run runs the job once its backoff is over and hands it on when it fails.
+func (p *jobProcessor) run(ctx context.Context, job *entities.JobEnvelope) error {
+	err := WaitForRetry(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Receiving", p.jobType, "ID:", job.ID, "attempt:", job.Attempt, "trace:", job.TraceID)
+
+	cause := p.handler(ctx, job)
+	if cause == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		// a stopped job did not fail, it is run again from the uncommitted message
+		return ctx.Err()
+	}
+	log.Println("Failed", p.jobType, "ID:", job.ID, "attempt:", job.Attempt, "trace:", job.TraceID, "with error:", cause)
+
+	if job.Attempt >= p.maxAttempts {
+		job.LastError = cause.Error()
+		message, err := job.Encode()
+		if err != nil {
+			return err
+		}
+		return p.deadLetter(message, job, cause)
+	}
+
+	job.NextAttempt(cause, p.retryBackoff, time.Now())
+	return p.kafkaService.ProduceJob(p.topics.RetryTopic, job)
+}
+
+// WaitForRetry waits until the backoff of a retried job is over or ctx is done.
+// Consumers wait before dispatching a job, so a waiting job does not take a worker.
+func WaitForRetry(ctx context.Context, job *entities.JobEnvelope) error {
+	if job.RetryAt == nil {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Until(*job.RetryAt))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// deadLetter gives up on a message, job is nil when the message is not a job.
+func (p *jobProcessor) deadLetter(message string, job *entities.JobEnvelope, cause error) error {
+	deadLetterJob := &entities.DeadLetterJob{
+		Topic:   p.topics.Topic,
+		JobType: p.jobType,
+		Message: message,
+		Error:   cause.Error(),
+	}
+	if job != nil {
+		deadLetterJob.TraceID = job.TraceID
+		deadLetterJob.Attempt = job.Attempt
+	}
+
+	_, err := p.deadLetterService.DeadLetter(p.topics.DeadLetterTopic, deadLetterJob)
+	return err
+}
+
+// NewJobProcessor creates a processor that runs jobs of jobType with handler, trying a job
+// at most maxAttempts times. The wait before a retry starts at retryBackoff and doubles
+// with every failed attempt.
+func NewJobProcessor(kafkaService KafkaService, deadLetterService DeadLetterService, topics JobTopics, jobType string, maxAttempts int, retryBackoff time.Duration, handler JobHandler) JobProcessor {
+	return &jobProcessor{
+		kafkaService:      kafkaService,
+		deadLetterService: deadLetterService,
+		topics:            topics,
+		jobType:           jobType,
+		maxAttempts:       maxAttempts,
+		retryBackoff:      retryBackoff,
+		handler:           handler,
+	}
+}
@@ -2,29 +2,83 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"sync"
 	"time"
 
 	"github.com/segmentio/kafka-go"
+	"github.com/wuttinanhi/code-judge-system/entities"
 )
 
 type KafkaService interface {
 	Produce(topic string, message string) error
-	Consume(topic string, groupID string) (chan string, chan error)
+	ProduceEvent(topic string, key string, message string) error
+	ProduceJob(topic string, job *entities.JobEnvelope) error
+	Consume(ctx context.Context, topic string, groupID string) (chan *KafkaMessage, chan error)
+	Broadcast(topic string) (chan string, chan error)
 	IsTopicExist(topic string) bool
 	OverriddenHost(host string)
 	CreateTopic(topic string, partitions int) error
+	Close() error
+}
+
+// KafkaMessage is a consumed message. Its offset is only committed by Commit, so a message
+// that was not committed is consumed again after a restart.
+type KafkaMessage struct {
+	Value  string
+	commit func() error
+}
+
+// Commit marks the message as consumed.
+func (m *KafkaMessage) Commit() error {
+	if m.commit == nil {
+		return nil
+	}
+	return m.commit()
+}
+
+// NewKafkaMessage creates a message that calls commit when it is committed.
+func NewKafkaMessage(value string, commit func() error) *KafkaMessage {
+	return &KafkaMessage{Value: value, commit: commit}
 }
 
 type kafkaService struct {
-	host string
-	ctx  context.Context
+	host   string
+	ctx    context.Context
+	cancel context.CancelFunc
+	mutex  sync.Mutex
+	// readers are closed by Close, after which the consume and broadcast loops stop.
+	readers []*kafka.Reader
+	// writers are created on first use so an overridden host applies, and closed by Close.
+	writer      *kafka.Writer
+	eventWriter *kafka.Writer
 }
 
 func newKafkaWriter(host string) *kafka.Writer {
 	return &kafka.Writer{
 		Addr:     kafka.TCP(host),
 		Balancer: &kafka.LeastBytes{},
+		// a message is sent right away instead of waiting a second for a fuller batch
+		BatchTimeout: 10 * time.Millisecond,
+	}
+}
+
+// newKafkaEventWriter creates a writer that sends in the background, so producing an
+// event never waits for the broker. Messages with the same key go to the same partition
+// and keep their order.
+func newKafkaEventWriter(host string) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:         kafka.TCP(host),
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: 10 * time.Millisecond,
+		Async:        true,
+		Completion: func(messages []kafka.Message, err error) {
+			if err != nil {
+				log.Println("KafkaService: failed to produce", len(messages), "events with error:", err)
+			}
+		},
 	}
 }
 
@@ -61,12 +115,7 @@ func (s *kafkaService) OverriddenHost(host string) {
 
 // Produce implements KafkaService.
 func (s *kafkaService) Produce(topic string, message string) error {
-	if s.host == "" {
-		panic("KafkaService: host is empty")
-	}
-
-	writer := newKafkaWriter(s.host)
-	defer writer.Close()
+	writer, _ := s.getWriters()
 
 	msg := kafka.Message{
 		Topic: topic,
@@ -77,30 +126,169 @@ func (s *kafkaService) Produce(topic string, message string) error {
 	return writer.WriteMessages(s.ctx, msg)
 }
 
+// ProduceEvent implements KafkaService.
+// The message is sent in the background, so delivery is best effort and failures are only
+// logged. Messages with the same key are delivered in the order they were produced.
+func (s *kafkaService) ProduceEvent(topic string, key string, message string) error {
+	_, eventWriter := s.getWriters()
+
+	msg := kafka.Message{
+		Topic: topic,
+		Value: []byte(message),
+		Key:   []byte(key),
+	}
+
+	return eventWriter.WriteMessages(s.ctx, msg)
+}
+
+// getWriters returns the writers shared by every producer, creating them on first use.
+func (s *kafkaService) getWriters() (writer *kafka.Writer, eventWriter *kafka.Writer) {
+	if s.host == "" {
+		panic("KafkaService: host is empty")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.writer == nil {
+		s.writer = newKafkaWriter(s.host)
+		s.eventWriter = newKafkaEventWriter(s.host)
+	}
+	return s.writer, s.eventWriter
+}
+
+// ProduceJob implements KafkaService.
+func (s *kafkaService) ProduceJob(topic string, job *entities.JobEnvelope) error {
+	message, err := job.Encode()
+	if err != nil {
+		return err
+	}
+
+	return s.Produce(topic, message)
+}
+
 // Consume implements KafkaService.
-func (s *kafkaService) Consume(topic string, groupID string) (chan string, chan error) {
-	reader := getKafkaReader(s.host, topic, groupID)
+// Messages are fetched without committing them, the receiver commits each message once
+// it is done with it. Messages may be committed in any order, see OffsetCommitter.
+// Fetching stops and both channels are closed once ctx is done, while messages that were
+// received can still be committed until the service is closed.
+func (s *kafkaService) Consume(ctx context.Context, topic string, groupID string) (chan *KafkaMessage, chan error) {
+	reader := s.addReader(getKafkaReader(s.host, topic, groupID))
+	committer := NewOffsetCommitter(func(partition int, offset int64) error {
+		return reader.CommitMessages(s.ctx, kafka.Message{Topic: topic, Partition: partition, Offset: offset})
+	})
 
 	// make channel
+	messageC := make(chan *KafkaMessage)
+	errorC := make(chan error)
+
+	go func() {
+		defer close(errorC)
+		defer close(messageC)
+
+		for {
+			msg, err := reader.FetchMessage(ctx)
+			if err != nil {
+				if ctx.Err() != nil || s.ctx.Err() != nil {
+					return
+				}
+
+				select {
+				case errorC <- err:
+				case <-ctx.Done():
+					return
+				}
+				continue
+			}
+
+			// a message that is not received is not committed and is consumed again
+			select {
+			case messageC <- NewKafkaMessage(string(msg.Value), committer.Track(msg.Partition, msg.Offset)):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return messageC, errorC
+}
+
+// Broadcast implements KafkaService.
+// Every call joins its own consumer group starting at the newest offset,
+// so each caller receives every message produced after it subscribed.
+// Both channels are closed once the service is closed.
+func (s *kafkaService) Broadcast(topic string) (chan string, chan error) {
+	reader := s.addReader(kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     []string{s.host},
+		GroupID:     fmt.Sprintf("%s-broadcast-%d", topic, time.Now().UnixNano()),
+		Topic:       topic,
+		StartOffset: kafka.LastOffset,
+	}))
+
 	messageC := make(chan string)
 	errorC := make(chan error)
 
 	go func() {
+		defer close(errorC)
+		defer close(messageC)
+
 		for {
 			msg, err := reader.ReadMessage(s.ctx)
 			if err != nil {
-				errorC <- err
+				if s.ctx.Err() != nil {
+					return
+				}
+
+				select {
+				case errorC <- err:
+				case <-s.ctx.Done():
+					return
+				}
 				continue
 			}
 
-			messageStr := string(msg.Value)
-			messageC <- messageStr
+			select {
+			case messageC <- string(msg.Value):
+			case <-s.ctx.Done():
+				return
+			}
 		}
 	}()
 
 	return messageC, errorC
 }
 
+// addReader registers reader to be closed by Close.
+func (s *kafkaService) addReader(reader *kafka.Reader) *kafka.Reader {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.readers = append(s.readers, reader)
+	return reader
+}
+
+// Close implements KafkaService.
+// Consumed messages can no longer be committed once the service is closed.
+// Events that are still being sent are flushed first.
+func (s *kafkaService) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var errs []error
+	if s.writer != nil {
+		errs = append(errs, s.eventWriter.Close(), s.writer.Close())
+		s.writer, s.eventWriter = nil, nil
+	}
+
+	s.cancel()
+
+	for _, reader := range s.readers {
+		errs = append(errs, reader.Close())
+	}
+	s.readers = nil
+	return errors.Join(errs...)
+}
+
 // CreateTopic implements KafkaService.
 func (s *kafkaService) CreateTopic(topic string, partitions int) error {
 	conn, err := kafka.DialContext(s.ctx, "tcp", s.host)
@@ -117,8 +305,10 @@ func (s *kafkaService) CreateTopic(topic string, partitions int) error {
 }
 
 func NewKafkaService(host string) KafkaService {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &kafkaService{
-		host: host,
-		ctx:  context.Background(),
+		host:   host,
+		ctx:    ctx,
+		cancel: cancel,
 	}
 }
@@ -3,10 +3,15 @@ package services
 import (
 	"context"
 	"log"
+	"sync"
+
+	"github.com/wuttinanhi/code-judge-system/entities"
 )
 
 type kafkaMockService struct {
-	ctx context.Context
+	ctx         context.Context
+	mutex       sync.Mutex
+	subscribers map[string][]chan string
 }
 
 // CreateTopic implements KafkaService.
@@ -15,9 +20,36 @@ func (*kafkaMockService) CreateTopic(topic string, partitions int) error {
 }
 
 // Consume implements KafkaService.
-func (*kafkaMockService) Consume(topic string, groupID string) (chan string, chan error) {
+// No message is consumed, both channels are closed once ctx is done.
+func (*kafkaMockService) Consume(ctx context.Context, topic string, groupID string) (chan *KafkaMessage, chan error) {
+	messageC := make(chan *KafkaMessage)
+	errorC := make(chan error)
+
+	go func() {
+		<-ctx.Done()
+		close(messageC)
+		close(errorC)
+	}()
+
+	return messageC, errorC
+}
+
+// Broadcast implements KafkaService.
+// Messages produced to the topic afterwards are delivered in process.
+func (s *kafkaMockService) Broadcast(topic string) (chan string, chan error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	messageC := make(chan string, 64)
+	s.subscribers[topic] = append(s.subscribers[topic], messageC)
+
+	return messageC, make(chan error)
+}
+
+// Close implements KafkaService.
+func (*kafkaMockService) Close() error {
 	// do nothing
-	return nil, nil
+	return nil
 }
 
 // IsTopicExist implements KafkaService.
@@ -32,14 +64,37 @@ func (*kafkaMockService) OverriddenHost(host string) {
 }
 
 // Produce implements KafkaService.
-func (*kafkaMockService) Produce(topic string, message string) error {
-	// do nothing
+func (s *kafkaMockService) Produce(topic string, message string) error {
 	log.Println("KafkaMockService: Produce", topic, message)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, messageC := range s.subscribers[topic] {
+		messageC <- message
+	}
+
 	return nil
 }
 
+// ProduceEvent implements KafkaService.
+func (s *kafkaMockService) ProduceEvent(topic string, key string, message string) error {
+	return s.Produce(topic, message)
+}
+
+// ProduceJob implements KafkaService.
+func (s *kafkaMockService) ProduceJob(topic string, job *entities.JobEnvelope) error {
+	message, err := job.Encode()
+	if err != nil {
+		return err
+	}
+
+	return s.Produce(topic, message)
+}
+
 func NewKafkaMockService() KafkaService {
 	return &kafkaMockService{
-		ctx: context.Background(),
+		ctx:         context.Background(),
+		subscribers: map[string][]chan string{},
 	}
 }
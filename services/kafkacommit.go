@@ -0,0 +1,73 @@
+package services
+
+import "sync"
+
+// OffsetCommitter commits the offsets of messages that are processed concurrently.
+// Committing an offset marks every message before it in its partition as consumed, so an
+// offset is only committed once every message fetched before it is done.
+type OffsetCommitter interface {
+	// Track registers a fetched message in fetch order and returns a function that marks
+	// it done, committing the offsets that are no longer behind an unfinished message.
+	Track(partition int, offset int64) (done func() error)
+}
+
+type pendingOffset struct {
+	offset int64
+	done   bool
+}
+
+type offsetCommitter struct {
+	mutex   sync.Mutex
+	commit  func(partition int, offset int64) error
+	pending map[int][]*pendingOffset
+}
+
+// Track implements OffsetCommitter.
+func (c *offsetCommitter) Track(partition int, offset int64) (done func() error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	pending := &pendingOffset{offset: offset}
+	c.pending[partition] = append(c.pending[partition], pending)
+
+	return func() error {
+		return c.done(partition, pending)
+	}
+}
+
+// done marks the message done and commits the last offset of the finished messages at
+// the front of its partition. The lock is held while committing to keep commits in order.
+func (c *offsetCommitter) done(partition int, pending *pendingOffset) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	pending.done = true
+
+	queue := c.pending[partition]
+	committable := -1
+	for i, p := range queue {
+		if !p.done {
+			break
+		}
+		committable = i
+	}
+	if committable < 0 {
+		return nil
+	}
+
+	err := c.commit(partition, queue[committable].offset)
+	if err != nil {
+		return err
+	}
+	c.pending[partition] = queue[committable+1:]
+	return nil
+}
+
+// NewOffsetCommitter creates a committer that commits through commit, called with the
+// partition and the offset of the last consumed message.
+func NewOffsetCommitter(commit func(partition int, offset int64) error) OffsetCommitter {
+	return &offsetCommitter{
+		commit:  commit,
+		pending: map[int][]*pendingOffset{},
+	}
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/repositories"
+)
+
+type LanguageService interface {
+	SeedLanguages(languages []*entities.Language) error
+	CreateLanguage(language *entities.Language) (*entities.Language, error)
+	UpdateLanguage(language *entities.Language) (*entities.Language, error)
+	SetLanguageEnabled(language *entities.Language, enabled bool) (*entities.Language, error)
+	GetLanguageByID(id uint) (*entities.Language, error)
+	AllLanguages() ([]*entities.Language, error)
+	EnabledLanguages() ([]*entities.Language, error)
+	GetInstruction(language string) (*entities.SandboxInstruction, error)
+	ValidateLanguage(language string) error
+}
+
+type languageService struct {
+	languageRepo repositories.LanguageRepository
+}
+
+// SeedLanguages implements LanguageService.
+// Only languages that do not exist yet are created, so changes made through
+// the admin API are kept across restarts.
+func (s *languageService) SeedLanguages(languages []*entities.Language) error {
+	for _, language := range languages {
+		existing, err := s.languageRepo.GetLanguageByName(language.Language)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			continue
+		}
+
+		_, err = s.languageRepo.CreateLanguage(language)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateLanguage implements LanguageService.
+func (s *languageService) CreateLanguage(language *entities.Language) (*entities.Language, error) {
+	existing, err := s.languageRepo.GetLanguageByName(language.Language)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("language %s already exists", language.Language)
+	}
+
+	return s.languageRepo.CreateLanguage(language)
+}
+
+// UpdateLanguage implements LanguageService.
+func (s *languageService) UpdateLanguage(language *entities.Language) (*entities.Language, error) {
+	existing, err := s.languageRepo.GetLanguageByName(language.Language)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.ID != language.ID {
+		return nil, fmt.Errorf("language %s already exists", language.Language)
+	}
+
+	return s.languageRepo.UpdateLanguage(language)
+}
+
+// SetLanguageEnabled implements LanguageService.
+func (s *languageService) SetLanguageEnabled(language *entities.Language, enabled bool) (*entities.Language, error) {
+	language.Enabled = enabled
+	return s.languageRepo.UpdateLanguage(language)
+}
+
+// GetLanguageByID implements LanguageService.
+func (s *languageService) GetLanguageByID(id uint) (*entities.Language, error) {
+	return s.languageRepo.GetLanguageByID(id)
+}
+
+// AllLanguages implements LanguageService.
+func (s *languageService) AllLanguages() ([]*entities.Language, error) {
+	return s.languageRepo.AllLanguages()
+}
+
+// EnabledLanguages implements LanguageService.
+func (s *languageService) EnabledLanguages() ([]*entities.Language, error) {
+	return s.languageRepo.EnabledLanguages()
+}
+
+// GetInstruction implements LanguageService.
+// Disabled languages still return their instruction so existing submissions,
+// checkers and interactors written in them can be judged.
+func (s *languageService) GetInstruction(language string) (*entities.SandboxInstruction, error) {
+	found, err := s.languageRepo.GetLanguageByName(language)
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("language %s not supported", language)
+	}
+
+	return found.ToInstruction(), nil
+}
+
+// ValidateLanguage implements LanguageService.
+// New programs can only be written in enabled languages.
+func (s *languageService) ValidateLanguage(language string) error {
+	found, err := s.languageRepo.GetLanguageByName(language)
+	if err != nil {
+		return err
+	}
+	if found == nil || !found.Enabled {
+		return fmt.Errorf("language %s not supported", language)
+	}
+
+	return nil
+}
+
+// DefaultLanguages returns the built-in languages the language table is seeded with.
+func DefaultLanguages() []*entities.Language {
+	languages := make([]*entities.Language, len(entities.DefaultSandboxInstructions))
+	for i, instruction := range entities.DefaultSandboxInstructions {
+		languages[i] = entities.NewLanguageFromInstruction(instruction)
+	}
+	return languages
+}
+
+func NewLanguageService(languageRepo repositories.LanguageRepository) LanguageService {
+	return &languageService{
+		languageRepo: languageRepo,
+	}
+}
@@ -0,0 +1,672 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/wuttinanhi/code-judge-system/entities"
+)
+
+// nsjailSandboxService runs programs with nsjail on the judge host instead of in Docker
+// containers, so judging needs no Docker daemon. The compilers and runtimes of the languages
+// must be installed on the host, the Docker image of a language is ignored.
+// Every program runs in its own namespaces and cgroup v2 as the unprivileged sandbox user,
+// seeing the host system directories read-only, its program at /sandbox, its files at /stdin
+// and a private /tmp. nsjail needs root to map the sandbox user and to create cgroups.
+// The programs of a run are judged out of memory by the OOM kills counted in its cgroup.
+type nsjailSandboxService struct {
+	sandboxLimits
+	languageService LanguageService
+	nsjailPath      string
+	workDir         string
+	outputLimit     int
+	// mutex guards the running processes and the program directories Close cleans up.
+	mutex       sync.Mutex
+	processes   map[*nsjailProcess]bool
+	programDirs map[string]bool
+}
+
+// nsjailCgroupRoot is the cgroup v2 mount the cgroups of the runs are created in.
+const nsjailCgroupRoot = "/sys/fs/cgroup"
+
+// nsjailSystemMounts are the host directories programs see read-only, when they exist.
+var nsjailSystemMounts = []string{"/bin", "/sbin", "/lib", "/lib32", "/lib64", "/libx32", "/usr", "/etc", "/opt"}
+
+// nsjailDevices are the devices programs can use.
+var nsjailDevices = []string{"/dev/null", "/dev/zero", "/dev/random", "/dev/urandom"}
+
+// nsjailRun is a program run by nsjail.
+type nsjailRun struct {
+	command     string
+	mounts      []string
+	memoryLimit uint
+	timeLimit   uint
+	limits      entities.SandboxContainerLimits
+	logFile     string
+	// cgroupDir is the cgroup of the run, nsjail creates the cgroup of the program in it.
+	cgroupDir string
+}
+
+// args returns the nsjail arguments of the run.
+// The time limit is enforced by killing nsjail, the nsjail limits only back it up.
+func (s *nsjailSandboxService) args(run nsjailRun) []string {
+	sandboxID := strings.Split(entities.SandboxUser, ":")
+	backupSeconds := strconv.FormatUint(uint64(run.timeLimit/1000+2), 10)
+
+	args := []string{
+		"--mode", "o",
+		"--log", run.logFile,
+		"--quiet",
+		"--hostname", "sandbox",
+		"--user", sandboxID[0] + ":" + sandboxID[0] + ":1",
+		"--group", sandboxID[1] + ":" + sandboxID[1] + ":1",
+		"--cwd", "/sandbox",
+		"--time_limit", backupSeconds,
+		"--use_cgroupv2",
+		"--cgroupv2_mount", run.cgroupDir,
+		"--cgroup_mem_max", strconv.FormatUint(uint64(run.memoryLimit), 10),
+		"--cgroup_pids_max", strconv.FormatInt(run.limits.PidsLimit, 10),
+		"--cgroup_cpu_ms_per_sec", "1000",
+		"--rlimit_as", "max",
+		"--rlimit_cpu", backupSeconds,
+		"--rlimit_fsize", strconv.FormatInt(run.limits.FileSizeLimit/int64(entities.SandboxMemoryMB), 10),
+		"--rlimit_nofile", strconv.FormatInt(run.limits.OpenFilesLimit, 10),
+		"--rlimit_nproc", "max",
+		"--seccomp_string", entities.SandboxNsjailSeccompPolicy,
+		"--env", "PATH=" + os.Getenv("PATH"),
+		"--env", "PYTHONUNBUFFERED=1",
+		"--env", "HOME=/tmp",
+		"--mount", fmt.Sprintf("none:/tmp:tmpfs:size=%d", run.limits.TmpfsSize),
+	}
+
+	for _, dir := range nsjailSystemMounts {
+		if _, err := os.Stat(dir); err == nil {
+			args = append(args, "--bindmount_ro", dir)
+		}
+	}
+	for _, device := range nsjailDevices {
+		args = append(args, "--bindmount", device)
+	}
+	args = append(args, run.mounts...)
+
+	return append(args, "--", "/bin/sh", "-c", run.command)
+}
+
+// nsjailProcess is a started nsjail.
+type nsjailProcess struct {
+	cmd      *exec.Cmd
+	logFile  string
+	wallTime time.Duration
+	timedOut atomic.Bool
+	// oomKills is the number of processes of the run the OOM killer killed.
+	oomKills uint
+	done     chan struct{}
+}
+
+// kill kills nsjail, which takes the program down with it.
+func (p *nsjailProcess) kill() {
+	p.cmd.Process.Kill()
+}
+
+// start starts nsjail for the run. nsjail is killed when the time limit is reached or when
+// a write to stdout or stderr fails with errOutputLimitExceeded.
+// Every run gets a cgroup of its own, whose memory events still count the OOM kills of the
+// program after nsjail removed the cgroup of the program.
+func (s *nsjailSandboxService) start(run nsjailRun, stdin io.Reader, stdout, stderr io.Writer) (*nsjailProcess, error) {
+	run.cgroupDir = filepath.Join(nsjailCgroupRoot, "code-judge-system-"+generateID())
+	err := os.Mkdir(run.cgroupDir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	process := &nsjailProcess{
+		cmd:     exec.Command(s.nsjailPath, s.args(run)...),
+		logFile: run.logFile,
+		done:    make(chan struct{}),
+	}
+	process.cmd.Stdin = stdin
+	process.cmd.Stdout = &killOnExceedWriter{writer: stdout, kill: process.kill}
+	process.cmd.Stderr = &killOnExceedWriter{writer: stderr, kill: process.kill}
+
+	err = process.cmd.Start()
+	if err != nil {
+		removeCgroup(run.cgroupDir)
+		return nil, err
+	}
+	s.mutex.Lock()
+	s.processes[process] = true
+	s.mutex.Unlock()
+
+	started := time.Now()
+	timer := time.AfterFunc(time.Duration(run.timeLimit)*time.Millisecond, func() {
+		process.timedOut.Store(true)
+		process.kill()
+	})
+
+	go func() {
+		// the error of an output copy is reported through the output limiter
+		process.cmd.Wait()
+		process.wallTime = time.Since(started)
+		timer.Stop()
+
+		oomKills, err := readOOMKills(run.cgroupDir)
+		if err != nil {
+			log.Println("failed to read OOM kills of nsjail:", err)
+		}
+		process.oomKills = oomKills
+		removeCgroup(run.cgroupDir)
+
+		s.mutex.Lock()
+		delete(s.processes, process)
+		s.mutex.Unlock()
+		close(process.done)
+	}()
+
+	return process, nil
+}
+
+// wait waits for nsjail to exit and returns the result of the program.
+// The time and output limits are enforced by killing nsjail.
+func (p *nsjailProcess) wait() (*entities.SandboxRunResult, error) {
+	<-p.done
+
+	state := p.cmd.ProcessState
+	killed := state.ExitCode() < 0
+
+	// nsjail exits with 255 when it fails to set up the jail
+	if !killed && state.ExitCode() == 255 {
+		nsjailLog, _ := os.ReadFile(p.logFile)
+		if bytes.Contains(nsjailLog, []byte("[E]")) || bytes.Contains(nsjailLog, []byte("[F]")) {
+			log.Println("nsjail failed:", string(nsjailLog))
+			return nil, errors.New("nsjail failed to run the program")
+		}
+	}
+
+	result := &entities.SandboxRunResult{
+		ExitCode:  state.ExitCode(),
+		Timeout:   p.timedOut.Load(),
+		OOMKilled: p.oomKills > 0,
+	}
+	if killed {
+		result.ExitCode = 128 + int(syscall.SIGKILL)
+	}
+
+	// the usage of nsjail includes the program it waited for
+	if rusage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		cpuTime := time.Duration(rusage.Utime.Nano() + rusage.Stime.Nano())
+		result.TimeMs = uint(cpuTime.Milliseconds())
+		result.MemoryUsage = uint(rusage.Maxrss) * 1024
+	}
+	if result.Timeout {
+		result.TimeMs = uint(p.wallTime.Milliseconds())
+	}
+
+	return result, nil
+}
+
+// readOOMKills returns the number of processes the OOM killer killed in the cgroup,
+// including those of its child cgroups.
+func readOOMKills(cgroupDir string) (uint, error) {
+	events, err := os.ReadFile(filepath.Join(cgroupDir, "memory.events"))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range strings.Split(string(events), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			oomKills, err := strconv.ParseUint(fields[1], 10, 64)
+			return uint(oomKills), err
+		}
+	}
+	return 0, nil
+}
+
+// removeCgroup removes the cgroup of a run with the cgroups nsjail left in it when it was killed.
+func removeCgroup(cgroupDir string) {
+	entries, _ := os.ReadDir(cgroupDir)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			os.Remove(filepath.Join(cgroupDir, entry.Name()))
+		}
+	}
+
+	err := os.Remove(cgroupDir)
+	if err != nil {
+		log.Println("failed to remove cgroup", cgroupDir, err)
+	}
+}
+
+// killOnExceedWriter kills the program once its output exceeds the limit, so it does not
+// block on a full pipe until the time limit.
+type killOnExceedWriter struct {
+	writer io.Writer
+	kill   func()
+}
+
+// Write implements io.Writer.
+func (w *killOnExceedWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	if errors.Is(err, errOutputLimitExceeded) {
+		w.kill()
+	}
+	return n, err
+}
+
+// peerWriter writes into the stdin of the other program of an interactive run.
+// Its errors are ignored, so a program that exited does not stop the output of the other one.
+type peerWriter struct {
+	writer io.Writer
+}
+
+// Write implements io.Writer.
+func (w *peerWriter) Write(p []byte) (int, error) {
+	w.writer.Write(p)
+	return len(p), nil
+}
+
+// createRunDir creates a directory for the files and the nsjail log of a run.
+// The files are readable by the sandbox user.
+func (s *nsjailSandboxService) createRunDir(instance *entities.SandboxInstance, files map[string]string) (string, error) {
+	runDir, err := os.MkdirTemp(s.workDir, fmt.Sprintf("code-judge-system-%s-run-", instance.RunID))
+	if err != nil {
+		return "", err
+	}
+
+	stdinDir := filepath.Join(runDir, "stdin")
+	err = os.Mkdir(stdinDir, 0755)
+	if err == nil {
+		err = os.Chmod(stdinDir, 0755)
+	}
+	for path, content := range files {
+		if err != nil {
+			break
+		}
+		err = os.WriteFile(filepath.Join(stdinDir, strings.TrimPrefix(path, "/stdin/")), []byte(content), 0644)
+	}
+	if err != nil {
+		os.RemoveAll(runDir)
+		return "", err
+	}
+
+	return runDir, nil
+}
+
+// CreateSandbox implements SandboxService.
+func (s *nsjailSandboxService) CreateSandbox(lang string, code string) (*entities.SandboxInstance, error) {
+	instance := &entities.SandboxInstance{
+		RunID:    generateID(),
+		Language: lang,
+		Code:     code,
+	}
+
+	instruction, err := s.languageService.GetInstruction(instance.Language)
+	if err != nil {
+		return nil, err
+	}
+	instance.Instruction = instruction
+	instance.TimeMultiplier = instruction.TimeMultiplier
+	instance.MemoryMultiplier = instruction.MemoryMultiplier
+
+	_, err = exec.LookPath(s.nsjailPath)
+	if err != nil {
+		return nil, fmt.Errorf("nsjail not found: %w", err)
+	}
+
+	return instance, nil
+}
+
+// CompileSandbox implements SandboxService.
+func (s *nsjailSandboxService) CompileSandbox(instance *entities.SandboxInstance) (result *entities.SandboxRunResult) {
+	log.Println("start compiling sandbox", instance.RunID)
+
+	result = &entities.SandboxRunResult{}
+
+	programDir, err := os.MkdirTemp(s.workDir, fmt.Sprintf("code-judge-system-%s-program-", instance.RunID))
+	if err != nil {
+		result.Err = errors.New("compile stage: failed to create program directory")
+		return
+	}
+	instance.ProgramDir = programDir
+	s.mutex.Lock()
+	s.programDirs[programDir] = true
+	s.mutex.Unlock()
+
+	// the compiler writes into the program directory as the sandbox user
+	err = os.Chmod(programDir, 0777)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(programDir, instance.Instruction.SourceFile), []byte(instance.Code), 0644)
+	}
+	if err != nil {
+		result.Err = errors.New("compile stage: failed to write code")
+		return
+	}
+
+	compileCommand := instance.Instruction.CompileCmd
+
+	// interpreted languages have nothing to compile
+	if compileCommand == "" {
+		log.Println("compiling skipped", instance.RunID)
+		return
+	}
+
+	runDir, err := s.createRunDir(instance, nil)
+	if err != nil {
+		result.Err = errors.New("compile stage: failed to create run directory")
+		return
+	}
+	defer os.RemoveAll(runDir)
+
+	var stdout, stderr bytes.Buffer
+	limiter := newOutputLimiter(s.outputLimit)
+	process, err := s.start(nsjailRun{
+		command:     compileCommand,
+		mounts:      []string{"--bindmount", programDir + ":/sandbox"},
+		memoryLimit: entities.SandboxMemoryGB * 1,
+		timeLimit:   instance.Instruction.CompileTimeout,
+		limits:      entities.SandboxCompileLimits,
+		logFile:     filepath.Join(runDir, "nsjail.log"),
+	}, nil, limiter.Writer(&stdout), limiter.Writer(&stderr))
+	if err != nil {
+		result.Err = errors.New("compile stage: failed to start nsjail")
+		return
+	}
+
+	compile, err := process.wait()
+	instance.CompileTimeMs = uint(process.wallTime.Milliseconds())
+	if err != nil {
+		result.Err = errors.New("compile stage: failed to compile code")
+		return
+	}
+
+	instance.CompileExitCode = compile.ExitCode
+	instance.CompileStdout = stdout.String()
+	instance.CompileStderr = stderr.String()
+
+	result.ExitCode = compile.ExitCode
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	result.Timeout = compile.Timeout
+	result.OutputLimitExceeded = limiter.Exceeded()
+
+	if result.Timeout {
+		result.Err = errors.New("compile stage: compile time limit exceeded")
+		return
+	}
+
+	if result.OutputLimitExceeded {
+		result.Err = errors.New("compile stage: compile output limit exceeded")
+		return
+	}
+
+	if instance.CompileExitCode != 0 {
+		result.Err = errors.New("compile stage: failed to compile code")
+		return
+	}
+
+	log.Println("compiling done", instance.RunID)
+	return
+}
+
+// Run implements SandboxService.
+// The limits are those of the testcase and are scaled by the multipliers of the sandbox.
+func (s *nsjailSandboxService) Run(instance *entities.SandboxInstance, stdin string, memoryLimit, timeLimit uint) (result *entities.SandboxRunResult) {
+	err := s.validateLimits(memoryLimit, timeLimit)
+	if err != nil {
+		return &entities.SandboxRunResult{Err: err}
+	}
+
+	runCommand := instance.Instruction.RunCommand(memoryLimit) + " < /stdin/stdin"
+	memoryLimit, timeLimit = s.applyMultipliers(instance, memoryLimit, timeLimit)
+
+	return s.run(
+		instance,
+		runCommand,
+		map[string]string{"/stdin/stdin": stdin},
+		memoryLimit,
+		timeLimit,
+	)
+}
+
+// RunChecker implements SandboxService.
+// The checker is started testlib-style with input, contestant output and expected answer file paths.
+func (s *nsjailSandboxService) RunChecker(instance *entities.SandboxInstance, input, output, answer string, memoryLimit, timeLimit uint) (result *entities.SandboxRunResult) {
+	return s.run(
+		instance,
+		instance.Instruction.RunCommand(memoryLimit)+" /stdin/input /stdin/output /stdin/answer",
+		map[string]string{
+			"/stdin/input":  input,
+			"/stdin/output": output,
+			"/stdin/answer": answer,
+		},
+		memoryLimit,
+		timeLimit,
+	)
+}
+
+// run starts the program with runCommand after writing files into its read-only /stdin.
+func (s *nsjailSandboxService) run(instance *entities.SandboxInstance, runCommand string, files map[string]string, memoryLimit, timeLimit uint) (result *entities.SandboxRunResult) {
+	result = &entities.SandboxRunResult{}
+
+	err := s.validateLimits(memoryLimit, timeLimit)
+	if err != nil {
+		result.Err = err
+		return
+	}
+
+	runDir, err := s.createRunDir(instance, files)
+	if err != nil {
+		result.Err = errors.New("run stage: failed to write stdin")
+		return
+	}
+	defer os.RemoveAll(runDir)
+
+	var stdout, stderr bytes.Buffer
+	limiter := newOutputLimiter(s.outputLimit)
+	process, err := s.start(nsjailRun{
+		command: runCommand,
+		mounts: []string{
+			"--bindmount_ro", instance.ProgramDir + ":/sandbox",
+			"--bindmount_ro", filepath.Join(runDir, "stdin") + ":/stdin",
+		},
+		memoryLimit: memoryLimit,
+		timeLimit:   timeLimit,
+		limits:      entities.SandboxRunLimits,
+		logFile:     filepath.Join(runDir, "nsjail.log"),
+	}, nil, limiter.Writer(&stdout), limiter.Writer(&stderr))
+	if err != nil {
+		result.Err = errors.New("run stage: failed to start nsjail")
+		return
+	}
+
+	result, err = process.wait()
+	if err != nil {
+		return &entities.SandboxRunResult{Err: errors.New("run stage: failed to run program")}
+	}
+
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	result.OutputLimitExceeded = limiter.Exceeded()
+	return
+}
+
+// RunInteractive implements SandboxService.
+// The program and the interactor run in separate jails and the stdout of each one
+// is piped into the stdin of the other. The interactor is started testlib-style with
+// the testcase input, an output file and the expected answer.
+func (s *nsjailSandboxService) RunInteractive(instance, interactor *entities.SandboxInstance, input, answer string, memoryLimit, timeLimit uint) (result, interactorResult *entities.SandboxRunResult) {
+	result = &entities.SandboxRunResult{}
+	interactorResult = &entities.SandboxRunResult{}
+
+	err := s.validateLimits(memoryLimit, timeLimit)
+	if err != nil {
+		result.Err = err
+		return
+	}
+
+	programRunCommand := instance.Instruction.RunCommand(memoryLimit)
+	memoryLimit, timeLimit = s.applyMultipliers(instance, memoryLimit, timeLimit)
+
+	runDir, err := s.createRunDir(interactor, map[string]string{
+		"/stdin/input":  input,
+		"/stdin/answer": answer,
+	})
+	if err != nil {
+		result.Err = errors.New("interactive stage: failed to write files")
+		return
+	}
+	defer os.RemoveAll(runDir)
+
+	programStdin, interactorToProgram, err := os.Pipe()
+	if err != nil {
+		result.Err = errors.New("interactive stage: failed to create pipe")
+		return
+	}
+	defer interactorToProgram.Close()
+	defer programStdin.Close()
+
+	interactorStdin, programToInteractor, err := os.Pipe()
+	if err != nil {
+		result.Err = errors.New("interactive stage: failed to create pipe")
+		return
+	}
+	defer programToInteractor.Close()
+	defer interactorStdin.Close()
+
+	// wire program stdout to interactor stdin and interactor stdout to program stdin
+	var programStdout, programStderr, interactorStdout, interactorStderr bytes.Buffer
+	programLimiter := newOutputLimiter(s.outputLimit)
+	interactorLimiter := newOutputLimiter(s.outputLimit)
+
+	interactorProcess, err := s.start(nsjailRun{
+		command: interactor.Instruction.RunCommand(entities.SandboxCheckerMemoryLimit) + " /stdin/input /tmp/output /stdin/answer",
+		mounts: []string{
+			"--bindmount_ro", interactor.ProgramDir + ":/sandbox",
+			"--bindmount_ro", filepath.Join(runDir, "stdin") + ":/stdin",
+		},
+		memoryLimit: entities.SandboxCheckerMemoryLimit,
+		timeLimit:   timeLimit + entities.SandboxInteractorTimeGraceMs,
+		limits:      entities.SandboxRunLimits,
+		logFile:     filepath.Join(runDir, "interactor.log"),
+	},
+		interactorStdin,
+		io.MultiWriter(&peerWriter{writer: interactorToProgram}, interactorLimiter.Writer(&interactorStdout)),
+		interactorLimiter.Writer(&interactorStderr),
+	)
+	if err != nil {
+		result.Err = errors.New("interactive stage: failed to start interactor")
+		return
+	}
+
+	programProcess, err := s.start(nsjailRun{
+		command:     programRunCommand,
+		mounts:      []string{"--bindmount_ro", instance.ProgramDir + ":/sandbox"},
+		memoryLimit: memoryLimit,
+		timeLimit:   timeLimit,
+		limits:      entities.SandboxRunLimits,
+		logFile:     filepath.Join(runDir, "program.log"),
+	},
+		programStdin,
+		io.MultiWriter(&peerWriter{writer: programToInteractor}, programLimiter.Writer(&programStdout)),
+		programLimiter.Writer(&programStderr),
+	)
+	if err != nil {
+		interactorProcess.kill()
+		<-interactorProcess.done
+		result.Err = errors.New("interactive stage: failed to start program")
+		return
+	}
+
+	// the jails have their own ends of the pipes, an exited side closes its output end
+	// so the other one reads end of file
+	programStdin.Close()
+	interactorStdin.Close()
+	go func() {
+		<-programProcess.done
+		programToInteractor.Close()
+	}()
+	go func() {
+		<-interactorProcess.done
+		interactorToProgram.Close()
+	}()
+
+	programResult, programErr := programProcess.wait()
+	interactorRunResult, interactorErr := interactorProcess.wait()
+	if programErr != nil || interactorErr != nil {
+		result.Err = errors.New("interactive stage: failed to run program")
+		return
+	}
+
+	result = programResult
+	result.Stdout = programStdout.String()
+	result.Stderr = programStderr.String()
+	result.OutputLimitExceeded = programLimiter.Exceeded()
+
+	interactorResult = interactorRunResult
+	interactorResult.Stdout = interactorStdout.String()
+	interactorResult.Stderr = interactorStderr.String()
+	interactorResult.OutputLimitExceeded = interactorLimiter.Exceeded()
+
+	return
+}
+
+// CleanUp implements SandboxService.
+func (s *nsjailSandboxService) CleanUp(instance *entities.SandboxInstance) error {
+	if instance.ProgramDir == "" {
+		return nil
+	}
+
+	s.mutex.Lock()
+	delete(s.programDirs, instance.ProgramDir)
+	s.mutex.Unlock()
+	return os.RemoveAll(instance.ProgramDir)
+}
+
+// Close implements SandboxService.
+// Running programs are killed and the program directories of unfinished runs are removed.
+func (s *nsjailSandboxService) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for process := range s.processes {
+		process.kill()
+	}
+
+	var errs []error
+	for programDir := range s.programDirs {
+		errs = append(errs, os.RemoveAll(programDir))
+	}
+	s.programDirs = map[string]bool{}
+	return errors.Join(errs...)
+}
+
+// ValidateLanguage implements SandboxService.
+func (s *nsjailSandboxService) ValidateLanguage(language string) (err error) {
+	return s.languageService.ValidateLanguage(language)
+}
+
+// NewNsjailSandboxService creates a sandbox service that runs programs with the nsjail binary
+// at nsjailPath and keeps their files under workDir. The limits are those of NewSandboxService.
+func NewNsjailSandboxService(languageService LanguageService, memoryLimit uint, timeLimit uint, outputLimit int, nsjailPath, workDir string) SandboxService {
+	return &nsjailSandboxService{
+		sandboxLimits:   sandboxLimits{memoryLimit: memoryLimit, timeLimit: timeLimit},
+		languageService: languageService,
+		nsjailPath:      nsjailPath,
+		workDir:         workDir,
+		outputLimit:     outputLimit,
+		processes:       map[*nsjailProcess]bool{},
+		programDirs:     map[string]bool{},
+	}
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+var errOutputLimitExceeded = errors.New("output limit exceeded")
+
+// outputLimiter caps the combined size of the outputs written through its writers.
+type outputLimiter struct {
+	mutex     sync.Mutex
+	remaining int
+	exceeded  bool
+}
+
+// Writer returns a writer into w that fails with errOutputLimitExceeded once the limit is reached.
+func (l *outputLimiter) Writer(w io.Writer) io.Writer {
+	return &limitedWriter{limiter: l, writer: w}
+}
+
+// Exceeded reports whether more output than the limit was written.
+func (l *outputLimiter) Exceeded() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.exceeded
+}
+
+type limitedWriter struct {
+	limiter *outputLimiter
+	writer  io.Writer
+}
+
+// Write implements io.Writer.
+// The part of p that fits in the limit is still written before the error is returned.
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	w.limiter.mutex.Lock()
+	defer w.limiter.mutex.Unlock()
+
+	if len(p) <= w.limiter.remaining {
+		w.limiter.remaining -= len(p)
+		return w.writer.Write(p)
+	}
+
+	n, err := w.writer.Write(p[:w.limiter.remaining])
+	w.limiter.remaining = 0
+	w.limiter.exceeded = true
+	if err != nil {
+		return n, err
+	}
+	return n, errOutputLimitExceeded
+}
+
+func newOutputLimiter(limit int) *outputLimiter {
+	return &outputLimiter{remaining: limit}
+}
@@ -1,13 +1,20 @@
 package services
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/wuttinanhi/code-judge-system/entities"
 )
 
@@ -15,32 +22,109 @@ type SandboxService interface {
 	CreateSandbox(lang, code string) (*entities.SandboxInstance, error)
 	CompileSandbox(instance *entities.SandboxInstance) (result *entities.SandboxRunResult)
 	Run(instance *entities.SandboxInstance, stdin string, memoryLimit, timeLimit uint) (result *entities.SandboxRunResult)
+	RunChecker(instance *entities.SandboxInstance, input, output, answer string, memoryLimit, timeLimit uint) (result *entities.SandboxRunResult)
+	RunInteractive(instance, interactor *entities.SandboxInstance, input, answer string, memoryLimit, timeLimit uint) (result, interactorResult *entities.SandboxRunResult)
 	CleanUp(instance *entities.SandboxInstance) error
+	Close() error
 	ValidateMemoryLimit(memoryLimit uint) (err error)
 	ValidateTimeLimit(timeLimit uint) (err error)
+	ValidateLanguage(language string) (err error)
+}
+
+// sandboxLimits are the maximum limits of a sandbox backend, the memory limit in megabytes
+// and the time limit in milliseconds.
+type sandboxLimits struct {
+	memoryLimit uint
+	timeLimit   uint
 }
 
 type sandboxService struct {
-	dockerService DockerService
-	memoryLimit   uint
-	timeLimit     uint
+	sandboxLimits
+	// dockerService tracks containers and volumes so Close removes those of interrupted runs.
+	dockerService   *trackedDockerService
+	languageService LanguageService
+	outputLimit     int
+	// pool runs programs in warm containers, nil when the pool is disabled.
+	pool ContainerPool
+}
+
+// createCompiledSandbox creates and compiles a sandbox for a staff supplied program
+// such as a checker or an interactor.
+func createCompiledSandbox(sandboxService SandboxService, language, code string) (*entities.SandboxInstance, error) {
+	sandbox, err := sandboxService.CreateSandbox(language, code)
+	if err != nil {
+		return nil, err
+	}
+
+	compile := sandboxService.CompileSandbox(sandbox)
+	if compile.Err != nil {
+		sandboxService.CleanUp(sandbox)
+		return nil, compile.Err
+	}
+
+	return sandbox, nil
+}
+
+// withUsage wraps a run command so the container reports the CPU time, peak memory and
+// OOM kills of the program on stderr after it exits, keeping the program's exit code.
+// Processes the program left behind are killed, so they neither hold its output open
+// nor outlive it in a pooled container.
+func withUsage(command string) string {
+	return entities.SandboxUsageStartScript + "; " + command + "; code=$?; kill -9 -1 2>/dev/null; " + entities.SandboxUsageScript + "; exit $code"
+}
+
+// applyUsage moves the usage reported by withUsage from stderr into the result.
+// The wall time is used when the program did not report its usage, e.g. on timeout.
+func applyUsage(result *entities.SandboxRunResult, wallTime time.Duration) {
+	var usage entities.SandboxUsage
+	var ok bool
+
+	result.Stderr, usage, ok = entities.ParseSandboxUsage(result.Stderr)
+
+	if !ok || result.Timeout {
+		usage.TimeMs = uint(wallTime.Milliseconds())
+	}
+
+	result.TimeMs = usage.TimeMs
+	result.MemoryUsage = usage.MemoryUsage
+	result.OOMKilled = result.OOMKilled || usage.OOMKills > 0
+}
+
+// sandboxCopyTimeoutMs is how long the helper container may take to prepare the volumes.
+const sandboxCopyTimeoutMs = 10000
+
+// generatedIDs keeps IDs unique when workers create sandboxes at the same time.
+var generatedIDs uint64
+
+// sandboxLabels returns the labels of a container or volume created now for instance.
+func sandboxLabels(instance *entities.SandboxInstance) map[string]string {
+	return entities.SandboxLabels(instance.RunID, instance.SubmissionID, time.Now())
 }
 
 func generateID() string {
-	return strconv.FormatInt(time.Now().UnixNano(), 10)
+	return strconv.FormatInt(time.Now().UnixNano(), 10) + "-" + strconv.FormatUint(atomic.AddUint64(&generatedIDs, 1), 10)
 }
 
+// CopyFileToVolume copies files into the volumes through a helper container, which first
+// makes the volumes writable for the unprivileged sandbox user.
 func (s *sandboxService) CopyFileToVolume(instance *entities.SandboxInstance, volumeMount []mount.Mount, fileContentMap map[string]string) error {
+	targets := make([]string, len(volumeMount))
+	for i, m := range volumeMount {
+		targets[i] = m.Target
+	}
+
 	// create container to store necessary files
 	containerName := fmt.Sprintf("%s-copy-%s", instance.RunID, generateID())
-	resp, err := s.dockerService.CreateContainer(
-		instance.ImageName,
-		// chmod 777 -R /sandbox &&
-		[]string{"/bin/sh", "-c", "sleep 9999"},
-		volumeMount,
-		int64(entities.SandboxMemoryMB*512),
-		containerName,
-	)
+	resp, err := s.dockerService.CreateContainer(ContainerConfig{
+		Name:        containerName,
+		Image:       instance.ImageName,
+		Command:     append([]string{"chmod", "777"}, targets...),
+		Mounts:      volumeMount,
+		MemoryLimit: int64(entities.SandboxMemoryMB * 512),
+		Limits:      entities.SandboxRunLimits,
+		Labels:      sandboxLabels(instance),
+		Helper:      true,
+	})
 	if err != nil {
 		return err
 	}
@@ -52,8 +136,11 @@ func (s *sandboxService) CopyFileToVolume(instance *entities.SandboxInstance, vo
 		return errors.New("copy: failed to start container")
 	}
 
-	// wait for container to ready
-	// time.Sleep(1 * time.Second)
+	// files are copied once chmod is done, copying works on stopped containers
+	waitResult := s.dockerService.WaitContainer(resp.ID, sandboxCopyTimeoutMs)
+	if waitResult != WaitResultSuccess {
+		return errors.New("copy: failed to prepare volume")
+	}
 
 	// copy file to container
 	for path, content := range fileContentMap {
@@ -75,10 +162,13 @@ func (s *sandboxService) CreateSandbox(lang string, code string) (*entities.Sand
 		Code:     code,
 	}
 
-	instance.Instruction = entities.GetSandboxInstructionByLanguage(instance.Language)
-	if instance.Instruction == nil {
-		return nil, fmt.Errorf("language %s not supported", instance.Language)
+	instruction, err := s.languageService.GetInstruction(instance.Language)
+	if err != nil {
+		return nil, err
 	}
+	instance.Instruction = instruction
+	instance.TimeMultiplier = instruction.TimeMultiplier
+	instance.MemoryMultiplier = instruction.MemoryMultiplier
 
 	instance.ImageName = instance.Instruction.DockerImage
 	if instance.ImageName == "" {
@@ -100,6 +190,10 @@ func (s *sandboxService) CreateSandbox(lang string, code string) (*entities.Sand
 		}
 	}
 
+	if s.pool != nil {
+		s.pool.Warm(instance.ImageName)
+	}
+
 	return instance, nil
 }
 
@@ -109,7 +203,7 @@ func (s *sandboxService) CompileSandbox(instance *entities.SandboxInstance) (res
 	result = &entities.SandboxRunResult{}
 
 	volumeName := fmt.Sprintf("code-judge-system-%s-program", instance.RunID)
-	volume, err := s.dockerService.CreateVolume(volumeName)
+	volume, err := s.dockerService.CreateVolume(volumeName, sandboxLabels(instance))
 	if err != nil {
 		result.Err = errors.New("compile stage: failed to create program volume")
 		return
@@ -121,7 +215,7 @@ func (s *sandboxService) CompileSandbox(instance *entities.SandboxInstance) (res
 	}
 
 	err = s.CopyFileToVolume(instance, programVolumeMount, map[string]string{
-		"/sandbox/code": instance.Code,
+		"/sandbox/" + instance.Instruction.SourceFile: instance.Code,
 	})
 	if err != nil {
 		result.Err = errors.New("compile stage: failed to copy code to container")
@@ -131,30 +225,57 @@ func (s *sandboxService) CompileSandbox(instance *entities.SandboxInstance) (res
 	compileCommand := instance.Instruction.CompileCmd
 	compileTimeout := instance.Instruction.CompileTimeout
 
-	resp, err := s.dockerService.CreateContainer(
-		instance.ImageName,
-		[]string{"/bin/sh", "-c", compileCommand},
-		programVolumeMount,
-		int64(entities.SandboxMemoryGB*1),
-		instance.RunID+"-compile",
-	)
+	// interpreted languages have nothing to compile
+	if compileCommand == "" {
+		if s.pool != nil {
+			instance.ProgramArchive, err = createArchive(map[string]string{
+				instance.Instruction.SourceFile: instance.Code,
+			})
+			if err != nil {
+				log.Println("failed to create program archive", instance.RunID, err)
+			}
+		}
+		log.Println("compiling skipped", instance.RunID)
+		return
+	}
+
+	resp, err := s.dockerService.CreateContainer(ContainerConfig{
+		Name:        instance.RunID + "-compile",
+		Image:       instance.ImageName,
+		Command:     []string{"/bin/sh", "-c", compileCommand},
+		Mounts:      programVolumeMount,
+		MemoryLimit: int64(entities.SandboxMemoryGB * 1),
+		Limits:      entities.SandboxCompileLimits,
+		Labels:      sandboxLabels(instance),
+	})
 	if err != nil {
 		result.Err = errors.New("compile stage: failed to create container")
 		return
 	}
 	defer s.dockerService.RemoveContainer(resp.ID)
 
+	compileStart := time.Now()
+
 	err = s.dockerService.StartContainer(resp.ID)
 	if err != nil {
 		result.Err = errors.New("compile stage: failed to start container")
 		return
 	}
+	outputC := s.captureOutput(resp.ID)
 
 	waitResult := s.dockerService.WaitContainer(resp.ID, compileTimeout)
+	instance.CompileTimeMs = uint(time.Since(compileStart).Milliseconds())
 	if waitResult == WaitResultError {
 		result.Err = errors.New("compile stage: failed to compile code")
 		return
 	}
+	if waitResult == WaitResultTimeout {
+		err = s.dockerService.StopContainer(resp.ID)
+		if err != nil {
+			result.Err = errors.New("compile stage: failed to stop container")
+			return
+		}
+	}
 
 	exitCode, err := s.dockerService.GetContainerExitCode(resp.ID)
 	if err != nil {
@@ -162,52 +283,167 @@ func (s *sandboxService) CompileSandbox(instance *entities.SandboxInstance) (res
 		return
 	}
 
-	compileStdOut, err := s.dockerService.GetLog(resp.ID, true, false)
-	if err != nil {
-		result.Err = errors.New("compile stage: failed to get stdout")
+	output := <-outputC
+	if output.err != nil {
+		result.Err = errors.New("compile stage: failed to get output")
 		return
 	}
 
-	compileStdErr, err := s.dockerService.GetLog(resp.ID, false, true)
-	if err != nil {
-		result.Err = errors.New("compile stage: failed to get stderr")
+	instance.CompileExitCode = exitCode
+	instance.CompileStdout = output.stdout
+	instance.CompileStderr = output.stderr
+
+	result.ExitCode = exitCode
+	result.Stdout = output.stdout
+	result.Stderr = output.stderr
+	result.Timeout = waitResult == WaitResultTimeout
+	result.OutputLimitExceeded = output.exceeded
+
+	if result.Timeout {
+		result.Err = errors.New("compile stage: compile time limit exceeded")
 		return
 	}
 
-	instance.CompileExitCode = exitCode
-	instance.CompileStdout = compileStdOut
-	instance.CompileStderr = compileStdErr
+	if result.OutputLimitExceeded {
+		result.Err = errors.New("compile stage: compile output limit exceeded")
+		return
+	}
 
 	if instance.CompileExitCode != 0 {
 		result.Err = errors.New("compile stage: failed to compile code")
 		return
 	}
 
+	// without an archive the program runs from its volume as usual
+	if s.pool != nil {
+		instance.ProgramArchive, err = s.programArchive(resp.ID)
+		if err != nil {
+			log.Println("failed to create program archive", instance.RunID, err)
+		}
+	}
+
 	log.Println("compiling done", instance.RunID)
 	return
 }
 
-// Run implements SandboxService.
-func (s *sandboxService) Run(instance *entities.SandboxInstance, stdin string, memoryLimit, timeLimit uint) (result *entities.SandboxRunResult) {
-	result = &entities.SandboxRunResult{}
+// programArchive copies the compiled program out of the /sandbox volume of a container.
+func (s *sandboxService) programArchive(containerID string) ([]byte, error) {
+	archive, err := s.dockerService.CopyFromContainer(containerID, "/sandbox")
+	if err != nil {
+		return nil, err
+	}
+	return stripArchivePrefix(archive, "sandbox")
+}
+
+// containerOutput is the output of a container captured while it runs.
+type containerOutput struct {
+	stdout   string
+	stderr   string
+	exceeded bool
+	err      error
+}
 
+// captureOutput follows the output of a started container in the background, stopping the
+// container when the output limit is exceeded. The output is sent once the container stops.
+func (s *sandboxService) captureOutput(containerID string) chan containerOutput {
+	outputC := make(chan containerOutput, 1)
+	go func() {
+		var output containerOutput
+		output.stdout, output.stderr, output.exceeded, output.err = s.dockerService.CaptureLog(containerID, s.outputLimit)
+		outputC <- output
+	}()
+	return outputC
+}
+
+// validateLimits checks the limits of a run against the sandbox maximum.
+func (s *sandboxLimits) validateLimits(memoryLimit, timeLimit uint) error {
 	maxMemoryErr := s.ValidateMemoryLimit(memoryLimit)
 	if maxMemoryErr != nil {
-		result.Err = errors.New("run stage: max memory exceeded sandbox limit")
-		return
+		return errors.New("run stage: max memory exceeded sandbox limit")
 	}
 
 	maxTimeLimitErr := s.ValidateTimeLimit(timeLimit)
 	if maxTimeLimitErr != nil {
-		result.Err = errors.New("run stage: max run time exceeded sandbox limit")
+		return errors.New("run stage: max run time exceeded sandbox limit")
+	}
+
+	return nil
+}
+
+// scaleLimit multiplies a limit and caps it at max.
+func scaleLimit(limit uint, multiplier float64, max uint) uint {
+	if multiplier <= 0 {
+		return limit
+	}
+
+	scaled := uint(math.Round(float64(limit) * multiplier))
+	if scaled > max {
+		return max
+	}
+	return scaled
+}
+
+// applyMultipliers scales the limits of a program run by the multipliers of the sandbox.
+// The scaled limits are capped at the sandbox maximum, so a multiplier can lift a valid
+// limit up to the maximum but never past it.
+func (s *sandboxLimits) applyMultipliers(instance *entities.SandboxInstance, memoryLimit, timeLimit uint) (uint, uint) {
+	return scaleLimit(memoryLimit, instance.MemoryMultiplier, entities.SandboxMemoryMB*s.memoryLimit),
+		scaleLimit(timeLimit, instance.TimeMultiplier, s.timeLimit)
+}
+
+// Run implements SandboxService.
+// The limits are those of the testcase and are scaled by the multipliers of the sandbox.
+func (s *sandboxService) Run(instance *entities.SandboxInstance, stdin string, memoryLimit, timeLimit uint) (result *entities.SandboxRunResult) {
+	err := s.validateLimits(memoryLimit, timeLimit)
+	if err != nil {
+		return &entities.SandboxRunResult{Err: err}
+	}
+
+	runCommand := instance.Instruction.RunCommand(memoryLimit) + " < /stdin/stdin"
+	memoryLimit, timeLimit = s.applyMultipliers(instance, memoryLimit, timeLimit)
+
+	return s.run(
+		instance,
+		runCommand,
+		map[string]string{"/stdin/stdin": stdin},
+		memoryLimit,
+		timeLimit,
+	)
+}
+
+// RunChecker implements SandboxService.
+// The checker is started testlib-style with input, contestant output and expected answer file paths.
+func (s *sandboxService) RunChecker(instance *entities.SandboxInstance, input, output, answer string, memoryLimit, timeLimit uint) (result *entities.SandboxRunResult) {
+	return s.run(
+		instance,
+		instance.Instruction.RunCommand(memoryLimit)+" /stdin/input /stdin/output /stdin/answer",
+		map[string]string{
+			"/stdin/input":  input,
+			"/stdin/output": output,
+			"/stdin/answer": answer,
+		},
+		memoryLimit,
+		timeLimit,
+	)
+}
+
+// run starts the program with runCommand after copying files into the read-only /stdin volume.
+func (s *sandboxService) run(instance *entities.SandboxInstance, runCommand string, files map[string]string, memoryLimit, timeLimit uint) (result *entities.SandboxRunResult) {
+	result = &entities.SandboxRunResult{}
+
+	err := s.validateLimits(memoryLimit, timeLimit)
+	if err != nil {
+		result.Err = err
 		return
 	}
 
-	runCommand := instance.Instruction.RunCmd
+	if s.pool != nil && instance.ProgramArchive != nil {
+		return s.runPooled(instance, runCommand, files, memoryLimit, timeLimit)
+	}
 
 	// create stdin volume
 	stdinVolumeName := fmt.Sprintf("code-judge-system-%s-%s-stdin", instance.RunID, generateID())
-	stdinVolume, err := s.dockerService.CreateVolume(stdinVolumeName)
+	stdinVolume, err := s.dockerService.CreateVolume(stdinVolumeName, sandboxLabels(instance))
 	if err != nil {
 		result.Err = errors.New("create stage: failed to create stdin volume")
 		return
@@ -220,9 +456,7 @@ func (s *sandboxService) Run(instance *entities.SandboxInstance, stdin string, m
 	}
 
 	// copy stdin to container
-	err = s.CopyFileToVolume(instance, stdinVolumeMount, map[string]string{
-		"/stdin/stdin": stdin,
-	})
+	err = s.CopyFileToVolume(instance, stdinVolumeMount, files)
 	if err != nil {
 		fmt.Println(err)
 		result.Err = errors.New("run stage: failed to copy stdin to container")
@@ -231,19 +465,21 @@ func (s *sandboxService) Run(instance *entities.SandboxInstance, stdin string, m
 
 	// create run volume mount
 	runVolumeMount := []mount.Mount{
-		{Type: mount.TypeVolume, Source: instance.ProgramVolume.Name, Target: "/sandbox"},
+		{Type: mount.TypeVolume, Source: instance.ProgramVolume.Name, Target: "/sandbox", ReadOnly: true},
 		{Type: mount.TypeVolume, Source: stdinVolumeName, Target: "/stdin", ReadOnly: true},
 	}
 
 	// create container to run
 	containerName := fmt.Sprintf("%s-run-%s", instance.RunID, generateID())
-	resp, err := s.dockerService.CreateContainer(
-		instance.ImageName,
-		[]string{"/bin/sh", "-c", runCommand},
-		runVolumeMount,
-		int64(memoryLimit),
-		containerName,
-	)
+	resp, err := s.dockerService.CreateContainer(ContainerConfig{
+		Name:        containerName,
+		Image:       instance.ImageName,
+		Command:     []string{"/bin/sh", "-c", withUsage(runCommand)},
+		Mounts:      runVolumeMount,
+		MemoryLimit: int64(memoryLimit),
+		Limits:      entities.SandboxRunLimits,
+		Labels:      sandboxLabels(instance),
+	})
 	if err != nil {
 		result.Err = errors.New("run stage: failed to create container")
 		return
@@ -251,14 +487,17 @@ func (s *sandboxService) Run(instance *entities.SandboxInstance, stdin string, m
 	defer s.dockerService.RemoveContainer(resp.ID)
 
 	// start container
+	runStart := time.Now()
 	err = s.dockerService.StartContainer(resp.ID)
 	if err != nil {
 		result.Err = errors.New("run stage: failed to start container")
 		return
 	}
+	outputC := s.captureOutput(resp.ID)
 
 	// wait for container to finish
 	waitResult := s.dockerService.WaitContainer(resp.ID, timeLimit)
+	wallTime := time.Since(runStart)
 	if waitResult == WaitResultError {
 		result.Err = errors.New("run stage: failed to wait container")
 		return
@@ -271,33 +510,292 @@ func (s *sandboxService) Run(instance *entities.SandboxInstance, stdin string, m
 		}
 	}
 
-	// get container exit code
-	exitCode, err := s.dockerService.GetContainerExitCode(resp.ID)
+	// get container state for exit code and OOM killer status
+	state, err := s.dockerService.GetContainerState(resp.ID)
 	if err != nil {
-		result.Err = errors.New("run stage: failed to get container exit code")
+		result.Err = errors.New("run stage: failed to get container state")
 		return
 	}
 
-	// get container stdout
-	stdout, err := s.dockerService.GetLog(resp.ID, true, false)
+	// get container stdout and stderr
+	output := <-outputC
+	if output.err != nil {
+		result.Err = errors.New("run stage: failed to get container output")
+		return
+	}
+
+	result.ExitCode = state.ExitCode
+	result.OOMKilled = state.OOMKilled
+	result.Stdout = output.stdout
+	result.Stderr = output.stderr
+	result.Timeout = waitResult == WaitResultTimeout
+	result.OutputLimitExceeded = output.exceeded
+	applyUsage(result, wallTime)
+
+	// return instance
+	return
+}
+
+// runPooled runs the program like run in a warm container of the pool. The program and
+// the files are copied into the container and the program is started with exec as
+// SandboxUser, which cannot write to /sandbox and /stdin.
+func (s *sandboxService) runPooled(instance *entities.SandboxInstance, runCommand string, files map[string]string, memoryLimit, timeLimit uint) (result *entities.SandboxRunResult) {
+	result = &entities.SandboxRunResult{}
+
+	container, err := s.pool.Acquire(instance.ImageName)
 	if err != nil {
-		result.Err = errors.New("run stage: failed to get container stderr")
+		result.Err = errors.New("run stage: failed to acquire container")
 		return
 	}
+	defer s.pool.Release(container)
 
-	// get container stderr
-	stderr, err := s.dockerService.GetLog(resp.ID, false, true)
+	err = s.dockerService.UpdateContainerMemory(container.ID, int64(memoryLimit))
 	if err != nil {
-		result.Err = errors.New("run stage: failed to get container stderr")
+		result.Err = errors.New("run stage: failed to set container memory limit")
 		return
 	}
 
-	result.ExitCode = exitCode
-	result.Stdout = stdout
-	result.Stderr = stderr
-	result.Timeout = waitResult == WaitResultTimeout
+	err = s.dockerService.CopyArchiveToContainer(container.ID, "/sandbox", instance.ProgramArchive)
+	if err != nil {
+		result.Err = errors.New("run stage: failed to copy program to container")
+		return
+	}
+
+	// the files are copied on their own since the root filesystem is read-only
+	stdinFiles := make(map[string]string, len(files))
+	for path, content := range files {
+		stdinFiles[strings.TrimPrefix(path, "/stdin/")] = content
+	}
+	stdinArchive, err := createArchive(stdinFiles)
+	if err != nil {
+		result.Err = errors.New("run stage: failed to create stdin archive")
+		return
+	}
+	err = s.dockerService.CopyArchiveToContainer(container.ID, "/stdin", stdinArchive)
+	if err != nil {
+		result.Err = errors.New("run stage: failed to copy stdin to container")
+		return
+	}
+
+	runStart := time.Now()
+	execResult, err := s.dockerService.ExecContainer(
+		container.ID,
+		entities.SandboxUser,
+		[]string{"/bin/sh", "-c", withUsage(runCommand)},
+		timeLimit,
+		s.outputLimit,
+	)
+	wallTime := time.Since(runStart)
+	if err != nil {
+		result.Err = errors.New("run stage: failed to run program")
+		return
+	}
+
+	result.ExitCode = execResult.ExitCode
+	result.Stdout = execResult.Stdout
+	result.Stderr = execResult.Stderr
+	result.Timeout = execResult.Timeout
+	result.OutputLimitExceeded = execResult.OutputLimitExceeded
+	applyUsage(result, wallTime)
+
+	return
+}
+
+// RunInteractive implements SandboxService.
+// The program and the interactor run in separate containers and the stdout of each one
+// is piped into the stdin of the other. The interactor is started testlib-style with
+// the testcase input, an output file and the expected answer.
+func (s *sandboxService) RunInteractive(instance, interactor *entities.SandboxInstance, input, answer string, memoryLimit, timeLimit uint) (result, interactorResult *entities.SandboxRunResult) {
+	result = &entities.SandboxRunResult{}
+	interactorResult = &entities.SandboxRunResult{}
+
+	err := s.validateLimits(memoryLimit, timeLimit)
+	if err != nil {
+		result.Err = err
+		return
+	}
+
+	programRunCommand := instance.Instruction.RunCommand(memoryLimit)
+	memoryLimit, timeLimit = s.applyMultipliers(instance, memoryLimit, timeLimit)
+
+	// create volume for interactor files
+	filesVolumeName := fmt.Sprintf("code-judge-system-%s-%s-stdin", instance.RunID, generateID())
+	filesVolume, err := s.dockerService.CreateVolume(filesVolumeName, sandboxLabels(instance))
+	if err != nil {
+		result.Err = errors.New("interactive stage: failed to create files volume")
+		return
+	}
+	defer s.dockerService.DeleteVolume(filesVolume)
+
+	err = s.CopyFileToVolume(interactor, []mount.Mount{
+		{Type: mount.TypeVolume, Source: filesVolumeName, Target: "/stdin"},
+	}, map[string]string{
+		"/stdin/input":  input,
+		"/stdin/answer": answer,
+	})
+	if err != nil {
+		result.Err = errors.New("interactive stage: failed to copy files to container")
+		return
+	}
+
+	// create program container
+	programResp, err := s.dockerService.CreateContainer(ContainerConfig{
+		Name:    fmt.Sprintf("%s-run-%s", instance.RunID, generateID()),
+		Image:   instance.ImageName,
+		Command: []string{"/bin/sh", "-c", withUsage(programRunCommand)},
+		Mounts: []mount.Mount{
+			{Type: mount.TypeVolume, Source: instance.ProgramVolume.Name, Target: "/sandbox", ReadOnly: true},
+		},
+		MemoryLimit: int64(memoryLimit),
+		Limits:      entities.SandboxRunLimits,
+		Labels:      sandboxLabels(instance),
+		Interactive: true,
+	})
+	if err != nil {
+		result.Err = errors.New("interactive stage: failed to create program container")
+		return
+	}
+	defer s.dockerService.RemoveContainer(programResp.ID)
+
+	// create interactor container
+	interactorResp, err := s.dockerService.CreateContainer(ContainerConfig{
+		Name:    fmt.Sprintf("%s-interactor-%s", instance.RunID, generateID()),
+		Image:   interactor.ImageName,
+		Command: []string{"/bin/sh", "-c", interactor.Instruction.RunCommand(entities.SandboxCheckerMemoryLimit) + " /stdin/input /tmp/output /stdin/answer"},
+		Mounts: []mount.Mount{
+			{Type: mount.TypeVolume, Source: interactor.ProgramVolume.Name, Target: "/sandbox", ReadOnly: true},
+			{Type: mount.TypeVolume, Source: filesVolumeName, Target: "/stdin", ReadOnly: true},
+		},
+		MemoryLimit: int64(entities.SandboxCheckerMemoryLimit),
+		Limits:      entities.SandboxRunLimits,
+		Labels:      sandboxLabels(interactor),
+		Interactive: true,
+	})
+	if err != nil {
+		result.Err = errors.New("interactive stage: failed to create interactor container")
+		return
+	}
+	defer s.dockerService.RemoveContainer(interactorResp.ID)
+
+	// attach to both containers before starting them so no output is lost
+	programConn, err := s.dockerService.AttachContainer(programResp.ID)
+	if err != nil {
+		result.Err = errors.New("interactive stage: failed to attach program container")
+		return
+	}
+	defer programConn.Close()
+
+	interactorConn, err := s.dockerService.AttachContainer(interactorResp.ID)
+	if err != nil {
+		result.Err = errors.New("interactive stage: failed to attach interactor container")
+		return
+	}
+	defer interactorConn.Close()
+
+	// wire program stdout to interactor stdin and interactor stdout to program stdin
+	// a container whose output exceeds the limit is stopped
+	var programStdout, programStderr, interactorStdout, interactorStderr bytes.Buffer
+	programLimiter := newOutputLimiter(s.outputLimit)
+	interactorLimiter := newOutputLimiter(s.outputLimit)
+	pipeWg := sync.WaitGroup{}
+	pipeWg.Add(2)
+	go func() {
+		defer pipeWg.Done()
+		stdcopy.StdCopy(
+			io.MultiWriter(interactorConn.Conn, programLimiter.Writer(&programStdout)),
+			programLimiter.Writer(&programStderr),
+			programConn.Reader,
+		)
+		if programLimiter.Exceeded() {
+			s.dockerService.StopContainer(programResp.ID)
+		}
+		interactorConn.CloseWrite()
+	}()
+	go func() {
+		defer pipeWg.Done()
+		stdcopy.StdCopy(
+			io.MultiWriter(programConn.Conn, interactorLimiter.Writer(&interactorStdout)),
+			interactorLimiter.Writer(&interactorStderr),
+			interactorConn.Reader,
+		)
+		if interactorLimiter.Exceeded() {
+			s.dockerService.StopContainer(interactorResp.ID)
+		}
+		programConn.CloseWrite()
+	}()
+
+	err = s.dockerService.StartContainer(interactorResp.ID)
+	if err != nil {
+		result.Err = errors.New("interactive stage: failed to start interactor container")
+		return
+	}
+
+	programStart := time.Now()
+	err = s.dockerService.StartContainer(programResp.ID)
+	if err != nil {
+		result.Err = errors.New("interactive stage: failed to start program container")
+		return
+	}
+
+	// wait for both containers to finish
+	var programWait, interactorWait string
+	var programWallTime time.Duration
+	waitWg := sync.WaitGroup{}
+	waitWg.Add(2)
+	go func() {
+		defer waitWg.Done()
+		programWait = s.dockerService.WaitContainer(programResp.ID, timeLimit)
+		programWallTime = time.Since(programStart)
+		if programWait == WaitResultTimeout {
+			s.dockerService.StopContainer(programResp.ID)
+		}
+	}()
+	go func() {
+		defer waitWg.Done()
+		interactorWait = s.dockerService.WaitContainer(interactorResp.ID, timeLimit+entities.SandboxInteractorTimeGraceMs)
+		if interactorWait == WaitResultTimeout {
+			s.dockerService.StopContainer(interactorResp.ID)
+		}
+	}()
+	waitWg.Wait()
+
+	// close the attached streams so the pipe goroutines return
+	programConn.Close()
+	interactorConn.Close()
+	pipeWg.Wait()
+
+	if programWait == WaitResultError || interactorWait == WaitResultError {
+		result.Err = errors.New("interactive stage: failed to wait container")
+		return
+	}
+
+	programState, err := s.dockerService.GetContainerState(programResp.ID)
+	if err != nil {
+		result.Err = errors.New("interactive stage: failed to get program container state")
+		return
+	}
+
+	interactorState, err := s.dockerService.GetContainerState(interactorResp.ID)
+	if err != nil {
+		result.Err = errors.New("interactive stage: failed to get interactor container state")
+		return
+	}
+
+	result.ExitCode = programState.ExitCode
+	result.OOMKilled = programState.OOMKilled
+	result.Timeout = programWait == WaitResultTimeout
+	result.Stdout = programStdout.String()
+	result.Stderr = programStderr.String()
+	result.OutputLimitExceeded = programLimiter.Exceeded()
+	applyUsage(result, programWallTime)
+
+	interactorResult.ExitCode = interactorState.ExitCode
+	interactorResult.OOMKilled = interactorState.OOMKilled
+	interactorResult.Timeout = interactorWait == WaitResultTimeout
+	interactorResult.Stdout = interactorStdout.String()
+	interactorResult.Stderr = interactorStderr.String()
+	interactorResult.OutputLimitExceeded = interactorLimiter.Exceeded()
 
-	// return instance
 	return
 }
 
@@ -312,14 +810,25 @@ func (s *sandboxService) CleanUp(instance *entities.SandboxInstance) error {
 	return nil
 }
 
-func (s *sandboxService) ValidateMemoryLimit(memoryLimit uint) (err error) {
+// Close implements SandboxService.
+// The warm containers are removed with the containers and volumes of runs that did not finish.
+func (s *sandboxService) Close() error {
+	var errs []error
+	if s.pool != nil {
+		errs = append(errs, s.pool.Close())
+	}
+	errs = append(errs, s.dockerService.removeAll())
+	return errors.Join(errs...)
+}
+
+func (s *sandboxLimits) ValidateMemoryLimit(memoryLimit uint) (err error) {
 	if memoryLimit > entities.SandboxMemoryMB*s.memoryLimit {
 		err = errors.New("run stage: too large memory limit")
 	}
 	return
 }
 
-func (s *sandboxService) ValidateTimeLimit(timeLimit uint) (err error) {
+func (s *sandboxLimits) ValidateTimeLimit(timeLimit uint) (err error) {
 
 	if timeLimit > s.timeLimit {
 		err = errors.New("run stage: too large time limit")
@@ -327,12 +836,28 @@ func (s *sandboxService) ValidateTimeLimit(timeLimit uint) (err error) {
 	return
 }
 
-func NewSandboxService(memoryLimit uint, timeLimit uint) SandboxService {
-	dockerService := NewDockerservice()
+// ValidateLanguage implements SandboxService.
+func (s *sandboxService) ValidateLanguage(language string) (err error) {
+	return s.languageService.ValidateLanguage(language)
+}
+
+// NewSandboxService creates a sandbox service that runs containers through dockerService.
+// The memory limit is in megabytes, the time limit in milliseconds and the output limit
+// in bytes of stdout and stderr together.
+// Testcases run in a warm container pool when the pool size is positive.
+func NewSandboxService(dockerService DockerService, languageService LanguageService, memoryLimit uint, timeLimit uint, outputLimit int, poolConfig ContainerPoolConfig) SandboxService {
+	tracked := newTrackedDockerService(dockerService)
+
+	var pool ContainerPool
+	if poolConfig.Size > 0 {
+		pool = NewContainerPool(tracked, poolConfig)
+	}
 
 	return &sandboxService{
-		dockerService: dockerService,
-		memoryLimit:   memoryLimit,
-		timeLimit:     timeLimit,
+		sandboxLimits:   sandboxLimits{memoryLimit: memoryLimit, timeLimit: timeLimit},
+		dockerService:   tracked,
+		languageService: languageService,
+		outputLimit:     outputLimit,
+		pool:            pool,
 	}
 }
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/wuttinanhi/code-judge-system/entities"
+)
+
+// SandboxReaper removes the sandbox containers and volumes left behind by judges that died
+// before cleaning up, found by the labels every sandbox container and volume gets.
+// Pooled containers are left to their pool.
+type SandboxReaper interface {
+	// Reap removes the sandbox containers and then the sandbox volumes created more than
+	// maxAge ago and returns how many of each were removed.
+	Reap(maxAge time.Duration) (containers, volumes int, err error)
+}
+
+type sandboxReaper struct {
+	dockerService DockerService
+	// tracked holds the containers and volumes of this process, which are still in use.
+	tracked *trackedDockerService
+}
+
+// Reap implements SandboxReaper.
+// Containers are removed first, as volumes can only be removed once no container uses them.
+func (r *sandboxReaper) Reap(maxAge time.Duration) (containers, volumes int, err error) {
+	createdBefore := time.Now().Add(-maxAge)
+
+	containerList, err := r.dockerService.ListContainers(entities.SandboxLabelCreatedAt)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var errs []error
+	for _, c := range containerList {
+		if !sandboxCreatedBefore(c.Labels, createdBefore) {
+			continue
+		}
+		if _, ok := c.Labels[entities.SandboxLabelPool]; ok {
+			continue
+		}
+		if r.tracked != nil && r.tracked.tracksContainer(c.ID) {
+			continue
+		}
+
+		err := r.dockerService.RemoveContainer(c.ID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		log.Println("reaped container", c.ID, c.Labels[entities.SandboxLabelRunID])
+		containers++
+	}
+
+	volumeList, err := r.dockerService.ListVolumes(entities.SandboxLabelCreatedAt)
+	if err != nil {
+		return containers, 0, errors.Join(append(errs, err)...)
+	}
+
+	for _, v := range volumeList {
+		if !sandboxCreatedBefore(v.Labels, createdBefore) {
+			continue
+		}
+		if r.tracked != nil && r.tracked.tracksVolume(v.Name) {
+			continue
+		}
+
+		err := r.dockerService.DeleteVolume(*v)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		log.Println("reaped volume", v.Name)
+		volumes++
+	}
+
+	return containers, volumes, errors.Join(errs...)
+}
+
+// sandboxCreatedBefore reports whether the created-at label of a sandbox container or
+// volume is before t. Those with a label that cannot be parsed are kept.
+func sandboxCreatedBefore(labels map[string]string, t time.Time) bool {
+	createdAt, err := time.Parse(time.RFC3339, labels[entities.SandboxLabelCreatedAt])
+	if err != nil {
+		return false
+	}
+	return createdAt.Before(t)
+}
+
+// NewSandboxReaper creates a reaper that removes sandboxes through dockerService.
+// The containers and volumes sandbox still uses are kept whatever their age, sandbox is
+// nil when no sandbox runs in this process.
+func NewSandboxReaper(dockerService DockerService, sandbox SandboxService) SandboxReaper {
+	reaper := &sandboxReaper{
+		dockerService: dockerService,
+	}
+	if s, ok := sandbox.(*sandboxService); ok {
+		reaper.tracked = s.dockerService
+	}
+	return reaper
+}
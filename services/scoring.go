@@ -0,0 +1,59 @@
+package services
+
+import (
+	"github.com/wuttinanhi/code-judge-system/entities"
+)
+
+// ScoreSubmission calculates the score of a judged submission.
+// A challenge without subtasks is scored all-or-nothing out of the default score.
+// Testcases that do not belong to a subtask only affect the verdict, not the score.
+func ScoreSubmission(challenge *entities.Challenge, submission *entities.Submission) (score float64, results []*entities.SubmissionSubtaskResult) {
+	if len(challenge.Subtasks) == 0 {
+		if submission.IsCorrect() {
+			return entities.ChallengeDefaultMaxScore, nil
+		}
+		return 0, nil
+	}
+
+	testcaseSubtask := make(map[uint]uint, len(challenge.Testcases))
+	for _, testcase := range challenge.Testcases {
+		testcaseSubtask[testcase.ID] = testcase.Subtask
+	}
+
+	for _, subtask := range challenge.Subtasks {
+		result := &entities.SubmissionSubtaskResult{
+			Number:   subtask.Number,
+			Name:     subtask.Name,
+			Status:   entities.SubmissionStatusCorrect,
+			MaxScore: subtask.Points,
+		}
+
+		for _, testcase := range submission.SubmissionTestcases {
+			if testcaseSubtask[testcase.ChallengeTestcaseID] != subtask.Number {
+				continue
+			}
+
+			result.Total++
+			if testcase.Status == entities.SubmissionStatusCorrect {
+				result.Passed++
+			} else if result.Status == entities.SubmissionStatusCorrect {
+				result.Status = testcase.Status
+			}
+		}
+
+		switch {
+		case result.Total == 0:
+			// a subtask without testcases can not be proven, so it is worth nothing
+			result.Status = entities.SubmissionStatusNotSolve
+		case subtask.ScoringRule == entities.ChallengeSubtaskScoringProportional:
+			result.Score = subtask.Points * float64(result.Passed) / float64(result.Total)
+		case result.Passed == result.Total:
+			result.Score = subtask.Points
+		}
+
+		score += result.Score
+		results = append(results, result)
+	}
+
+	return score, results
+}
@@ -1,6 +1,11 @@
 package services
 
 import (
+	"log"
+	"os"
+	"runtime"
+	"time"
+
 	"github.com/spf13/viper"
 	"github.com/wuttinanhi/code-judge-system/entities"
 	"github.com/wuttinanhi/code-judge-system/repositories"
@@ -8,18 +13,37 @@ import (
 )
 
 type ServiceKit struct {
-	JWTService        JWTService
-	UserService       UserService
-	ChallengeService  ChallengeService
-	SubmissionService SubmissionService
-	SandboxService    SandboxService
-	KafkaService      KafkaService
+	JWTService             JWTService
+	UserService            UserService
+	ChallengeService       ChallengeService
+	SubmissionService      SubmissionService
+	SubmissionEventService SubmissionEventService
+	CodeRunService         CodeRunService
+	SandboxService         SandboxService
+	SandboxReaper          SandboxReaper
+	LanguageService        LanguageService
+	KafkaService           KafkaService
+	DeadLetterService      DeadLetterService
+}
+
+// ReadSandboxBackend returns the backend programs are run with.
+func ReadSandboxBackend() string {
+	// read env var "SANDBOX_BACKEND" to choose how programs are run
+	// if SANDBOX_BACKEND is empty, run programs in Docker containers
+	sandboxBackend := viper.GetString("SANDBOX_BACKEND")
+	if sandboxBackend == "" {
+		sandboxBackend = entities.SandboxBackendDocker
+	}
+	return sandboxBackend
 }
 
 func CreateServiceKit(db *gorm.DB) *ServiceKit {
 	userRepo := repositories.NewUserRepository(db)
 	challengeRepo := repositories.NewChallengeRepository(db)
 	submissionRepo := repositories.NewSubmissionRepository(db)
+	codeRunRepo := repositories.NewCodeRunRepository(db)
+	languageRepo := repositories.NewLanguageRepository(db)
+	deadLetterJobRepo := repositories.NewDeadLetterJobRepository(db)
 
 	// read env var "JWT_SECRET" and pass it to JWTService
 	// if JWT_SECRET is empty, use default value
@@ -29,48 +53,172 @@ func CreateServiceKit(db *gorm.DB) *ServiceKit {
 	}
 
 	kafkaHost := viper.GetString("KAFKA_HOST")
+	submissionEventTopic := viper.GetString("KAFKA_SUBMISSION_EVENT_TOPIC")
 
 	maxMemoryLimit := viper.GetUint("SANDBOX_MAX_MEMORY_MB")
 	maxRuntimeMs := viper.GetUint("SANDBOX_MAX_TIME_MS")
 
+	// read env var "SANDBOX_MAX_OUTPUT_KB" to cap the output of a run
+	// if SANDBOX_MAX_OUTPUT_KB is empty, use default value
+	maxOutputKB := viper.GetInt("SANDBOX_MAX_OUTPUT_KB")
+	if maxOutputKB <= 0 {
+		maxOutputKB = entities.SandboxDefaultOutputLimitKB
+	}
+
+	sandboxBackend := ReadSandboxBackend()
+
+	// read env var "SANDBOX_NSJAIL_PATH" for the nsjail binary of the nsjail backend
+	// if SANDBOX_NSJAIL_PATH is empty, look nsjail up in PATH
+	nsjailPath := viper.GetString("SANDBOX_NSJAIL_PATH")
+	if nsjailPath == "" {
+		nsjailPath = "nsjail"
+	}
+
+	// read env var "SANDBOX_NSJAIL_WORK_DIR" for the program files of the nsjail backend
+	// if SANDBOX_NSJAIL_WORK_DIR is empty, use the temporary directory
+	nsjailWorkDir := viper.GetString("SANDBOX_NSJAIL_WORK_DIR")
+	if nsjailWorkDir == "" {
+		nsjailWorkDir = os.TempDir()
+	}
+
+	// read env var "SANDBOX_POOL_SIZE" for the number of warm containers per language
+	// if SANDBOX_POOL_SIZE is empty, run every testcase in a new container
+	// read env var "SANDBOX_POOL_MAX_USES" for the runs a pooled container serves
+	// if SANDBOX_POOL_MAX_USES is empty, use every container once
+	poolConfig := ContainerPoolConfig{
+		Size:           viper.GetInt("SANDBOX_POOL_SIZE"),
+		MaxUses:        viper.GetInt("SANDBOX_POOL_MAX_USES"),
+		MaxAge:         time.Duration(viper.GetInt("SANDBOX_POOL_MAX_AGE_MS")) * time.Millisecond,
+		HealthInterval: time.Duration(viper.GetInt("SANDBOX_POOL_HEALTH_INTERVAL_MS")) * time.Millisecond,
+	}
+	if poolConfig.MaxUses <= 0 {
+		poolConfig.MaxUses = 1
+	}
+
+	// read env var "SANDBOX_LANGUAGES_FILE" for languages to add besides the built-in ones
+	languagesFile := viper.GetString("SANDBOX_LANGUAGES_FILE")
+
+	// read env var "JUDGE_WORKER_ID" to name this judge in submissions
+	// if JUDGE_WORKER_ID is empty, use host name and process ID
+	workerID := viper.GetString("JUDGE_WORKER_ID")
+	if workerID == "" {
+		workerID = DefaultWorkerID()
+	}
+
+	// read env var "JUDGE_MAX_PARALLEL_TESTCASES" to bound the testcases run at the same time
+	// if JUDGE_MAX_PARALLEL_TESTCASES is empty, use the number of CPUs as each run gets one
+	maxParallelTestcases := viper.GetInt("JUDGE_MAX_PARALLEL_TESTCASES")
+	if maxParallelTestcases <= 0 {
+		maxParallelTestcases = runtime.NumCPU()
+	}
+
+	// read env var "SUBMISSION_STDERR_VISIBILITY" to decide who sees the stderr of testcases
+	// if SUBMISSION_STDERR_VISIBILITY is empty, show it to the submitter and staff
+	stderrVisibility := viper.GetString("SUBMISSION_STDERR_VISIBILITY")
+	if stderrVisibility == "" {
+		stderrVisibility = entities.SubmissionStderrVisibilityOwner
+	}
+	if !entities.IsSubmissionStderrVisibility(stderrVisibility) {
+		log.Fatal("Invalid SUBMISSION_STDERR_VISIBILITY: ", stderrVisibility)
+	}
+
 	jwtService := NewJWTService(jwtSecret)
 	userService := NewUserService(userRepo)
-	sandboxService := NewSandboxService(maxMemoryLimit, maxRuntimeMs)
+	languageService := NewLanguageService(languageRepo)
+	var sandboxService SandboxService
+	var sandboxReaper SandboxReaper
+	switch sandboxBackend {
+	case entities.SandboxBackendDocker:
+		dockerService := NewDockerservice()
+		sandboxService = NewSandboxService(dockerService, languageService, maxMemoryLimit, maxRuntimeMs, maxOutputKB*1024, poolConfig)
+		sandboxReaper = NewSandboxReaper(dockerService, sandboxService)
+	case entities.SandboxBackendNsjail:
+		sandboxService = NewNsjailSandboxService(languageService, maxMemoryLimit, maxRuntimeMs, maxOutputKB*1024, nsjailPath, nsjailWorkDir)
+	default:
+		log.Fatal("Invalid SANDBOX_BACKEND: ", sandboxBackend)
+	}
 	challengeService := NewChallengeService(challengeRepo, sandboxService)
-	submissionService := NewSubmissionService(submissionRepo, challengeService, sandboxService)
 	kafkaService := NewKafkaService(kafkaHost)
+	submissionEventService := NewSubmissionEventService(kafkaService, submissionEventTopic)
+	submissionService := NewSubmissionService(submissionRepo, challengeService, sandboxService, submissionEventService, workerID, stderrVisibility, NewTestcaseLimiter(maxParallelTestcases))
+	codeRunService := NewCodeRunService(codeRunRepo, sandboxService)
+	deadLetterService := NewDeadLetterService(deadLetterJobRepo, kafkaService)
+
+	// seed the language table with the built-in languages and those from the file
+	languages := DefaultLanguages()
+	if languagesFile != "" {
+		fileLanguages, err := entities.LoadLanguageFile(languagesFile)
+		if err != nil {
+			log.Fatal("Failed to load languages file: ", err)
+		}
+		languages = append(languages, fileLanguages...)
+	}
+
+	err := languageService.SeedLanguages(languages)
+	if err != nil {
+		log.Fatal("Failed to seed languages: ", err)
+	}
 
 	return &ServiceKit{
-		JWTService:        jwtService,
-		UserService:       userService,
-		ChallengeService:  challengeService,
-		SubmissionService: submissionService,
-		SandboxService:    sandboxService,
-		KafkaService:      kafkaService,
+		JWTService:             jwtService,
+		UserService:            userService,
+		ChallengeService:       challengeService,
+		SubmissionService:      submissionService,
+		SubmissionEventService: submissionEventService,
+		CodeRunService:         codeRunService,
+		SandboxService:         sandboxService,
+		SandboxReaper:          sandboxReaper,
+		LanguageService:        languageService,
+		KafkaService:           kafkaService,
+		DeadLetterService:      deadLetterService,
 	}
 }
 
 func CreateTestServiceKit(db *gorm.DB) *ServiceKit {
+	return CreateTestServiceKitWithDocker(db, NewDockerservice())
+}
+
+// CreateTestServiceKitWithDocker creates a test service kit whose sandbox runs containers
+// through dockerService, e.g. a fake that needs no Docker daemon.
+func CreateTestServiceKitWithDocker(db *gorm.DB, dockerService DockerService) *ServiceKit {
 	userRepo := repositories.NewUserRepository(db)
 	challengeRepo := repositories.NewChallengeRepository(db)
 	submissionRepo := repositories.NewSubmissionRepository(db)
+	codeRunRepo := repositories.NewCodeRunRepository(db)
+	languageRepo := repositories.NewLanguageRepository(db)
+	deadLetterJobRepo := repositories.NewDeadLetterJobRepository(db)
 
 	maxMemoryLimit := entities.SandboxMemoryMB * 256
 	maxRuntimeMs := uint(10000)
+	maxOutput := 1024 * 1024
 
 	jwtService := NewJWTService("test")
 	userService := NewUserService(userRepo)
-	sandboxService := NewSandboxService(maxMemoryLimit, maxRuntimeMs)
+	languageService := NewLanguageService(languageRepo)
+	sandboxService := NewSandboxService(dockerService, languageService, maxMemoryLimit, maxRuntimeMs, maxOutput, ContainerPoolConfig{})
 	challengeService := NewChallengeService(challengeRepo, sandboxService)
-	submissionService := NewSubmissionService(submissionRepo, challengeService, sandboxService)
 	kafkaService := NewKafkaMockService()
+	submissionEventService := NewSubmissionEventService(kafkaService, "submission-event-topic")
+	submissionService := NewSubmissionService(submissionRepo, challengeService, sandboxService, submissionEventService, "test-worker", entities.SubmissionStderrVisibilityOwner, NewTestcaseLimiter(4))
+	codeRunService := NewCodeRunService(codeRunRepo, sandboxService)
+	deadLetterService := NewDeadLetterService(deadLetterJobRepo, kafkaService)
+
+	err := languageService.SeedLanguages(DefaultLanguages())
+	if err != nil {
+		panic(err)
+	}
 
 	return &ServiceKit{
-		JWTService:        jwtService,
-		UserService:       userService,
-		ChallengeService:  challengeService,
-		SubmissionService: submissionService,
-		SandboxService:    sandboxService,
-		KafkaService:      kafkaService,
+		JWTService:             jwtService,
+		UserService:            userService,
+		ChallengeService:       challengeService,
+		SubmissionService:      submissionService,
+		SubmissionEventService: submissionEventService,
+		CodeRunService:         codeRunService,
+		SandboxService:         sandboxService,
+		SandboxReaper:          NewSandboxReaper(dockerService, sandboxService),
+		LanguageService:        languageService,
+		KafkaService:           kafkaService,
+		DeadLetterService:      deadLetterService,
 	}
 }
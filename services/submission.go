@@ -1,14 +1,23 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
+	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/wuttinanhi/code-judge-system/entities"
 	"github.com/wuttinanhi/code-judge-system/repositories"
 )
 
+// ErrSubmissionNotFinished is returned when a submission that is queued or being judged
+// is rejudged.
+var ErrSubmissionNotFinished = errors.New("submission is still being judged")
+
 type SubmissionService interface {
 	CreateSubmission(submission *entities.Submission) (*entities.Submission, error)
 	DeleteSubmission(submission *entities.Submission) error
@@ -18,14 +27,64 @@ type SubmissionService interface {
 	CreateSubmissionTestcase(submissionTestcase *entities.SubmissionTestcase) (*entities.SubmissionTestcase, error)
 	GetSubmissionTestcaseBySubmission(submission *entities.Submission) ([]*entities.SubmissionTestcase, error)
 	SubmitSubmission(submission *entities.Submission) (*entities.Submission, error)
-	ProcessSubmission(submission *entities.Submission) (*entities.Submission, error)
+	ProcessSubmission(ctx context.Context, submission *entities.Submission) (*entities.Submission, error)
+	RequeueSubmission(submission *entities.Submission) (*entities.Submission, error)
 	Pagination(options *entities.SubmissionPaginationOptions) (result *entities.PaginationResult[*entities.Submission], err error)
+	RejudgeSubmission(submission *entities.Submission, user *entities.User, reason string) (*entities.Submission, error)
+	RejudgeChallenge(challenge *entities.Challenge, user *entities.User, reason string) ([]*entities.Submission, error)
+	RejudgeChallengeTestcase(testcase *entities.ChallengeTestcase, user *entities.User, reason string) ([]*entities.Submission, error)
+	HideStderr(user *entities.User, submissions ...*entities.Submission)
 }
 
 type submissionService struct {
 	submissionRepository repositories.SubmissionRepository
 	challengeService     ChallengeService
 	sandboxService       SandboxService
+	eventService         SubmissionEventService
+	workerID             string
+	stderrVisibility     string
+	testcaseLimiter      TestcaseLimiter
+}
+
+// DefaultWorkerID identifies this judge process by host name and process ID.
+func DefaultWorkerID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// transition moves the submission to the next lifecycle state and saves it.
+// Every state change of a submission goes through here so invalid transitions are rejected,
+// and it fails when the stored submission changed state in the meantime.
+func (s *submissionService) transition(submission *entities.Submission, state string) error {
+	from := submission.State
+	err := submission.TransitionTo(state, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if state == entities.SubmissionStateCompiling {
+		submission.JudgedBy = s.workerID
+	}
+
+	err = s.submissionRepository.UpdateSubmissionState(submission, from)
+	if err != nil {
+		return err
+	}
+
+	s.publish(entities.NewSubmissionEvent(entities.SubmissionEventState, submission))
+	return nil
+}
+
+// publish sends a progress event of the submission to live listeners.
+// Events are best effort, the stored submission stays the source of truth.
+func (s *submissionService) publish(event *entities.SubmissionEvent) {
+	err := s.eventService.Publish(event)
+	if err != nil {
+		log.Println("failed to publish event of submission ID:", event.SubmissionID, "with error:", err)
+	}
 }
 
 // Pagination implements SubmissionService.
@@ -35,83 +94,452 @@ func (s *submissionService) Pagination(options *entities.SubmissionPaginationOpt
 }
 
 // ProcessSubmission implements SubmissionService.
-func (s *submissionService) ProcessSubmission(submission *entities.Submission) (*entities.Submission, error) {
+// Once ctx is done no further testcase is started and ctx's error is returned after the
+// running ones finish, leaving the submission compiling or running to be queued again.
+func (s *submissionService) ProcessSubmission(ctx context.Context, submission *entities.Submission) (*entities.Submission, error) {
 	submissionTestcases := submission.SubmissionTestcases
 
+	// a submission that was judged already, e.g. by a duplicate message, is not judged again
+	err := s.transition(submission, entities.SubmissionStateCompiling)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.challengeService.FindChallengeByID(submission.ChallengeID)
+	if err != nil {
+		s.finishSubmission(submission, entities.SubmissionStatusSystemError, "failed to load challenge")
+		return nil, errors.New("failed to load challenge")
+	}
+
 	sandbox, err := s.sandboxService.CreateSandbox(submission.Language, submission.Code)
 	if err != nil {
+		s.finishSubmission(submission, entities.SubmissionStatusSystemError, "failed to create sandbox")
 		return nil, errors.New("failed to create sandbox")
 	}
 	defer s.sandboxService.CleanUp(sandbox)
+	sandbox.SubmissionID = submission.ID
+
+	// the challenge can override the time and memory multipliers of the language
+	challenge.ApplyLanguageLimit(sandbox)
 
 	compile := s.sandboxService.CompileSandbox(sandbox)
-	if compile.ExitCode != 0 {
+
+	submission.CompileExitCode = sandbox.CompileExitCode
+	submission.CompileStdout = entities.TruncateOutput(sandbox.CompileStdout, entities.SandboxCompileOutputLimit)
+	submission.CompileStderr = entities.TruncateOutput(sandbox.CompileStderr, entities.SandboxCompileOutputLimit)
+	submission.CompileTimeMs = sandbox.CompileTimeMs
+
+	if compile.Err != nil {
+		// a non-zero exit code, a compile timeout or too much compiler output is the
+		// user's fault, anything else is a failure of the sandbox itself
+		if compile.Timeout || compile.OutputLimitExceeded || compile.ExitCode != 0 {
+			return s.finishSubmission(submission, entities.SubmissionStatusCompilationError, compile.Err.Error())
+		}
+
+		s.finishSubmission(submission, entities.SubmissionStatusSystemError, compile.Err.Error())
 		return nil, errors.New("failed to compile sandbox")
 	}
 
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	err = s.transition(submission, entities.SubmissionStateRunning)
+	if err != nil {
+		return nil, err
+	}
+
+	var checker Checker
+	var interactor *entities.SandboxInstance
+
+	if challenge.Type == entities.ChallengeTypeInteractive {
+		interactor, err = createCompiledSandbox(s.sandboxService, challenge.InteractorLanguage, challenge.InteractorCode)
+		if err != nil {
+			s.finishSubmission(submission, entities.SubmissionStatusSystemError, "failed to compile interactor: "+err.Error())
+			return nil, errors.New("failed to create interactor")
+		}
+		defer s.sandboxService.CleanUp(interactor)
+	} else {
+		checker, err = NewChallengeChecker(challenge, s.sandboxService)
+		if err != nil {
+			s.finishSubmission(submission, entities.SubmissionStatusSystemError, err.Error())
+			return nil, errors.New("failed to create checker")
+		}
+		defer checker.CleanUp()
+	}
+
 	wg := sync.WaitGroup{}
 
 	for _, testcase := range submissionTestcases {
+		if ctx.Err() != nil {
+			break
+		}
+
+		// serial challenges run one testcase at a time with nothing else beside it
+		if challenge.SerialTestcases {
+			release := s.testcaseLimiter.AcquireExclusive()
+			s.runSubmissionTestcase(submission, sandbox, checker, interactor, testcase)
+			release()
+
+			s.publish(entities.NewSubmissionTestcaseEvent(submission, testcase))
+			continue
+		}
+
+		// a slot is taken before starting the testcase so testcases queue in order
+		release := s.testcaseLimiter.Acquire()
 		wg.Add(1)
 
 		go func(testcase *entities.SubmissionTestcase) {
 			defer wg.Done()
 
-			challengeTestcase, err := s.challengeService.FindTestcaseByID(testcase.ChallengeTestcaseID)
-			if err != nil {
-				log.Println("failed to get challenge testcase ID:", testcase.ID, "with error:", err)
-				return
-			}
-
-			result := s.sandboxService.Run(
-				sandbox,
-				challengeTestcase.Input,
-				challengeTestcase.LimitMemory,
-				challengeTestcase.LimitTimeMs,
-			)
-			if result.Err != nil {
-				testcase.Status = entities.SubmissionStatusWrong
-				testcase.Note = result.Err.Error()
-			}
-
-			if result.ExitCode != 0 {
-				testcase.Status = entities.SubmissionStatusWrong
-			}
-
-			testcase.Output = result.Stdout + result.Stderr
-
-			if testcase.Output == challengeTestcase.ExpectedOutput {
-				testcase.Status = entities.SubmissionStatusCorrect
-			} else {
-				testcase.Status = entities.SubmissionStatusWrong
-			}
-
-			_, err = s.submissionRepository.UpdateSubmissionTestcase(testcase)
-			if err != nil {
-				log.Println("failed to update submission testcase ID:", testcase.ID, "with error:", err)
-			}
+			s.runSubmissionTestcase(submission, sandbox, checker, interactor, testcase)
+			release()
+
+			// the slot is free for the next testcase before the event is sent
+			s.publish(entities.NewSubmissionTestcaseEvent(submission, testcase))
 		}(testcase)
 	}
 
 	// wait for all goroutines to finish
 	wg.Wait()
 
-	if submission.IsCorrect() {
-		submission.Status = entities.SubmissionStatusCorrect
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	submission.Status = submission.Verdict()
+	submission.UpdateMaxUsage()
+
+	// a testcase the sandbox failed on is not the user's fault, so the submission is
+	// judged again instead of keeping the system error
+	if submission.Status == entities.SubmissionStatusSystemError {
+		return s.failSubmission(submission, errors.New("failed to run testcases"))
+	}
+
+	submission.MaxScore = challenge.MaxScore()
+	submission.Score, submission.SubtaskResults = ScoreSubmission(challenge, submission)
+
+	from := submission.State
+	err = submission.TransitionTo(entities.SubmissionStateJudged, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.submissionRepository.FinishSubmission(submission, from)
+	if errors.Is(err, repositories.ErrSubmissionStateChanged) {
+		return nil, err
+	}
+	if err != nil {
+		// the stored submission is still running
+		submission.State = from
+		return s.failSubmission(submission, err)
+	}
+
+	s.publish(entities.NewSubmissionEvent(entities.SubmissionEventResult, submission))
+
+	return submission, nil
+}
+
+// RequeueSubmission implements SubmissionService.
+// A submission whose judge died while judging it or that failed with a system error is
+// queued again, keeping its testcases to be overwritten when it is judged.
+func (s *submissionService) RequeueSubmission(submission *entities.Submission) (*entities.Submission, error) {
+	err := s.transition(submission, entities.SubmissionStateQueued)
+	if err != nil {
+		return nil, err
+	}
+	return submission, nil
+}
+
+// runSubmissionTestcase runs the program on a testcase, judges the result and saves it.
+// The caller publishes the testcase event once it has released the testcase slot.
+func (s *submissionService) runSubmissionTestcase(submission *entities.Submission, sandbox *entities.SandboxInstance, checker Checker, interactor *entities.SandboxInstance, testcase *entities.SubmissionTestcase) {
+	challengeTestcase, err := s.challengeService.FindTestcaseByID(testcase.ChallengeTestcaseID)
+	if err != nil {
+		log.Println("failed to get challenge testcase ID:", testcase.ID, "with error:", err)
+		testcase.Status = entities.SubmissionStatusSystemError
+		testcase.Note = "failed to load testcase"
+		s.submissionRepository.UpdateSubmissionTestcase(testcase)
+		return
+	}
+
+	if interactor != nil {
+		result, interactorResult := s.sandboxService.RunInteractive(
+			sandbox,
+			interactor,
+			challengeTestcase.Input,
+			challengeTestcase.ExpectedOutput,
+			challengeTestcase.LimitMemory,
+			challengeTestcase.LimitTimeMs,
+		)
+
+		testcase.Output = entities.TruncateOutput(result.Stdout, entities.SubmissionTestcaseOutputPreviewLimit)
+		testcase.Stderr = s.keepStderr(result.Stderr)
+		testcase.TimeMs = result.TimeMs
+		testcase.MemoryUsage = result.MemoryUsage
+		testcase.Status, testcase.Note = judgeInteractiveTestcase(result, interactorResult)
 	} else {
-		submission.Status = entities.SubmissionStatusWrong
+		result := s.sandboxService.Run(
+			sandbox,
+			challengeTestcase.Input,
+			challengeTestcase.LimitMemory,
+			challengeTestcase.LimitTimeMs,
+		)
+
+		// only stdout is judged, stderr is free for debug output
+		testcase.Output = entities.TruncateOutput(result.Stdout, entities.SubmissionTestcaseOutputPreviewLimit)
+		testcase.Stderr = s.keepStderr(result.Stderr)
+		testcase.TimeMs = result.TimeMs
+		testcase.MemoryUsage = result.MemoryUsage
+		testcase.Status, testcase.Note = judgeTestcase(checker, result, challengeTestcase.Input, result.Stdout, challengeTestcase.ExpectedOutput)
+	}
+
+	_, err = s.submissionRepository.UpdateSubmissionTestcase(testcase)
+	if err != nil {
+		log.Println("failed to update submission testcase ID:", testcase.ID, "with error:", err)
+	}
+}
+
+// keepStderr returns the part of stderr stored on a testcase under the stderr visibility policy.
+func (s *submissionService) keepStderr(stderr string) string {
+	if s.stderrVisibility == entities.SubmissionStderrVisibilityNone {
+		return ""
+	}
+	return entities.TruncateOutput(stderr, entities.SubmissionTestcaseOutputPreviewLimit)
+}
+
+// HideStderr implements SubmissionService.
+// It clears the stderr of the testcases of every submission the user may not see it of.
+func (s *submissionService) HideStderr(user *entities.User, submissions ...*entities.Submission) {
+	for _, submission := range submissions {
+		if submission.StderrVisibleTo(user, s.stderrVisibility) {
+			continue
+		}
+		for _, testcase := range submission.SubmissionTestcases {
+			testcase.Stderr = ""
+		}
 	}
+}
+
+// finishSubmission marks the submission and every testcase with the same status
+// when judging stops before the testcases can run.
+func (s *submissionService) finishSubmission(submission *entities.Submission, status, note string) (*entities.Submission, error) {
+	for _, testcase := range submission.SubmissionTestcases {
+		testcase.Status = status
+		testcase.Note = note
+
+		_, err := s.submissionRepository.UpdateSubmissionTestcase(testcase)
+		if err != nil {
+			log.Println("failed to update submission testcase ID:", testcase.ID, "with error:", err)
+		}
+	}
+
+	submission.Status = status
+	submission.Score = 0
+	submission.SubtaskResults = nil
 
-	submission, err = s.submissionRepository.UpdateSubmission(submission)
+	// a system error means the judge failed, anything else is a judged verdict
+	state := entities.SubmissionStateJudged
+	if status == entities.SubmissionStatusSystemError {
+		state = entities.SubmissionStateFailed
+	}
+	from := submission.State
+	err := submission.TransitionTo(state, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.submissionRepository.FinishSubmission(submission, from)
 	if err != nil {
 		return nil, err
 	}
 
+	s.publish(entities.NewSubmissionEvent(entities.SubmissionEventResult, submission))
+
 	return submission, nil
 }
 
+// failSubmission moves a submission whose judging failed after its testcases started to
+// FAILED with a system error, so its job is retried, and returns cause. The testcases keep
+// the results they got until the submission is judged again.
+func (s *submissionService) failSubmission(submission *entities.Submission, cause error) (*entities.Submission, error) {
+	submission.Status = entities.SubmissionStatusSystemError
+	submission.Score = 0
+	submission.SubtaskResults = nil
+
+	from := submission.State
+	err := submission.TransitionTo(entities.SubmissionStateFailed, time.Now())
+	if err != nil {
+		return nil, errors.Join(cause, err)
+	}
+
+	err = s.submissionRepository.FinishSubmission(submission, from)
+	if err != nil {
+		return nil, errors.Join(cause, err)
+	}
+
+	s.publish(entities.NewSubmissionEvent(entities.SubmissionEventResult, submission))
+
+	return nil, cause
+}
+
+// judgeTestcase returns the verdict and note for a single testcase run.
+// Sandbox failures take precedence, then resource limits, then the exit code,
+// and only a clean run is passed to the checker.
+func judgeTestcase(checker Checker, result *entities.SandboxRunResult, input, output, expectedOutput string) (status string, note string) {
+	switch {
+	case result.Err != nil:
+		return entities.SubmissionStatusSystemError, result.Err.Error()
+	case result.Timeout:
+		return entities.SubmissionStatusTimeLimitExceeded, ""
+	case result.OOMKilled:
+		return entities.SubmissionStatusMemoryLimitExceeded, ""
+	case result.OutputLimitExceeded:
+		return entities.SubmissionStatusOutputLimitExceeded, ""
+	case result.ExitCode != 0:
+		return entities.SubmissionStatusRuntimeError, fmt.Sprintf("exit code %d", result.ExitCode)
+	}
+
+	return checker.Check(input, expectedOutput, output)
+}
+
+// judgeInteractiveTestcase returns the verdict and note for an interactive testcase run.
+// Limits of the program are reported first, then a rejection by the interactor, which
+// decides the verdict the same way as a testlib checker. A program that is cut off by a
+// rejecting interactor usually crashes on the closed pipe, so its exit code comes last.
+func judgeInteractiveTestcase(result, interactorResult *entities.SandboxRunResult) (status string, note string) {
+	switch {
+	case result.Err != nil:
+		return entities.SubmissionStatusSystemError, result.Err.Error()
+	case result.Timeout:
+		return entities.SubmissionStatusTimeLimitExceeded, ""
+	case result.OOMKilled:
+		return entities.SubmissionStatusMemoryLimitExceeded, ""
+	case result.OutputLimitExceeded:
+		return entities.SubmissionStatusOutputLimitExceeded, ""
+	case interactorResult.Timeout || interactorResult.OOMKilled || interactorResult.OutputLimitExceeded:
+		return entities.SubmissionStatusSystemError, "interactor: exceeded resource limit"
+	}
+
+	note = entities.TruncateOutput(strings.TrimSpace(interactorResult.Stderr), 1024)
+
+	switch {
+	case interactorResult.ExitCode == 1 || interactorResult.ExitCode == 2:
+		return entities.SubmissionStatusWrong, note
+	case result.ExitCode != 0:
+		return entities.SubmissionStatusRuntimeError, fmt.Sprintf("exit code %d", result.ExitCode)
+	case interactorResult.ExitCode == 0:
+		return entities.SubmissionStatusCorrect, note
+	}
+
+	return entities.SubmissionStatusSystemError, fmt.Sprintf("interactor: exit code %d", interactorResult.ExitCode)
+}
+
+// RejudgeSubmission implements SubmissionService.
+// The testcases are rebuilt from the current challenge testcases so added or
+// removed testcases are picked up, and the previous verdict is kept as history.
+func (s *submissionService) RejudgeSubmission(submission *entities.Submission, user *entities.User, reason string) (*entities.Submission, error) {
+	// the judge of an unfinished submission would write over the reset testcases
+	if !submission.IsFinished() {
+		return nil, ErrSubmissionNotFinished
+	}
+
+	challenge, err := s.challengeService.FindChallengeByID(submission.ChallengeID)
+	if err != nil {
+		return nil, err
+	}
+
+	from := submission.State
+	err = submission.TransitionTo(entities.SubmissionStateQueued, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	history := &entities.SubmissionVerdictHistory{
+		Status:       submission.Status,
+		Score:        submission.Score,
+		MaxScore:     submission.MaxScore,
+		Reason:       reason,
+		RejudgedByID: user.ID,
+	}
+
+	submissionTestcases := make([]*entities.SubmissionTestcase, len(challenge.Testcases))
+	for i, challengeTestcase := range challenge.Testcases {
+		submissionTestcases[i] = &entities.SubmissionTestcase{
+			ChallengeTestcaseID: challengeTestcase.ID,
+			Status:              entities.SubmissionStatusPending,
+		}
+	}
+
+	submission.Status = entities.SubmissionStatusPending
+	submission.Score = 0
+	submission.MaxScore = challenge.MaxScore()
+	submission.MaxTimeMs = 0
+	submission.MaxMemoryUsage = 0
+	submission.CompileStdout = ""
+	submission.CompileStderr = ""
+	submission.CompileExitCode = 0
+	submission.CompileTimeMs = 0
+
+	err = s.submissionRepository.ResetSubmission(submission, from, submissionTestcases, history)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publish(entities.NewSubmissionEvent(entities.SubmissionEventState, submission))
+
+	return submission, nil
+}
+
+// RejudgeChallenge implements SubmissionService.
+func (s *submissionService) RejudgeChallenge(challenge *entities.Challenge, user *entities.User, reason string) ([]*entities.Submission, error) {
+	submissions, err := s.submissionRepository.GetSubmissionByChallenge(challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.rejudgeSubmissions(submissions, user, reason)
+}
+
+// RejudgeChallengeTestcase implements SubmissionService.
+func (s *submissionService) RejudgeChallengeTestcase(testcase *entities.ChallengeTestcase, user *entities.User, reason string) ([]*entities.Submission, error) {
+	submissions, err := s.submissionRepository.GetSubmissionByChallengeTestcase(testcase)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.rejudgeSubmissions(submissions, user, reason)
+}
+
+// rejudgeSubmissions rejudges every submission that is not queued or being judged already.
+func (s *submissionService) rejudgeSubmissions(submissions []*entities.Submission, user *entities.User, reason string) ([]*entities.Submission, error) {
+	rejudged := make([]*entities.Submission, 0, len(submissions))
+	for _, submission := range submissions {
+		if !submission.IsFinished() {
+			continue
+		}
+
+		submission, err := s.RejudgeSubmission(submission, user, reason)
+		if errors.Is(err, repositories.ErrSubmissionStateChanged) {
+			// rejudged or judged again since it was loaded
+			continue
+		}
+		if err != nil {
+			return rejudged, err
+		}
+		rejudged = append(rejudged, submission)
+	}
+
+	return rejudged, nil
+}
+
 // SubmitSubmission implements SubmissionService.
 func (s *submissionService) SubmitSubmission(submission *entities.Submission) (*entities.Submission, error) {
+	err := s.sandboxService.ValidateLanguage(submission.Language)
+	if err != nil {
+		return nil, err
+	}
+
 	// get challenge
 	challenge, err := s.challengeService.FindChallengeByID(submission.ChallengeID)
 	if err != nil {
@@ -134,6 +562,12 @@ func (s *submissionService) SubmitSubmission(submission *entities.Submission) (*
 	}
 
 	submission.SubmissionTestcases = submissionTestcases
+	submission.MaxScore = challenge.MaxScore()
+
+	err = submission.TransitionTo(entities.SubmissionStateQueued, time.Now())
+	if err != nil {
+		return nil, err
+	}
 
 	// create submission
 	submission, err = s.CreateSubmission(submission)
@@ -187,10 +621,14 @@ func (s *submissionService) GetSubmissionTestcaseBySubmission(submission *entiti
 	return submissionTestcases, err
 }
 
-func NewSubmissionService(submissionRepository repositories.SubmissionRepository, challengeService ChallengeService, sandboxService SandboxService) SubmissionService {
+func NewSubmissionService(submissionRepository repositories.SubmissionRepository, challengeService ChallengeService, sandboxService SandboxService, eventService SubmissionEventService, workerID, stderrVisibility string, testcaseLimiter TestcaseLimiter) SubmissionService {
 	return &submissionService{
 		submissionRepository: submissionRepository,
 		challengeService:     challengeService,
 		sandboxService:       sandboxService,
+		eventService:         eventService,
+		workerID:             workerID,
+		stderrVisibility:     stderrVisibility,
+		testcaseLimiter:      testcaseLimiter,
 	}
 }
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"sync"
+
+	"github.com/wuttinanhi/code-judge-system/entities"
+)
+
+// SubmissionEventService carries submission progress from the judge to API clients.
+// The judge publishes events to a Kafka topic, and every API process listens to
+// the topic and passes each event to the clients subscribed to the submission.
+type SubmissionEventService interface {
+	Publish(event *entities.SubmissionEvent) error
+	Subscribe(submissionID uint) (events chan *entities.SubmissionEvent, unsubscribe func())
+}
+
+type submissionEventService struct {
+	kafkaService KafkaService
+	topic        string
+	mutex        sync.Mutex
+	subscribers  map[uint]map[chan *entities.SubmissionEvent]bool
+	listenOnce   sync.Once
+}
+
+// Publish implements SubmissionEventService.
+func (s *submissionEventService) Publish(event *entities.SubmissionEvent) error {
+	message, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	// keyed by submission so its events reach listeners in order
+	key := strconv.FormatUint(uint64(event.SubmissionID), 10)
+	return s.kafkaService.ProduceEvent(s.topic, key, string(message))
+}
+
+// Subscribe implements SubmissionEventService.
+// The topic is only consumed once the first client subscribes, so judge
+// processes that only publish never listen to it.
+func (s *submissionEventService) Subscribe(submissionID uint) (chan *entities.SubmissionEvent, func()) {
+	s.listenOnce.Do(func() {
+		messageC, errorC := s.kafkaService.Broadcast(s.topic)
+		go s.listen(messageC, errorC)
+	})
+
+	events := make(chan *entities.SubmissionEvent, 64)
+
+	s.mutex.Lock()
+	if s.subscribers[submissionID] == nil {
+		s.subscribers[submissionID] = map[chan *entities.SubmissionEvent]bool{}
+	}
+	s.subscribers[submissionID][events] = true
+	s.mutex.Unlock()
+
+	unsubscribe := func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		delete(s.subscribers[submissionID], events)
+		if len(s.subscribers[submissionID]) == 0 {
+			delete(s.subscribers, submissionID)
+		}
+	}
+
+	return events, unsubscribe
+}
+
+func (s *submissionEventService) listen(messageC chan string, errorC chan error) {
+	for {
+		select {
+		case message, ok := <-messageC:
+			if !ok {
+				// the kafka service is closed
+				return
+			}
+
+			var event entities.SubmissionEvent
+			err := json.Unmarshal([]byte(message), &event)
+			if err != nil {
+				log.Println("failed to parse submission event:", err)
+				continue
+			}
+
+			s.dispatch(&event)
+		case err, ok := <-errorC:
+			if !ok {
+				errorC = nil
+				continue
+			}
+
+			log.Println(err)
+		}
+	}
+}
+
+// dispatch passes the event to every subscriber of its submission.
+// A subscriber that is too slow to keep up misses the event instead of blocking the others.
+func (s *submissionEventService) dispatch(event *entities.SubmissionEvent) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for events := range s.subscribers[event.SubmissionID] {
+		select {
+		case events <- event:
+		default:
+			log.Println("dropped event of submission ID:", event.SubmissionID)
+		}
+	}
+}
+
+func NewSubmissionEventService(kafkaService KafkaService, topic string) SubmissionEventService {
+	return &submissionEventService{
+		kafkaService: kafkaService,
+		topic:        topic,
+		subscribers:  map[uint]map[chan *entities.SubmissionEvent]bool{},
+	}
+}
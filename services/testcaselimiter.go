@@ -0,0 +1,59 @@
+package services
+
+import "sync"
+
+// TestcaseLimiter bounds the number of testcases that run at the same time in a judge process.
+// Testcases waiting for a slot are served in the order they asked for one.
+type TestcaseLimiter interface {
+	// Acquire blocks until a slot is free and returns a function that frees it.
+	Acquire() (release func())
+	// AcquireExclusive blocks until every slot is free and returns a function that frees them,
+	// so a timing sensitive testcase runs alone.
+	AcquireExclusive() (release func())
+}
+
+type testcaseLimiter struct {
+	slots chan struct{}
+	// exclusive keeps exclusive acquirers from holding part of the slots each and waiting forever.
+	exclusive sync.Mutex
+}
+
+// Acquire implements TestcaseLimiter.
+func (l *testcaseLimiter) Acquire() (release func()) {
+	if l.slots == nil {
+		return func() {}
+	}
+
+	l.slots <- struct{}{}
+	return func() { <-l.slots }
+}
+
+// AcquireExclusive implements TestcaseLimiter.
+// Without a limit there are no slots to take, so testcases are not kept apart.
+func (l *testcaseLimiter) AcquireExclusive() (release func()) {
+	if l.slots == nil {
+		return func() {}
+	}
+
+	l.exclusive.Lock()
+	for i := 0; i < cap(l.slots); i++ {
+		l.slots <- struct{}{}
+	}
+	l.exclusive.Unlock()
+
+	return func() {
+		for i := 0; i < cap(l.slots); i++ {
+			<-l.slots
+		}
+	}
+}
+
+// NewTestcaseLimiter creates a limiter that runs at most limit testcases at the same time.
+// A limit of zero or less does not limit testcases.
+func NewTestcaseLimiter(limit int) TestcaseLimiter {
+	limiter := &testcaseLimiter{}
+	if limit > 0 {
+		limiter.slots = make(chan struct{}, limit)
+	}
+	return limiter
+}
@@ -0,0 +1,84 @@
+package tests_test
+
+import (
+	"testing"
+
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/services"
+)
+
+func TestChecker(t *testing.T) {
+	type checkCase struct {
+		name     string
+		expected string
+		output   string
+		status   string
+	}
+
+	runCases := func(t *testing.T, checker services.Checker, cases []checkCase) {
+		for _, c := range cases {
+			status, note := checker.Check("", c.expected, c.output)
+			if status != c.status {
+				t.Errorf("%s: expected status %v, got %v (%v)", c.name, c.status, status, note)
+			}
+		}
+	}
+
+	t.Run("Exact Checker", func(t *testing.T) {
+		runCases(t, services.NewExactChecker(), []checkCase{
+			{"same output", "3\n", "3\n", entities.SubmissionStatusCorrect},
+			{"missing newline", "3\n", "3", entities.SubmissionStatusWrong},
+			{"different output", "3\n", "4\n", entities.SubmissionStatusWrong},
+		})
+	})
+
+	t.Run("Token Checker", func(t *testing.T) {
+		runCases(t, services.NewTokenChecker(), []checkCase{
+			{"same output", "1 2 3\n", "1 2 3\n", entities.SubmissionStatusCorrect},
+			{"different whitespace", "1 2 3\n", "1\n2   3", entities.SubmissionStatusCorrect},
+			{"missing token", "1 2 3\n", "1 2\n", entities.SubmissionStatusWrong},
+			{"different token", "1 2 3\n", "1 2 4\n", entities.SubmissionStatusWrong},
+		})
+	})
+
+	t.Run("Line Checker", func(t *testing.T) {
+		runCases(t, services.NewLineChecker(), []checkCase{
+			{"same output", "a b\nc\n", "a b\nc\n", entities.SubmissionStatusCorrect},
+			{"trailing spaces", "a b\nc\n", "a b  \nc\t\n", entities.SubmissionStatusCorrect},
+			{"trailing empty lines", "a b\nc\n", "a b\nc\n\n\n", entities.SubmissionStatusCorrect},
+			{"crlf line endings", "a b\nc\n", "a b\r\nc\r\n", entities.SubmissionStatusCorrect},
+			{"leading spaces", "a b\nc\n", " a b\nc\n", entities.SubmissionStatusWrong},
+			{"joined lines", "a b\nc\n", "a b c\n", entities.SubmissionStatusWrong},
+		})
+	})
+
+	t.Run("Float Checker", func(t *testing.T) {
+		runCases(t, services.NewFloatChecker(1e-3, 0), []checkCase{
+			{"same output", "0.333\n", "0.333\n", entities.SubmissionStatusCorrect},
+			{"within absolute epsilon", "0.333\n", "0.3333333\n", entities.SubmissionStatusCorrect},
+			{"outside absolute epsilon", "0.333\n", "0.335\n", entities.SubmissionStatusWrong},
+			{"mixed tokens", "YES 1.5\n", "YES 1.5001\n", entities.SubmissionStatusCorrect},
+			{"different word", "YES 1.5\n", "NO 1.5\n", entities.SubmissionStatusWrong},
+		})
+
+		runCases(t, services.NewFloatChecker(0, 1e-6), []checkCase{
+			{"within relative epsilon", "1000000000\n", "1000000100\n", entities.SubmissionStatusCorrect},
+			{"outside relative epsilon", "1000000000\n", "1000010000\n", entities.SubmissionStatusWrong},
+		})
+	})
+
+	t.Run("Challenge Checker", func(t *testing.T) {
+		checker, err := services.NewChallengeChecker(&entities.Challenge{Checker: entities.ChallengeCheckerToken}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		runCases(t, checker, []checkCase{
+			{"token checker selected", "1 2\n", "1\n2", entities.SubmissionStatusCorrect},
+		})
+
+		_, err = services.NewChallengeChecker(&entities.Challenge{Checker: "unknown"}, nil)
+		if err == nil {
+			t.Error("Expected error for unknown checker")
+		}
+	})
+}
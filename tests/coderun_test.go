@@ -0,0 +1,190 @@
+package tests_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/wuttinanhi/code-judge-system/controllers"
+	"github.com/wuttinanhi/code-judge-system/databases"
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/services"
+	"github.com/wuttinanhi/code-judge-system/tests"
+)
+
+func TestCodeRunRoute(t *testing.T) {
+	db := databases.NewTempSQLiteDatabase()
+	testServiceKit := services.CreateTestServiceKit(db)
+	rateLimitStorage := controllers.GetMemoryStorage()
+	app := controllers.SetupAPI(testServiceKit, rateLimitStorage)
+
+	user, err := testServiceKit.UserService.Register("test-code-run@example.com", "testpassword", "test-code-run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userAccessToken, err := testServiceKit.JWTService.GenerateToken(*user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherUser, err := testServiceKit.UserService.Register("test-code-run-other@example.com", "testpassword", "test-code-run-other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherUserAccessToken, err := testServiceKit.JWTService.GenerateToken(*otherUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runCode := func(accessToken string, dto entities.CodeRunCreateDTO) *http.Response {
+		requestBody, _ := json.Marshal(dto)
+
+		request, _ := http.NewRequest(http.MethodPost, "/submission/run", bytes.NewBuffer(requestBody))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Authorization", "Bearer "+accessToken)
+
+		response, err := app.Test(request, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	t.Run("/submission/run", func(t *testing.T) {
+		response := runCode(userAccessToken, entities.CodeRunCreateDTO{
+			Language: "python",
+			Code:     entities.PythonCodeExample,
+			Stdin:    "1\n2\n",
+		})
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status OK, got %v", response.StatusCode)
+		}
+
+		var codeRun entities.CodeRun
+		err := json.Unmarshal(tests.ResponseBodyToBytes(response), &codeRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if codeRun.Status != entities.SubmissionStatusPending {
+			t.Errorf("Expected status %v, got %v", entities.SubmissionStatusPending, codeRun.Status)
+		}
+		if codeRun.UserID != user.ID {
+			t.Errorf("Expected user id %v, got %v", user.ID, codeRun.UserID)
+		}
+
+		// a code run must not create a submission
+		submissions, err := testServiceKit.SubmissionService.GetSubmissionByUser(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(submissions) != 0 {
+			t.Errorf("Expected no submissions, got %v", len(submissions))
+		}
+	})
+
+	t.Run("/submission/run unsupported language", func(t *testing.T) {
+		response := runCode(userAccessToken, entities.CodeRunCreateDTO{
+			Language: "brainfuck",
+			Code:     "+",
+		})
+		if response.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected status BadRequest, got %v", response.StatusCode)
+		}
+	})
+
+	t.Run("/submission/run/:id", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/submission/run/1", nil)
+		request.Header.Set("Authorization", "Bearer "+userAccessToken)
+
+		response, err := app.Test(request, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.StatusCode != http.StatusOK {
+			t.Errorf("Expected status OK, got %v", response.StatusCode)
+		}
+
+		// other users can not see the code run
+		request, _ = http.NewRequest(http.MethodGet, "/submission/run/1", nil)
+		request.Header.Set("Authorization", "Bearer "+otherUserAccessToken)
+
+		response, err = app.Test(request, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected status Forbidden, got %v", response.StatusCode)
+		}
+	})
+
+	t.Run("/submission/run rate limit", func(t *testing.T) {
+		var lastStatus int
+		for i := 0; i < 20; i++ {
+			response := runCode(otherUserAccessToken, entities.CodeRunCreateDTO{
+				Language: "python",
+				Code:     fmt.Sprintf("print(%d)", i),
+			})
+			lastStatus = response.StatusCode
+		}
+		if lastStatus != http.StatusTooManyRequests {
+			t.Errorf("Expected status TooManyRequests, got %v", lastStatus)
+		}
+	})
+}
+
+func TestCodeRunProcess(t *testing.T) {
+	db := databases.NewTempSQLiteDatabase()
+	fakeDocker := tests.NewFakeDockerService()
+	testServiceKit := services.CreateTestServiceKitWithDocker(db, fakeDocker)
+
+	user, err := testServiceKit.UserService.Register("test-code-run-process@example.com", "testpassword", "test-code-run-process")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(t *testing.T, code string) *entities.CodeRun {
+		codeRun, err := testServiceKit.CodeRunService.CreateCodeRun(&entities.CodeRun{
+			Language: entities.GoInstructionBook.Language,
+			Code:     code,
+			UserID:   user.ID,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		codeRun, err = testServiceKit.CodeRunService.ProcessCodeRun(codeRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return codeRun
+	}
+
+	t.Run("Compilation Error", func(t *testing.T) {
+		code := "package main // broken"
+		fakeDocker.HandleCompile(code, func(process *tests.FakeProcess) tests.FakeExit {
+			fmt.Fprint(process.Stderr, "syntax error\n")
+			return tests.FakeExit{ExitCode: 2}
+		})
+
+		codeRun := run(t, code)
+		if codeRun.Status != entities.SubmissionStatusCompilationError {
+			t.Errorf("expected status %s, got %s", entities.SubmissionStatusCompilationError, codeRun.Status)
+		}
+	})
+
+	t.Run("Compilation Output Limit Exceeded", func(t *testing.T) {
+		code := "package main // noisy"
+		fakeDocker.HandleCompile(code, func(process *tests.FakeProcess) tests.FakeExit {
+			fmt.Fprint(process.Stderr, strings.Repeat("warning\n", 256*1024))
+			return tests.FakeExit{}
+		})
+
+		codeRun := run(t, code)
+		if codeRun.Status != entities.SubmissionStatusCompilationError {
+			t.Errorf("expected status %s, got %s", entities.SubmissionStatusCompilationError, codeRun.Status)
+		}
+	})
+}
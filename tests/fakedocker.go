@@ -0,0 +1,777 @@
+package tests
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/services"
+)
+
+// FakeProgram simulates what runs in a container. It reads its stdin and files through
+// the process, writes its output and returns how it ended.
+type FakeProgram func(process *FakeProcess) FakeExit
+
+// FakeExit is how a fake program ended.
+type FakeExit struct {
+	ExitCode int
+	// Hang keeps the program running until its container is stopped, so waiting for it times out.
+	Hang bool
+	// OOMKilled reports the program killed by the OOM killer, with exit code 137 unless another is set.
+	OOMKilled bool
+	// TimeMs and MemoryUsage are reported as the usage of programs run with the usage wrapper.
+	TimeMs      uint
+	MemoryUsage uint
+}
+
+// FakeProcess is a container or an exec the fake runs a program for.
+type FakeProcess struct {
+	Config services.ContainerConfig
+	// Command is the shell command of the process.
+	Command string
+	Stdin   io.Reader
+	Stdout  io.Writer
+	Stderr  io.Writer
+
+	service   *FakeDockerService
+	container *fakeContainer
+}
+
+// ReadFile returns a file of the volumes mounted into the process.
+func (p *FakeProcess) ReadFile(filePath string) (string, bool) {
+	p.service.mutex.Lock()
+	defer p.service.mutex.Unlock()
+
+	files, name := p.service.resolve(p.container, filePath)
+	content, ok := files[name]
+	return content, ok
+}
+
+// WriteFile writes a file into the volumes mounted into the process.
+func (p *FakeProcess) WriteFile(filePath, content string) {
+	p.service.mutex.Lock()
+	defer p.service.mutex.Unlock()
+
+	files, name := p.service.resolve(p.container, filePath)
+	files[name] = content
+}
+
+// HasFile reports whether a file under dir has exactly content, e.g. the code of a program in /sandbox.
+func (p *FakeProcess) HasFile(dir, content string) bool {
+	p.service.mutex.Lock()
+	defer p.service.mutex.Unlock()
+
+	files, prefix := p.service.resolve(p.container, dir)
+	for name, fileContent := range files {
+		if strings.HasPrefix(name, prefix) && fileContent == content {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRun reports whether the process runs a program with the usage wrapper of the sandbox,
+// as opposed to compiling it or preparing volumes.
+func (p *FakeProcess) IsRun() bool {
+	return strings.Contains(p.Command, entities.SandboxUsageMarker)
+}
+
+type fakeHandler struct {
+	match   func(process *FakeProcess) bool
+	program FakeProgram
+}
+
+type fakeContainer struct {
+	id     string
+	config services.ContainerConfig
+	// volumes maps mount targets to volume names, anonymous mounts get a volume of their own.
+	volumes   map[string]string
+	anonymous []string
+	rootfs    map[string]string
+	stdout    bytes.Buffer
+	stderr    bytes.Buffer
+	state     types.ContainerState
+	attach    *fakeConn
+	done      chan struct{}
+	hung      chan struct{}
+	finish    sync.Once
+}
+
+// FakeDockerService is an in-process services.DockerService for tests that run without
+// a Docker daemon. A container runs the first program whose match accepts its process,
+// containers without a program exit with code 0 and no output. Volumes keep the files
+// copied into them and programs see them at their mount targets.
+type FakeDockerService struct {
+	mutex    sync.Mutex
+	handlers []fakeHandler
+	images   map[string]bool
+	volumes  map[string]map[string]string
+	// volumeLabels are the labels of the named volumes.
+	volumeLabels map[string]map[string]string
+	containers   map[string]*fakeContainer
+	nextID       int
+}
+
+// Handle runs program in the containers and execs match accepts.
+func (s *FakeDockerService) Handle(match func(process *FakeProcess) bool, program FakeProgram) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.handlers = append(s.handlers, fakeHandler{match: match, program: program})
+}
+
+// HandleCode runs program for the runs of the code, checkers and interactors included.
+func (s *FakeDockerService) HandleCode(code string, program FakeProgram) {
+	s.Handle(func(process *FakeProcess) bool {
+		return process.IsRun() && process.HasFile("/sandbox", code)
+	}, program)
+}
+
+// HandleCompile runs program for the compile container of the code.
+func (s *FakeDockerService) HandleCompile(code string, program FakeProgram) {
+	s.Handle(func(process *FakeProcess) bool {
+		return !process.IsRun() && !process.Config.Helper && process.HasFile("/sandbox", code)
+	}, program)
+}
+
+// ContainerCount returns the number of containers that were created and not removed.
+func (s *FakeDockerService) ContainerCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.containers)
+}
+
+// VolumeCount returns the number of volumes that were created and not deleted.
+func (s *FakeDockerService) VolumeCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.volumes)
+}
+
+// resolve returns the files of the volume mounted at filePath and the name of filePath in it.
+// Paths outside the volumes are on the root filesystem of the container.
+func (s *FakeDockerService) resolve(c *fakeContainer, filePath string) (map[string]string, string) {
+	filePath = path.Clean(filePath)
+
+	// the deepest mount wins
+	targets := make([]string, 0, len(c.volumes))
+	for target := range c.volumes {
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool { return len(targets[i]) > len(targets[j]) })
+
+	for _, target := range targets {
+		if filePath == target {
+			return s.volumes[c.volumes[target]], ""
+		}
+		if strings.HasPrefix(filePath, target+"/") {
+			return s.volumes[c.volumes[target]], strings.TrimPrefix(filePath, target+"/")
+		}
+	}
+	return c.rootfs, strings.TrimPrefix(filePath, "/")
+}
+
+func (s *FakeDockerService) container(containerID string) (*fakeContainer, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	c, ok := s.containers[containerID]
+	if !ok {
+		return nil, fmt.Errorf("no such container: %s", containerID)
+	}
+	return c, nil
+}
+
+// program returns the program of the process, nil when no handler accepts it.
+func (s *FakeDockerService) program(process *FakeProcess) FakeProgram {
+	s.mutex.Lock()
+	handlers := s.handlers
+	s.mutex.Unlock()
+
+	for _, handler := range handlers {
+		if handler.match(process) {
+			return handler.program
+		}
+	}
+	return nil
+}
+
+// shellCommand returns the command run by "/bin/sh -c", or the joined command otherwise.
+func shellCommand(command []string) string {
+	if len(command) == 3 && command[0] == "/bin/sh" && command[1] == "-c" {
+		return command[2]
+	}
+	return strings.Join(command, " ")
+}
+
+var fakeStdinRedirect = regexp.MustCompile(`<\s*(/stdin/[^\s;]+)`)
+
+// run runs the program of the process and reports the usage like the usage wrapper would.
+func (s *FakeDockerService) run(process *FakeProcess) FakeExit {
+	// the program reads a redirected file as stdin
+	if process.Stdin == nil {
+		process.Stdin = strings.NewReader("")
+		if match := fakeStdinRedirect.FindStringSubmatch(process.Command); match != nil {
+			content, _ := process.ReadFile(match[1])
+			process.Stdin = strings.NewReader(content)
+		}
+	}
+
+	var exit FakeExit
+	if program := s.program(process); program != nil {
+		exit = program(process)
+	} else {
+		s.removeFiles(process)
+	}
+	if exit.OOMKilled && exit.ExitCode == 0 {
+		exit.ExitCode = 137
+	}
+
+	if process.IsRun() && !exit.Hang {
+		oomKills := 0
+		if exit.OOMKilled {
+			oomKills = 1
+		}
+		fmt.Fprintf(process.Stderr, "\n%s %d %d %d\n", entities.SandboxUsageMarker, exit.TimeMs*1000, exit.MemoryUsage, oomKills)
+	}
+
+	return exit
+}
+
+var fakeRemove = regexp.MustCompile(`rm -rf ([^;&|]+)`)
+
+// removeFiles runs the "rm -rf dir/*" of a command, e.g. when the pool resets a container.
+func (s *FakeDockerService) removeFiles(process *FakeProcess) {
+	match := fakeRemove.FindStringSubmatch(process.Command)
+	if match == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, pattern := range strings.Fields(match[1]) {
+		if !strings.HasSuffix(pattern, "/*") {
+			continue
+		}
+		files, prefix := s.resolve(process.container, strings.TrimSuffix(pattern, "/*"))
+		for name := range files {
+			if prefix == "" || strings.HasPrefix(name, prefix+"/") {
+				delete(files, name)
+			}
+		}
+	}
+}
+
+// limitOutput cuts stdout and stderr down to limit bytes together like the output limiter.
+func limitOutput(stdout, stderr string, limit int) (string, string, bool) {
+	if len(stdout)+len(stderr) <= limit {
+		return stdout, stderr, false
+	}
+	if len(stdout) > limit {
+		return stdout[:limit], "", true
+	}
+	return stdout, stderr[:limit-len(stdout)], true
+}
+
+// WaitContainer implements services.DockerService.
+// Hanging programs time out right away instead of after the timeout.
+func (s *FakeDockerService) WaitContainer(containerID string, timeout uint) string {
+	c, err := s.container(containerID)
+	if err != nil {
+		return services.WaitResultError
+	}
+
+	select {
+	case <-c.done:
+		return services.WaitResultSuccess
+	case <-c.hung:
+		return services.WaitResultTimeout
+	case <-time.After(time.Duration(timeout) * time.Millisecond):
+		return services.WaitResultTimeout
+	}
+}
+
+// PullImage implements services.DockerService.
+func (s *FakeDockerService) PullImage(imageName string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.images[imageName] = true
+	return nil
+}
+
+// ImageExist implements services.DockerService.
+func (s *FakeDockerService) ImageExist(imageName string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.images[imageName], nil
+}
+
+// CaptureLog implements services.DockerService.
+func (s *FakeDockerService) CaptureLog(containerID string, limit int) (stdout, stderr string, exceeded bool, err error) {
+	c, err := s.container(containerID)
+	if err != nil {
+		return "", "", false, err
+	}
+	<-c.done
+
+	stdout, stderr, exceeded = limitOutput(c.stdout.String(), c.stderr.String(), limit)
+	return stdout, stderr, exceeded, nil
+}
+
+// GetContainerExitCode implements services.DockerService.
+func (s *FakeDockerService) GetContainerExitCode(containerID string) (int, error) {
+	state, err := s.GetContainerState(containerID)
+	if err != nil {
+		return 0, err
+	}
+	return state.ExitCode, nil
+}
+
+// GetContainerState implements services.DockerService.
+func (s *FakeDockerService) GetContainerState(containerID string) (*types.ContainerState, error) {
+	c, err := s.container(containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	state := c.state
+	return &state, nil
+}
+
+// CreateVolume implements services.DockerService.
+func (s *FakeDockerService) CreateVolume(name string, labels map[string]string) (volume.Volume, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.volumes[name] = map[string]string{}
+	s.volumeLabels[name] = labels
+	return volume.Volume{Name: name, Driver: "local", Labels: labels}, nil
+}
+
+// DeleteVolume implements services.DockerService.
+func (s *FakeDockerService) DeleteVolume(v volume.Volume) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.volumes[v.Name]; !ok {
+		return fmt.Errorf("no such volume: %s", v.Name)
+	}
+	for _, c := range s.containers {
+		for _, name := range c.volumes {
+			if name == v.Name {
+				return fmt.Errorf("volume is in use: %s", v.Name)
+			}
+		}
+	}
+	delete(s.volumes, v.Name)
+	delete(s.volumeLabels, v.Name)
+	return nil
+}
+
+// ListVolumes implements services.DockerService.
+func (s *FakeDockerService) ListVolumes(label string) ([]*volume.Volume, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var volumes []*volume.Volume
+	for name, labels := range s.volumeLabels {
+		if _, ok := labels[label]; ok {
+			volumes = append(volumes, &volume.Volume{Name: name, Driver: "local", Labels: labels})
+		}
+	}
+	return volumes, nil
+}
+
+// CopyToContainer implements services.DockerService.
+func (s *FakeDockerService) CopyToContainer(containerID, targetPath string, content []byte) error {
+	c, err := s.container(containerID)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	files, name := s.resolve(c, targetPath)
+	files[name] = string(content)
+	return nil
+}
+
+// CopyArchiveToContainer implements services.DockerService.
+func (s *FakeDockerService) CopyArchiveToContainer(containerID, targetDir string, archive []byte) error {
+	c, err := s.container(containerID)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tr := tar.NewReader(bytes.NewReader(archive))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		files, name := s.resolve(c, path.Join(targetDir, header.Name))
+		files[name] = string(content)
+	}
+}
+
+// CopyFromContainer implements services.DockerService.
+func (s *FakeDockerService) CopyFromContainer(containerID, sourcePath string) ([]byte, error) {
+	c, err := s.container(containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	files, prefix := s.resolve(c, sourcePath)
+	base := path.Base(sourcePath)
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if prefix == "" || strings.HasPrefix(name, prefix+"/") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: base + "/", Typeflag: tar.TypeDir, Mode: 0755})
+	for _, name := range names {
+		relative := strings.TrimPrefix(strings.TrimPrefix(name, prefix), "/")
+		tw.WriteHeader(&tar.Header{Name: base + "/" + relative, Mode: 0755, Size: int64(len(files[name]))})
+		tw.Write([]byte(files[name]))
+	}
+	err = tw.Close()
+	return buf.Bytes(), err
+}
+
+// CreateContainer implements services.DockerService.
+func (s *FakeDockerService) CreateContainer(config services.ContainerConfig) (response container.CreateResponse, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.nextID++
+	c := &fakeContainer{
+		id:      fmt.Sprintf("fake-%d", s.nextID),
+		config:  config,
+		volumes: map[string]string{},
+		rootfs:  map[string]string{},
+		done:    make(chan struct{}),
+		hung:    make(chan struct{}),
+	}
+
+	for _, m := range config.Mounts {
+		name := m.Source
+		if m.Type == mount.TypeVolume && name == "" {
+			name = c.id + "-" + strings.ReplaceAll(strings.Trim(m.Target, "/"), "/", "-")
+			s.volumes[name] = map[string]string{}
+			c.anonymous = append(c.anonymous, name)
+		}
+		if _, ok := s.volumes[name]; !ok {
+			return response, fmt.Errorf("no such volume: %s", name)
+		}
+		c.volumes[path.Clean(m.Target)] = name
+	}
+
+	s.containers[c.id] = c
+	return container.CreateResponse{ID: c.id}, nil
+}
+
+// AttachContainer implements services.DockerService.
+func (s *FakeDockerService) AttachContainer(containerID string) (types.HijackedResponse, error) {
+	c, err := s.container(containerID)
+	if err != nil {
+		return types.HijackedResponse{}, err
+	}
+
+	c.attach = &fakeConn{stdin: newFakePipe(), output: newFakePipe()}
+	return types.HijackedResponse{Conn: c.attach, Reader: bufio.NewReader(c.attach)}, nil
+}
+
+// StartContainer implements services.DockerService.
+// The program runs in the background like a started container.
+func (s *FakeDockerService) StartContainer(containerID string) error {
+	c, err := s.container(containerID)
+	if err != nil {
+		return err
+	}
+
+	process := &FakeProcess{
+		Config:    c.config,
+		Command:   shellCommand(c.config.Command),
+		Stdout:    &c.stdout,
+		Stderr:    &c.stderr,
+		service:   s,
+		container: c,
+	}
+	if c.attach != nil {
+		process.Stdin = c.attach.stdin
+		process.Stdout = io.MultiWriter(&c.stdout, stdcopy.NewStdWriter(c.attach.output, stdcopy.Stdout))
+		process.Stderr = io.MultiWriter(&c.stderr, stdcopy.NewStdWriter(c.attach.output, stdcopy.Stderr))
+	}
+
+	s.mutex.Lock()
+	c.state.Running = true
+	s.mutex.Unlock()
+
+	go func() {
+		var exit FakeExit
+		if !c.config.Helper {
+			exit = s.run(process)
+		}
+
+		if exit.Hang {
+			close(c.hung)
+			return
+		}
+		s.stop(c, exit.ExitCode, exit.OOMKilled)
+	}()
+
+	return nil
+}
+
+// stop ends the container once with the exit code and closes its attached streams.
+func (s *FakeDockerService) stop(c *fakeContainer, exitCode int, oomKilled bool) {
+	c.finish.Do(func() {
+		s.mutex.Lock()
+		c.state.Running = false
+		c.state.ExitCode = exitCode
+		c.state.OOMKilled = oomKilled
+		s.mutex.Unlock()
+
+		if c.attach != nil {
+			c.attach.output.Close()
+			c.attach.stdin.Close()
+		}
+		close(c.done)
+	})
+}
+
+// StopContainer implements services.DockerService.
+// A running program is killed with exit code 137.
+func (s *FakeDockerService) StopContainer(containerID string) error {
+	c, err := s.container(containerID)
+	if err != nil {
+		return err
+	}
+
+	s.stop(c, 137, false)
+	return nil
+}
+
+// UpdateContainerMemory implements services.DockerService.
+func (s *FakeDockerService) UpdateContainerMemory(containerID string, memoryLimit int64) error {
+	c, err := s.container(containerID)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	c.config.MemoryLimit = memoryLimit
+	return nil
+}
+
+// ExecContainer implements services.DockerService.
+func (s *FakeDockerService) ExecContainer(containerID, user string, command []string, timeout uint, outputLimit int) (*services.ExecResult, error) {
+	c, err := s.container(containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	config := c.config
+	config.User = user
+	config.Command = command
+	exit := s.run(&FakeProcess{
+		Config:    config,
+		Command:   shellCommand(command),
+		Stdout:    &stdout,
+		Stderr:    &stderr,
+		service:   s,
+		container: c,
+	})
+
+	result := &services.ExecResult{
+		ExitCode: exit.ExitCode,
+		Timeout:  exit.Hang,
+	}
+	if exit.Hang {
+		result.ExitCode = 137
+	}
+	result.Stdout, result.Stderr, result.OutputLimitExceeded = limitOutput(stdout.String(), stderr.String(), outputLimit)
+	return result, nil
+}
+
+// RemoveContainer implements services.DockerService.
+// The anonymous volumes of the container are removed with it.
+func (s *FakeDockerService) RemoveContainer(containerID string) error {
+	c, err := s.container(containerID)
+	if err != nil {
+		return err
+	}
+	s.stop(c, 137, false)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, name := range c.anonymous {
+		delete(s.volumes, name)
+	}
+	delete(s.containers, containerID)
+	return nil
+}
+
+// ListContainers implements services.DockerService.
+func (s *FakeDockerService) ListContainers(label string) ([]types.Container, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var containers []types.Container
+	for _, c := range s.containers {
+		if _, ok := c.config.Labels[label]; ok {
+			containers = append(containers, types.Container{
+				ID:     c.id,
+				Names:  []string{"/" + c.config.Name},
+				Labels: c.config.Labels,
+				State:  c.state.Status,
+			})
+		}
+	}
+	return containers, nil
+}
+
+// NewFakeDockerService creates a fake Docker service without images, volumes or programs.
+func NewFakeDockerService() *FakeDockerService {
+	return &FakeDockerService{
+		images:       map[string]bool{},
+		volumes:      map[string]map[string]string{},
+		volumeLabels: map[string]map[string]string{},
+		containers:   map[string]*fakeContainer{},
+	}
+}
+
+// fakePipe is an unbounded in-memory pipe, so writers never wait for a slow reader
+// like they would not with the buffers of a real connection.
+type fakePipe struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	buf    bytes.Buffer
+	closed bool
+}
+
+func newFakePipe() *fakePipe {
+	p := &fakePipe{}
+	p.cond = sync.NewCond(&p.mutex)
+	return p
+}
+
+// Read implements io.Reader, it waits for data until the pipe is closed.
+func (p *fakePipe) Read(b []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for p.buf.Len() == 0 && !p.closed {
+		p.cond.Wait()
+	}
+	if p.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return p.buf.Read(b)
+}
+
+// Write implements io.Writer.
+func (p *fakePipe) Write(b []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return 0, io.ErrClosedPipe
+	}
+	p.cond.Broadcast()
+	return p.buf.Write(b)
+}
+
+// Close implements io.Closer, the data written so far can still be read.
+func (p *fakePipe) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.closed = true
+	p.cond.Broadcast()
+	return nil
+}
+
+// fakeConn is the attached connection of a container: writes go to the stdin of the
+// program and reads return its multiplexed output.
+type fakeConn struct {
+	stdin  *fakePipe
+	output *fakePipe
+}
+
+func (c *fakeConn) Read(b []byte) (int, error)  { return c.output.Read(b) }
+func (c *fakeConn) Write(b []byte) (int, error) { return c.stdin.Write(b) }
+
+// Close implements net.Conn.
+func (c *fakeConn) Close() error {
+	c.stdin.Close()
+	c.output.Close()
+	return nil
+}
+
+// CloseWrite closes the stdin of the program like types.HijackedResponse.CloseWrite.
+func (c *fakeConn) CloseWrite() error { return c.stdin.Close() }
+
+func (c *fakeConn) LocalAddr() net.Addr                { return fakeAddr{} }
+func (c *fakeConn) RemoteAddr() net.Addr               { return fakeAddr{} }
+func (c *fakeConn) SetDeadline(t time.Time) error      { return nil }
+func (c *fakeConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *fakeConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type fakeAddr struct{}
+
+func (fakeAddr) Network() string { return "fake" }
+func (fakeAddr) String() string  { return "fake" }
+
+var _ services.DockerService = (*FakeDockerService)(nil)
@@ -0,0 +1,150 @@
+package tests_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/services"
+)
+
+func TestOffsetCommitter(t *testing.T) {
+	committed := map[int]int64{}
+	committer := services.NewOffsetCommitter(func(partition int, offset int64) error {
+		committed[partition] = offset
+		return nil
+	})
+
+	done := make([]func() error, 4)
+	for i := range done {
+		done[i] = committer.Track(0, int64(10+i))
+	}
+	doneOther := committer.Track(1, 5)
+
+	expectCommitted := func(partition int, offset int64) {
+		t.Helper()
+		got, ok := committed[partition]
+		if offset < 0 && ok {
+			t.Errorf("partition %d: expected no commit, got offset %d", partition, got)
+		}
+		if offset >= 0 && got != offset {
+			t.Errorf("partition %d: expected offset %d committed, got %d", partition, offset, got)
+		}
+	}
+
+	// a later message is done before the first one
+	done[2]()
+	done[1]()
+	expectCommitted(0, -1)
+
+	done[0]()
+	expectCommitted(0, 12)
+
+	// partitions are committed on their own
+	expectCommitted(1, -1)
+	doneOther()
+	expectCommitted(1, 5)
+
+	done[3]()
+	expectCommitted(0, 13)
+}
+
+func TestJobDispatcher(t *testing.T) {
+	t.Run("Jobs", func(t *testing.T) {
+		var mutex sync.Mutex
+		running := map[uint]bool{}
+		processed := map[uint]int{}
+		maxRunning, current := 0, 0
+		overlapped := false
+
+		dispatcher := services.NewJobDispatcher(entities.JobTypeSubmission, 2, func(message *services.KafkaMessage) {
+			job, err := entities.ParseJobEnvelope(message.Value, entities.JobTypeSubmission)
+			if err != nil {
+				return
+			}
+
+			mutex.Lock()
+			if running[job.ID] {
+				overlapped = true
+			}
+			running[job.ID] = true
+			current++
+			if current > maxRunning {
+				maxRunning = current
+			}
+			mutex.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+
+			mutex.Lock()
+			running[job.ID] = false
+			processed[job.ID]++
+			current--
+			mutex.Unlock()
+		})
+
+		// every job twice, like a rejudge arriving while the submission is judged
+		for _, id := range []uint{1, 2, 1, 2, 3, 4, 3, 4} {
+			dispatcher.Dispatch(services.NewKafkaMessage(strconv.Itoa(int(id)), nil))
+		}
+		dispatcher.Dispatch(services.NewKafkaMessage("not a job", nil))
+
+		closed := make(chan struct{})
+		go func() {
+			dispatcher.Close()
+			close(closed)
+		}()
+		select {
+		case <-closed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected close to return once the messages are processed")
+		}
+
+		if overlapped {
+			t.Error("expected messages of the same job not to be processed at the same time")
+		}
+		if maxRunning != 2 {
+			t.Errorf("expected 2 jobs at the same time, got %d", maxRunning)
+		}
+		for id := uint(1); id <= 4; id++ {
+			if processed[id] != 2 {
+				t.Errorf("expected job %d processed twice, got %d", id, processed[id])
+			}
+		}
+	})
+
+	t.Run("Busy Job", func(t *testing.T) {
+		release := make(chan struct{})
+		processedC := make(chan string, 8)
+
+		dispatcher := services.NewJobDispatcher(entities.JobTypeSubmission, 2, func(message *services.KafkaMessage) {
+			if message.Value == "1" {
+				<-release
+			}
+			processedC <- message.Value
+		})
+
+		// jobs behind a long job are taken by the free worker
+		for _, value := range []string{"1", "3", "5"} {
+			dispatcher.Dispatch(services.NewKafkaMessage(value, nil))
+		}
+		for _, expected := range []string{"3", "5"} {
+			select {
+			case value := <-processedC:
+				if value != expected {
+					t.Errorf("expected job %s processed, got %s", expected, value)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("expected job %s processed while job 1 runs", expected)
+			}
+		}
+
+		close(release)
+		dispatcher.Close()
+		if value := <-processedC; value != "1" {
+			t.Errorf("expected job 1 processed last, got %s", value)
+		}
+	})
+}
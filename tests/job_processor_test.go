@@ -0,0 +1,248 @@
+package tests_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/wuttinanhi/code-judge-system/controllers"
+	"github.com/wuttinanhi/code-judge-system/databases"
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/services"
+	"github.com/wuttinanhi/code-judge-system/tests"
+)
+
+func TestJobProcessor(t *testing.T) {
+	db := databases.NewTempSQLiteDatabase()
+	testServiceKit := services.CreateTestServiceKit(db)
+
+	topics := services.JobTopics{
+		Topic:           "job-topic",
+		RetryTopic:      "job-retry-topic",
+		DeadLetterTopic: "job-dead-letter-topic",
+	}
+	jobC, _ := testServiceKit.KafkaService.Broadcast(topics.Topic)
+	retryC, _ := testServiceKit.KafkaService.Broadcast(topics.RetryTopic)
+	deadLetterC, _ := testServiceKit.KafkaService.Broadcast(topics.DeadLetterTopic)
+
+	var handled []*entities.JobEnvelope
+	var handlerErr error
+	processor := services.NewJobProcessor(
+		testServiceKit.KafkaService,
+		testServiceKit.DeadLetterService,
+		topics,
+		entities.JobTypeSubmission,
+		2,
+		10*time.Millisecond,
+		func(ctx context.Context, job *entities.JobEnvelope) error {
+			handled = append(handled, job)
+			return handlerErr
+		},
+	)
+
+	process := func(t *testing.T, value string) {
+		committed := false
+		err := processor.Process(context.Background(), services.NewKafkaMessage(value, func() error {
+			committed = true
+			return nil
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !committed {
+			t.Error("expected message to be committed")
+		}
+	}
+
+	receive := func(t *testing.T, messageC chan string) string {
+		select {
+		case message := <-messageC:
+			return message
+		default:
+			t.Fatal("expected a message")
+			return ""
+		}
+	}
+
+	expectNone := func(t *testing.T, messageC chan string) {
+		select {
+		case message := <-messageC:
+			t.Errorf("expected no message, got %s", message)
+		default:
+		}
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		handled, handlerErr = nil, nil
+		process(t, "1")
+
+		if len(handled) != 1 || handled[0].ID != 1 {
+			t.Fatalf("expected job 1 to be handled, got %v", handled)
+		}
+		expectNone(t, retryC)
+		expectNone(t, deadLetterC)
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		handled, handlerErr = nil, errors.New("sandbox is down")
+		message, _ := entities.NewJobEnvelope(entities.JobTypeSubmission, 2).Encode()
+		process(t, message)
+
+		retry, err := entities.ParseJobEnvelope(receive(t, retryC), entities.JobTypeSubmission)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if retry.ID != 2 || retry.Attempt != 2 || retry.LastError != "sandbox is down" || retry.RetryAt == nil {
+			t.Errorf("unexpected retry %+v", retry)
+		}
+		expectNone(t, deadLetterC)
+
+		// the last attempt is dead lettered instead of retried
+		retryMessage, _ := retry.Encode()
+		start := time.Now()
+		process(t, retryMessage)
+		if time.Now().Before(*retry.RetryAt) {
+			t.Errorf("expected retry to wait for its backoff, waited %v", time.Since(start))
+		}
+
+		expectNone(t, retryC)
+		deadLetter, err := entities.ParseJobEnvelope(receive(t, deadLetterC), entities.JobTypeSubmission)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deadLetter.TraceID != retry.TraceID || deadLetter.Attempt != 2 {
+			t.Errorf("unexpected dead letter %+v", deadLetter)
+		}
+	})
+
+	t.Run("Retry Stopped", func(t *testing.T) {
+		handled, handlerErr = nil, nil
+		retry := entities.NewJobEnvelope(entities.JobTypeSubmission, 3)
+		retry.NextAttempt(errors.New("sandbox is down"), time.Hour, time.Now())
+		message, _ := retry.Encode()
+
+		ctx, stop := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer stop()
+
+		committed := false
+		err := processor.Process(ctx, services.NewKafkaMessage(message, func() error {
+			committed = true
+			return nil
+		}))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the wait for the backoff to stop, got %v", err)
+		}
+		if committed || len(handled) != 0 {
+			t.Error("expected a stopped retry not to be handled or committed")
+		}
+		expectNone(t, retryC)
+		expectNone(t, deadLetterC)
+	})
+
+	t.Run("Poison Message", func(t *testing.T) {
+		handled, handlerErr = nil, nil
+		process(t, "not a job")
+
+		if len(handled) != 0 {
+			t.Error("expected poison message not to be handled")
+		}
+		if message := receive(t, deadLetterC); message != "not a job" {
+			t.Errorf("expected poison message to be dead lettered, got %s", message)
+		}
+	})
+
+	t.Run("Dead Letter Routes", func(t *testing.T) {
+		rateLimitStorage := controllers.GetMemoryStorage()
+		app := controllers.SetupAPI(testServiceKit, rateLimitStorage)
+
+		adminUser, err := testServiceKit.UserService.Register("test-dead-letter-admin@example.com", "testpassword", "test-dead-letter-admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = testServiceKit.UserService.UpdateRole(adminUser, entities.UserRoleAdmin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		adminAccessToken, err := testServiceKit.JWTService.GenerateToken(*adminUser)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := testServiceKit.UserService.Register("test-dead-letter-user@example.com", "testpassword", "test-dead-letter-user")
+		if err != nil {
+			t.Fatal(err)
+		}
+		userAccessToken, err := testServiceKit.JWTService.GenerateToken(*user)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		request := func(method, url, accessToken string) *http.Response {
+			req, _ := http.NewRequest(method, url, nil)
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+			response, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return response
+		}
+
+		response := request(http.MethodGet, "/deadletter/pagination", userAccessToken)
+		if response.StatusCode != http.StatusForbidden {
+			t.Errorf("expected status Forbidden, got %v", response.StatusCode)
+		}
+
+		response = request(http.MethodGet, "/deadletter/pagination", adminAccessToken)
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("expected status OK, got %v", response.StatusCode)
+		}
+		var result entities.PaginationResult[*entities.DeadLetterJob]
+		json.Unmarshal(tests.ResponseBodyToBytes(response), &result)
+		if result.Total != 2 {
+			t.Fatalf("expected 2 dead letter jobs, got %d", result.Total)
+		}
+
+		var failedJob, poisonJob *entities.DeadLetterJob
+		for _, job := range result.Items {
+			if job.TraceID != "" {
+				failedJob = job
+			} else {
+				poisonJob = job
+			}
+		}
+		if failedJob == nil || poisonJob == nil {
+			t.Fatal("expected a failed and a poison dead letter job")
+		}
+		if failedJob.Topic != topics.Topic || failedJob.Error != "sandbox is down" || failedJob.Attempt != 2 {
+			t.Errorf("unexpected dead letter job %+v", failedJob)
+		}
+
+		// the failed job is replayed to its topic as a first attempt
+		response = request(http.MethodPost, fmt.Sprintf("/deadletter/replay/%d", failedJob.ID), adminAccessToken)
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("expected status OK, got %v", response.StatusCode)
+		}
+		replayed, err := entities.ParseJobEnvelope(receive(t, jobC), entities.JobTypeSubmission)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if replayed.ID != 2 || replayed.Attempt != 1 || replayed.RetryAt != nil || replayed.TraceID != failedJob.TraceID {
+			t.Errorf("unexpected replayed job %+v", replayed)
+		}
+
+		response = request(http.MethodPost, fmt.Sprintf("/deadletter/replay/%d", failedJob.ID), adminAccessToken)
+		if response.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected a second replay to fail, got %v", response.StatusCode)
+		}
+
+		response = request(http.MethodPost, fmt.Sprintf("/deadletter/replay/%d", poisonJob.ID), adminAccessToken)
+		if response.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected poison message replay to fail, got %v", response.StatusCode)
+		}
+		expectNone(t, jobC)
+	})
+}
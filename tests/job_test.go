@@ -0,0 +1,61 @@
+package tests_test
+
+import (
+	"testing"
+
+	"github.com/wuttinanhi/code-judge-system/entities"
+)
+
+func TestJobEnvelope(t *testing.T) {
+	t.Run("Round Trip", func(t *testing.T) {
+		job := entities.NewRejudgeJobEnvelope(42, "fixed testcase")
+		message, err := job.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := entities.ParseJobEnvelope(message, entities.JobTypeSubmission)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.ID != 42 || parsed.Version != entities.JobEnvelopeVersion || parsed.Attempt != 1 {
+			t.Errorf("unexpected envelope %+v", parsed)
+		}
+		if parsed.Priority != entities.JobPriorityLow || parsed.Reason != "fixed testcase" {
+			t.Errorf("expected low priority rejudge, got %+v", parsed)
+		}
+		if parsed.TraceID == "" || parsed.TraceID != job.TraceID {
+			t.Errorf("expected trace ID %q, got %q", job.TraceID, parsed.TraceID)
+		}
+	})
+
+	t.Run("Legacy Numeric ID", func(t *testing.T) {
+		parsed, err := entities.ParseJobEnvelope("17", entities.JobTypeCodeRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.ID != 17 || parsed.Type != entities.JobTypeCodeRun || parsed.Version != 0 {
+			t.Errorf("unexpected legacy envelope %+v", parsed)
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		codeRunJob, _ := entities.NewJobEnvelope(entities.JobTypeCodeRun, 1).Encode()
+
+		messages := []string{
+			"",
+			"0",
+			"not a job",
+			`{"version":1,"type":"submission"}`,
+			`{"version":99,"type":"submission","id":1}`,
+			`{"type":"submission","id":1}`,
+			codeRunJob,
+		}
+		for _, message := range messages {
+			_, err := entities.ParseJobEnvelope(message, entities.JobTypeSubmission)
+			if err == nil {
+				t.Errorf("expected message %q to be rejected", message)
+			}
+		}
+	})
+}
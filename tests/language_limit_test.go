@@ -0,0 +1,106 @@
+package tests_test
+
+import (
+	"testing"
+
+	"github.com/wuttinanhi/code-judge-system/databases"
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/services"
+)
+
+func TestLanguageLimit(t *testing.T) {
+	db := databases.NewTempSQLiteDatabase()
+	testServiceKit := services.CreateTestServiceKit(db)
+
+	t.Run("Apply Language Limit", func(t *testing.T) {
+		challenge := &entities.Challenge{
+			LanguageLimits: []*entities.ChallengeLanguageLimit{
+				{Language: "python", TimeMultiplier: 5},
+				{Language: "java", TimeMultiplier: 3, MemoryMultiplier: 4},
+			},
+		}
+
+		python := &entities.SandboxInstance{Language: "python", TimeMultiplier: 3, MemoryMultiplier: 1}
+		challenge.ApplyLanguageLimit(python)
+		if python.TimeMultiplier != 5 || python.MemoryMultiplier != 1 {
+			t.Errorf("Expected multipliers 5 and 1, got %v and %v", python.TimeMultiplier, python.MemoryMultiplier)
+		}
+
+		java := &entities.SandboxInstance{Language: "java", TimeMultiplier: 2, MemoryMultiplier: 2}
+		challenge.ApplyLanguageLimit(java)
+		if java.TimeMultiplier != 3 || java.MemoryMultiplier != 4 {
+			t.Errorf("Expected multipliers 3 and 4, got %v and %v", java.TimeMultiplier, java.MemoryMultiplier)
+		}
+
+		c := &entities.SandboxInstance{Language: "c", TimeMultiplier: 1, MemoryMultiplier: 1}
+		challenge.ApplyLanguageLimit(c)
+		if c.TimeMultiplier != 1 || c.MemoryMultiplier != 1 {
+			t.Errorf("Expected language multipliers to be kept, got %v and %v", c.TimeMultiplier, c.MemoryMultiplier)
+		}
+	})
+
+	t.Run("Sandbox Uses Language Multipliers", func(t *testing.T) {
+		instruction, err := testServiceKit.LanguageService.GetInstruction("python")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if instruction.TimeMultiplier != entities.PythonInstructionBook.TimeMultiplier {
+			t.Errorf("Expected python time multiplier %v, got %v", entities.PythonInstructionBook.TimeMultiplier, instruction.TimeMultiplier)
+		}
+	})
+
+	t.Run("Save Language Limits", func(t *testing.T) {
+		created, err := testServiceKit.ChallengeService.CreateChallenge(&entities.Challenge{
+			Name: "Language Limit Challenge",
+			Testcases: []*entities.ChallengeTestcase{
+				{Input: "1", ExpectedOutput: "1", LimitMemory: 1, LimitTimeMs: 1},
+			},
+			LanguageLimits: []*entities.ChallengeLanguageLimit{
+				{Language: "python", TimeMultiplier: 5},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		challenge, err := testServiceKit.ChallengeService.FindChallengeByID(created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(challenge.LanguageLimits) != 1 || challenge.LanguageLimits[0].TimeMultiplier != 5 {
+			t.Fatalf("Expected python limit to be saved, got %+v", challenge.LanguageLimits)
+		}
+
+		// limits are replaced as a whole on update
+		challenge.LanguageLimits = []*entities.ChallengeLanguageLimit{
+			{Language: "java", MemoryMultiplier: 3},
+		}
+		err = testServiceKit.ChallengeService.UpdateChallengeWithTestcase(challenge)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		challenge, err = testServiceKit.ChallengeService.FindChallengeByID(created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(challenge.LanguageLimits) != 1 || challenge.LanguageLimits[0].Language != "java" {
+			t.Errorf("Expected only the java limit, got %+v", challenge.LanguageLimits)
+		}
+	})
+
+	t.Run("Validate Language Limits", func(t *testing.T) {
+		invalid := [][]*entities.ChallengeLanguageLimit{
+			{{Language: "brainfuck", TimeMultiplier: 2}},
+			{{Language: "python", TimeMultiplier: 2}, {Language: "python", TimeMultiplier: 3}},
+			{{Language: "python", TimeMultiplier: -1}},
+		}
+
+		for _, limits := range invalid {
+			err := testServiceKit.ChallengeService.ValidateLanguageLimits(&entities.Challenge{LanguageLimits: limits})
+			if err == nil {
+				t.Errorf("Expected error for language limits %+v", limits[0])
+			}
+		}
+	})
+}
@@ -0,0 +1,242 @@
+package tests_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wuttinanhi/code-judge-system/controllers"
+	"github.com/wuttinanhi/code-judge-system/databases"
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/services"
+	"github.com/wuttinanhi/code-judge-system/tests"
+)
+
+func TestLanguage(t *testing.T) {
+	db := databases.NewTempSQLiteDatabase()
+	testServiceKit := services.CreateTestServiceKit(db)
+	rateLimitStorage := controllers.GetMemoryStorage()
+	app := controllers.SetupAPI(testServiceKit, rateLimitStorage)
+
+	adminUser, err := testServiceKit.UserService.Register("test-language-admin@example.com", "testpassword", "test-language-admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = testServiceKit.UserService.UpdateRole(adminUser, entities.UserRoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminAccessToken, err := testServiceKit.JWTService.GenerateToken(*adminUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := testServiceKit.UserService.Register("test-language-user@example.com", "testpassword", "test-language-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userAccessToken, err := testServiceKit.JWTService.GenerateToken(*user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(method, path, accessToken string, body interface{}) *http.Response {
+		var requestBody []byte
+		if body != nil {
+			requestBody, _ = json.Marshal(body)
+		}
+
+		request, _ := http.NewRequest(method, path, bytes.NewBuffer(requestBody))
+		request.Header.Set("Content-Type", "application/json")
+		if accessToken != "" {
+			request.Header.Set("Authorization", "Bearer "+accessToken)
+		}
+
+		response, err := app.Test(request, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	enabledLanguages := func() []*entities.Language {
+		response := send(http.MethodGet, "/languages", "", nil)
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status OK, got %v", response.StatusCode)
+		}
+
+		var languages []*entities.Language
+		err := json.Unmarshal(tests.ResponseBodyToBytes(response), &languages)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return languages
+	}
+
+	rubyDTO := entities.LanguageCreateDTO{
+		Language:       "ruby",
+		DisplayName:    "Ruby 3.2",
+		DockerImage:    "docker.io/library/ruby:3.2",
+		SourceFile:     "main.rb",
+		RunCmd:         "ruby /sandbox/main.rb",
+		CompileTimeout: 1000,
+	}
+
+	var ruby entities.Language
+
+	t.Run("/languages", func(t *testing.T) {
+		languages := enabledLanguages()
+		if len(languages) != len(entities.DefaultSandboxInstructions) {
+			t.Errorf("Expected %v built-in languages, got %v", len(entities.DefaultSandboxInstructions), len(languages))
+		}
+	})
+
+	t.Run("/language/create", func(t *testing.T) {
+		response := send(http.MethodPost, "/language/create", userAccessToken, rubyDTO)
+		if response.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected status Forbidden, got %v", response.StatusCode)
+		}
+
+		response = send(http.MethodPost, "/language/create", adminAccessToken, rubyDTO)
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status OK, got %v", response.StatusCode)
+		}
+		err := json.Unmarshal(tests.ResponseBodyToBytes(response), &ruby)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ruby.Enabled || ruby.TimeMultiplier != 1 || ruby.MemoryMultiplier != 1 {
+			t.Errorf("Expected enabled language with multipliers 1, got %+v", ruby)
+		}
+
+		// names are unique
+		response = send(http.MethodPost, "/language/create", adminAccessToken, rubyDTO)
+		if response.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected status BadRequest, got %v", response.StatusCode)
+		}
+
+		instruction, err := testServiceKit.LanguageService.GetInstruction("ruby")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if instruction.SourceFile != "main.rb" || instruction.RunCmd != rubyDTO.RunCmd {
+			t.Errorf("Expected instruction from the created language, got %+v", instruction)
+		}
+	})
+
+	t.Run("/language/update/:id", func(t *testing.T) {
+		dto := rubyDTO
+		dto.DockerImage = "docker.io/library/ruby:3.3"
+
+		response := send(http.MethodPut, fmt.Sprintf("/language/update/%d", ruby.ID), adminAccessToken, dto)
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status OK, got %v", response.StatusCode)
+		}
+
+		instruction, err := testServiceKit.LanguageService.GetInstruction("ruby")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if instruction.DockerImage != dto.DockerImage {
+			t.Errorf("Expected image %v, got %v", dto.DockerImage, instruction.DockerImage)
+		}
+	})
+
+	t.Run("/language/disable/:id", func(t *testing.T) {
+		response := send(http.MethodPut, fmt.Sprintf("/language/disable/%d", ruby.ID), adminAccessToken, nil)
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status OK, got %v", response.StatusCode)
+		}
+
+		for _, language := range enabledLanguages() {
+			if language.Language == "ruby" {
+				t.Error("Expected disabled language to be hidden")
+			}
+		}
+
+		// new programs can not use a disabled language
+		err := testServiceKit.LanguageService.ValidateLanguage("ruby")
+		if err == nil {
+			t.Error("Expected error for disabled language")
+		}
+
+		response = send(http.MethodPost, "/submission/run", userAccessToken, entities.CodeRunCreateDTO{
+			Language: "ruby",
+			Code:     "puts 1",
+		})
+		if response.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected status BadRequest, got %v", response.StatusCode)
+		}
+
+		// existing programs can still be judged
+		_, err = testServiceKit.LanguageService.GetInstruction("ruby")
+		if err != nil {
+			t.Error(err)
+		}
+
+		response = send(http.MethodPut, fmt.Sprintf("/language/enable/%d", ruby.ID), adminAccessToken, nil)
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status OK, got %v", response.StatusCode)
+		}
+		if len(enabledLanguages()) != len(entities.DefaultSandboxInstructions)+1 {
+			t.Error("Expected enabled language to be listed")
+		}
+	})
+
+	t.Run("Run Command Memory Placeholder", func(t *testing.T) {
+		command := entities.JavaInstructionBook.RunCommand(entities.SandboxMemoryMB * 256)
+		if !strings.Contains(command, "-Xmx256m") {
+			t.Errorf("Expected heap size of 256 MB, got %v", command)
+		}
+
+		command = entities.CInstructionBook.RunCommand(entities.SandboxMemoryMB * 256)
+		if command != entities.CInstructionBook.RunCmd {
+			t.Errorf("Expected run command without placeholder to be unchanged, got %v", command)
+		}
+	})
+
+	t.Run("Language File", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "languages.json")
+		err := os.WriteFile(path, []byte(`[{
+			"language": "lua",
+			"display_name": "Lua 5.4",
+			"docker_image": "docker.io/library/lua:5.4",
+			"source_file": "main.lua",
+			"run_cmd": "lua /sandbox/main.lua",
+			"compile_timeout": 1000,
+			"time_multiplier": 2
+		}]`), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		languages, err := entities.LoadLanguageFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = testServiceKit.LanguageService.SeedLanguages(append(services.DefaultLanguages(), languages...))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		instruction, err := testServiceKit.LanguageService.GetInstruction("lua")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if instruction.TimeMultiplier != 2 || instruction.MemoryMultiplier != 1 {
+			t.Errorf("Expected multipliers 2 and 1, got %v and %v", instruction.TimeMultiplier, instruction.MemoryMultiplier)
+		}
+
+		// seeding again keeps changes made through the admin API
+		instruction, err = testServiceKit.LanguageService.GetInstruction("ruby")
+		if err != nil || instruction.DockerImage != "docker.io/library/ruby:3.3" {
+			t.Errorf("Expected updated ruby image to be kept, got %+v %v", instruction, err)
+		}
+	})
+}
@@ -0,0 +1,294 @@
+package tests_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/wuttinanhi/code-judge-system/databases"
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/services"
+	"github.com/wuttinanhi/code-judge-system/tests"
+)
+
+// fakeSum reads two numbers like the code examples and prints their sum.
+func fakeSum(process *tests.FakeProcess) tests.FakeExit {
+	var a, b int
+	fmt.Fscan(process.Stdin, &a, &b)
+	fmt.Fprintln(process.Stdout, a+b)
+	return tests.FakeExit{TimeMs: 12, MemoryUsage: entities.SandboxMemoryMB * 8}
+}
+
+func TestFakeSandbox(t *testing.T) {
+	db := databases.NewTempSQLiteDatabase()
+	fakeDocker := tests.NewFakeDockerService()
+	testServiceKit := services.CreateTestServiceKitWithDocker(db, fakeDocker)
+
+	runPython := func(t *testing.T, code string, program tests.FakeProgram, stdin string) *entities.SandboxRunResult {
+		fakeDocker.HandleCode(code, program)
+
+		sandbox, err := testServiceKit.SandboxService.CreateSandbox(entities.PythonInstructionBook.Language, code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer testServiceKit.SandboxService.CleanUp(sandbox)
+
+		compile := testServiceKit.SandboxService.CompileSandbox(sandbox)
+		if compile.Err != nil {
+			t.Fatal(compile.Err)
+		}
+
+		return testServiceKit.SandboxService.Run(sandbox, stdin, entities.SandboxMemoryMB*128, 1000)
+	}
+
+	t.Run("Run", func(t *testing.T) {
+		result := runPython(t, "# sum", fakeSum, "1\n2\n")
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+
+		if result.Stdout != "3\n" {
+			t.Errorf("expected stdout %q, got %q", "3\n", result.Stdout)
+		}
+		if result.Stderr != "" {
+			t.Errorf("expected empty stderr, got %q", result.Stderr)
+		}
+		if result.ExitCode != 0 {
+			t.Errorf("expected exit code 0, got %d", result.ExitCode)
+		}
+		if result.TimeMs != 12 || result.MemoryUsage != entities.SandboxMemoryMB*8 {
+			t.Errorf("expected usage 12ms 8MB, got %dms %d", result.TimeMs, result.MemoryUsage)
+		}
+	})
+
+	t.Run("Exit Code And Stderr", func(t *testing.T) {
+		result := runPython(t, "# crash", func(process *tests.FakeProcess) tests.FakeExit {
+			fmt.Fprint(process.Stderr, "Traceback\n")
+			return tests.FakeExit{ExitCode: 1}
+		}, "")
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+
+		if result.ExitCode != 1 {
+			t.Errorf("expected exit code 1, got %d", result.ExitCode)
+		}
+		if result.Stderr != "Traceback\n" {
+			t.Errorf("expected stderr %q, got %q", "Traceback\n", result.Stderr)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		result := runPython(t, "# hang", func(process *tests.FakeProcess) tests.FakeExit {
+			return tests.FakeExit{Hang: true}
+		}, "")
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+
+		if !result.Timeout {
+			t.Error("expected timeout")
+		}
+	})
+
+	t.Run("OOM", func(t *testing.T) {
+		result := runPython(t, "# oom", func(process *tests.FakeProcess) tests.FakeExit {
+			return tests.FakeExit{OOMKilled: true}
+		}, "")
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+
+		if !result.OOMKilled {
+			t.Error("expected OOM killed")
+		}
+		if result.ExitCode != 137 {
+			t.Errorf("expected exit code 137, got %d", result.ExitCode)
+		}
+	})
+
+	t.Run("Output Limit", func(t *testing.T) {
+		result := runPython(t, "# spam", func(process *tests.FakeProcess) tests.FakeExit {
+			fmt.Fprint(process.Stdout, strings.Repeat("a", 2*1024*1024))
+			return tests.FakeExit{}
+		}, "")
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+
+		if !result.OutputLimitExceeded {
+			t.Error("expected output limit exceeded")
+		}
+	})
+
+	t.Run("Compile Error", func(t *testing.T) {
+		code := "package main // broken"
+		fakeDocker.HandleCompile(code, func(process *tests.FakeProcess) tests.FakeExit {
+			fmt.Fprint(process.Stderr, "syntax error\n")
+			return tests.FakeExit{ExitCode: 2}
+		})
+
+		sandbox, err := testServiceKit.SandboxService.CreateSandbox(entities.GoInstructionBook.Language, code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer testServiceKit.SandboxService.CleanUp(sandbox)
+
+		compile := testServiceKit.SandboxService.CompileSandbox(sandbox)
+		if compile.Err == nil {
+			t.Fatal("expected compile error")
+		}
+		if sandbox.CompileExitCode != 2 || sandbox.CompileStderr != "syntax error\n" {
+			t.Errorf("expected compile exit code 2 with stderr, got %d %q", sandbox.CompileExitCode, sandbox.CompileStderr)
+		}
+	})
+
+	t.Run("Interactive", func(t *testing.T) {
+		programCode := "# guess"
+		interactorCode := "# interactor"
+
+		// the program echoes every line doubled until the interactor says done
+		fakeDocker.HandleCode(programCode, func(process *tests.FakeProcess) tests.FakeExit {
+			scanner := bufio.NewScanner(process.Stdin)
+			for scanner.Scan() && scanner.Text() != "done" {
+				var n int
+				fmt.Sscan(scanner.Text(), &n)
+				fmt.Fprintln(process.Stdout, n*2)
+			}
+			return tests.FakeExit{}
+		})
+		fakeDocker.Handle(func(process *tests.FakeProcess) bool {
+			return strings.Contains(process.Command, "/stdin/answer") && process.HasFile("/sandbox", interactorCode)
+		}, func(process *tests.FakeProcess) tests.FakeExit {
+			input, _ := process.ReadFile("/stdin/input")
+			reader := bufio.NewReader(process.Stdin)
+			fmt.Fprintln(process.Stdout, input)
+			line, _ := reader.ReadString('\n')
+			fmt.Fprintln(process.Stdout, "done")
+			io.Copy(io.Discard, reader)
+
+			if strings.TrimSpace(line) != "42" {
+				return tests.FakeExit{ExitCode: 1}
+			}
+			return tests.FakeExit{}
+		})
+
+		program, err := testServiceKit.SandboxService.CreateSandbox(entities.PythonInstructionBook.Language, programCode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer testServiceKit.SandboxService.CleanUp(program)
+		if compile := testServiceKit.SandboxService.CompileSandbox(program); compile.Err != nil {
+			t.Fatal(compile.Err)
+		}
+
+		interactor, err := testServiceKit.SandboxService.CreateSandbox(entities.PythonInstructionBook.Language, interactorCode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer testServiceKit.SandboxService.CleanUp(interactor)
+		if compile := testServiceKit.SandboxService.CompileSandbox(interactor); compile.Err != nil {
+			t.Fatal(compile.Err)
+		}
+
+		result, interactorResult := testServiceKit.SandboxService.RunInteractive(program, interactor, "21", "42", entities.SandboxMemoryMB*128, 1000)
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+
+		if result.Stdout != "42\n" {
+			t.Errorf("expected program stdout %q, got %q", "42\n", result.Stdout)
+		}
+		if interactorResult.ExitCode != 0 {
+			t.Errorf("expected interactor to accept, got exit code %d", interactorResult.ExitCode)
+		}
+	})
+
+	t.Run("Clean Up", func(t *testing.T) {
+		if fakeDocker.ContainerCount() != 0 {
+			t.Errorf("expected every container removed, got %d", fakeDocker.ContainerCount())
+		}
+		if fakeDocker.VolumeCount() != 0 {
+			t.Errorf("expected every volume deleted, got %d", fakeDocker.VolumeCount())
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		// a sandbox left behind by an interrupted submission
+		fakeDocker.HandleCode("# interrupted", fakeSum)
+		sandbox, err := testServiceKit.SandboxService.CreateSandbox(entities.PythonInstructionBook.Language, "# interrupted")
+		if err != nil {
+			t.Fatal(err)
+		}
+		compile := testServiceKit.SandboxService.CompileSandbox(sandbox)
+		if compile.Err != nil {
+			t.Fatal(compile.Err)
+		}
+		if fakeDocker.VolumeCount() == 0 {
+			t.Fatal("expected the sandbox volume to be left")
+		}
+
+		err = testServiceKit.SandboxService.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fakeDocker.ContainerCount() != 0 {
+			t.Errorf("expected every container removed, got %d", fakeDocker.ContainerCount())
+		}
+		if fakeDocker.VolumeCount() != 0 {
+			t.Errorf("expected every volume deleted, got %d", fakeDocker.VolumeCount())
+		}
+	})
+}
+
+func TestFakeSandboxPool(t *testing.T) {
+	db := databases.NewTempSQLiteDatabase()
+	testServiceKit := services.CreateTestServiceKit(db)
+	fakeDocker := tests.NewFakeDockerService()
+	sandboxService := services.NewSandboxService(
+		fakeDocker,
+		testServiceKit.LanguageService,
+		1024, 10000, 1024*1024,
+		services.ContainerPoolConfig{Size: 1, MaxUses: 10},
+	)
+
+	codes := []string{"# first", "# second"}
+	for i, code := range codes {
+		output := fmt.Sprint(i)
+		fakeDocker.HandleCode(code, func(process *tests.FakeProcess) tests.FakeExit {
+			fmt.Fprint(process.Stdout, output)
+			return tests.FakeExit{}
+		})
+	}
+
+	// the reused container must not run the program of the previous run
+	for i, code := range codes {
+		sandbox, err := sandboxService.CreateSandbox(entities.PythonInstructionBook.Language, code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compile := sandboxService.CompileSandbox(sandbox); compile.Err != nil {
+			t.Fatal(compile.Err)
+		}
+
+		result := sandboxService.Run(sandbox, "", entities.SandboxMemoryMB*128, 1000)
+		sandboxService.CleanUp(sandbox)
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		if result.Stdout != fmt.Sprint(i) {
+			t.Errorf("run %d: expected stdout %q, got %q", i, fmt.Sprint(i), result.Stdout)
+		}
+	}
+
+	// the warm container is removed on shutdown
+	err := sandboxService.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fakeDocker.ContainerCount() != 0 || fakeDocker.VolumeCount() != 0 {
+		t.Errorf("expected no containers and volumes left, got %d and %d", fakeDocker.ContainerCount(), fakeDocker.VolumeCount())
+	}
+}
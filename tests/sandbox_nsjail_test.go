@@ -0,0 +1,160 @@
+package tests_test
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/wuttinanhi/code-judge-system/databases"
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/repositories"
+	"github.com/wuttinanhi/code-judge-system/services"
+)
+
+func TestNsjailSandbox(t *testing.T) {
+	if _, err := exec.LookPath("nsjail"); err != nil {
+		t.Skip("nsjail not installed")
+	}
+
+	db := databases.NewTempSQLiteDatabase()
+	languageService := services.NewLanguageService(repositories.NewLanguageRepository(db))
+	err := languageService.SeedLanguages(services.DefaultLanguages())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sandboxService := services.NewNsjailSandboxService(languageService, entities.SandboxMemoryMB*256, 10000, 1024*1024, "nsjail", os.TempDir())
+
+	runTests := []struct {
+		name     string
+		language string
+		code     string
+	}{
+		{"Nsjail Python Test", entities.PythonInstructionBook.Language, entities.PythonCodeExample},
+		{"Nsjail C Test", entities.CInstructionBook.Language, entities.CCodeExample},
+	}
+
+	for _, runTest := range runTests {
+		runTest := runTest
+		t.Run(runTest.name, func(t *testing.T) {
+			if runTest.language == entities.CInstructionBook.Language {
+				if _, err := exec.LookPath("gcc"); err != nil {
+					t.Skip("gcc not installed")
+				}
+			}
+
+			sandbox, err := sandboxService.CreateSandbox(runTest.language, runTest.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sandboxService.CleanUp(sandbox)
+
+			compile := sandboxService.CompileSandbox(sandbox)
+			if compile.Err != nil {
+				t.Fatal(compile.Err, compile.Stderr)
+			}
+
+			result := sandboxService.Run(sandbox, "1\n2\n", entities.SandboxMemoryMB*128, 1000)
+			if result.Err != nil {
+				t.Fatal(result.Err)
+			}
+			if result.Stdout != "3\n" {
+				t.Error("stdout not match got\n", result.Stdout)
+			}
+			if result.Stderr != "" {
+				t.Error("stderr not match got\n", result.Stderr)
+			}
+			if result.TimeMs > 1000 {
+				t.Error("expected time within limit got", result.TimeMs)
+			}
+		})
+	}
+
+	t.Run("Nsjail Timeout Python Test", func(t *testing.T) {
+		sandbox, err := sandboxService.CreateSandbox(
+			entities.PythonInstructionBook.Language,
+			entities.PythonCodeTimeoutTestCode,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sandboxService.CleanUp(sandbox)
+		sandbox.TimeMultiplier = 1
+
+		compile := sandboxService.CompileSandbox(sandbox)
+		if compile.Err != nil {
+			t.Fatal(compile.Err)
+		}
+
+		result := sandboxService.Run(sandbox, "", entities.SandboxMemoryMB*128, 1000)
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		if result.Timeout != true {
+			t.Error("timeout not match expected true got", result.Timeout)
+		}
+	})
+
+	t.Run("Nsjail Output Limit Python Test", func(t *testing.T) {
+		sandbox, err := sandboxService.CreateSandbox(
+			entities.PythonInstructionBook.Language,
+			entities.PythonCodeOutputFloodTestCode,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sandboxService.CleanUp(sandbox)
+
+		compile := sandboxService.CompileSandbox(sandbox)
+		if compile.Err != nil {
+			t.Fatal(compile.Err)
+		}
+
+		result := sandboxService.Run(sandbox, "", entities.SandboxMemoryMB*128, 5000)
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		if result.OutputLimitExceeded != true {
+			t.Error("output limit exceeded not match expected true got", result.OutputLimitExceeded)
+		}
+		if result.Timeout {
+			t.Error("expected the program to be stopped before the time limit")
+		}
+	})
+
+	oomTests := []struct {
+		name      string
+		code      string
+		oomKilled bool
+	}{
+		{"Nsjail OOM Python Test", entities.PythonCodeOOMTestCode, true},
+		{"Nsjail Self Kill Python Test", entities.PythonCodeSelfKillTestCode, false},
+	}
+
+	for _, oomTest := range oomTests {
+		oomTest := oomTest
+		t.Run(oomTest.name, func(t *testing.T) {
+			sandbox, err := sandboxService.CreateSandbox(entities.PythonInstructionBook.Language, oomTest.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sandboxService.CleanUp(sandbox)
+
+			compile := sandboxService.CompileSandbox(sandbox)
+			if compile.Err != nil {
+				t.Fatal(compile.Err)
+			}
+
+			result := sandboxService.Run(sandbox, "", entities.SandboxMemoryMB*128, 5000)
+			if result.Err != nil {
+				t.Fatal(result.Err)
+			}
+			if result.OOMKilled != oomTest.oomKilled {
+				t.Error("oom killed not match expected", oomTest.oomKilled, "got", result.OOMKilled)
+			}
+			if result.ExitCode != 137 {
+				t.Error("exit code not match expected 137 got", result.ExitCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package tests_test
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/wuttinanhi/code-judge-system/entities"
+)
+
+func TestTruncateOutput(t *testing.T) {
+	t.Run("Short Output", func(t *testing.T) {
+		output := entities.TruncateOutput("hello", 5)
+		if output != "hello" {
+			t.Errorf("expected output to be unchanged, got %q", output)
+		}
+	})
+
+	t.Run("Long Output", func(t *testing.T) {
+		output := entities.TruncateOutput("hello world", 5)
+		if !strings.HasPrefix(output, "hello\n") || !strings.Contains(output, "truncated") {
+			t.Errorf("expected output to be truncated after 5 bytes, got %q", output)
+		}
+	})
+
+	t.Run("Multibyte Output", func(t *testing.T) {
+		// each character is 3 bytes, so the limit splits the second one
+		output := entities.TruncateOutput("สวัสดี", 4)
+		if !utf8.ValidString(output) {
+			t.Errorf("expected valid UTF-8, got %q", output)
+		}
+		if !strings.HasPrefix(output, "ส\n") {
+			t.Errorf("expected only the whole first character to be kept, got %q", output)
+		}
+	})
+}
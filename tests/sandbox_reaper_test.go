@@ -0,0 +1,138 @@
+package tests_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/wuttinanhi/code-judge-system/databases"
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/services"
+	"github.com/wuttinanhi/code-judge-system/tests"
+)
+
+func TestSandboxReaper(t *testing.T) {
+	db := databases.NewTempSQLiteDatabase()
+	fakeDocker := tests.NewFakeDockerService()
+	testServiceKit := services.CreateTestServiceKitWithDocker(db, fakeDocker)
+
+	t.Run("Labels", func(t *testing.T) {
+		fakeDocker.HandleCode("# labeled", fakeSum)
+		sandbox, err := testServiceKit.SandboxService.CreateSandbox(entities.PythonInstructionBook.Language, "# labeled")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sandbox.SubmissionID = 7
+		defer testServiceKit.SandboxService.CleanUp(sandbox)
+
+		compile := testServiceKit.SandboxService.CompileSandbox(sandbox)
+		if compile.Err != nil {
+			t.Fatal(compile.Err)
+		}
+
+		volumes, err := fakeDocker.ListVolumes(entities.SandboxLabelCreatedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(volumes) != 1 {
+			t.Fatalf("expected the program volume to be labeled, got %d volumes", len(volumes))
+		}
+		labels := volumes[0].Labels
+		if labels[entities.SandboxLabelRunID] != sandbox.RunID || labels[entities.SandboxLabelSubmissionID] != "7" {
+			t.Errorf("unexpected labels %v", labels)
+		}
+		createdAt, err := time.Parse(time.RFC3339, labels[entities.SandboxLabelCreatedAt])
+		if err != nil || time.Since(createdAt) > time.Minute {
+			t.Errorf("unexpected creation time %q", labels[entities.SandboxLabelCreatedAt])
+		}
+	})
+
+	t.Run("Reap", func(t *testing.T) {
+		// a run of a dead judge, the container still uses the volume
+		oldLabels := entities.SandboxLabels("old-run", 3, time.Now().Add(-2*time.Hour))
+		oldVolume, err := fakeDocker.CreateVolume("code-judge-system-old-run-program", oldLabels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = fakeDocker.CreateContainer(services.ContainerConfig{
+			Name:   "old-run-run-1",
+			Mounts: []mount.Mount{{Type: mount.TypeVolume, Source: oldVolume.Name, Target: "/sandbox"}},
+			Labels: oldLabels,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// a run in progress and a volume that is not a sandbox
+		_, err = fakeDocker.CreateVolume("code-judge-system-new-run-program", entities.SandboxLabels("new-run", 4, time.Now()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = fakeDocker.CreateVolume("other", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		containers, volumes, err := testServiceKit.SandboxReaper.Reap(time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if containers != 1 || volumes != 1 {
+			t.Errorf("expected 1 container and 1 volume reaped, got %d and %d", containers, volumes)
+		}
+		if fakeDocker.ContainerCount() != 0 {
+			t.Errorf("expected the old container removed, got %d containers", fakeDocker.ContainerCount())
+		}
+
+		left, err := fakeDocker.ListVolumes(entities.SandboxLabelCreatedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(left) != 1 || left[0].Labels[entities.SandboxLabelSubmissionID] != "4" {
+			t.Errorf("expected only the new volume left, got %v", left)
+		}
+		if fakeDocker.VolumeCount() != 2 {
+			t.Errorf("expected the new and the other volume left, got %d", fakeDocker.VolumeCount())
+		}
+	})
+
+	t.Run("Keep Pooled And In Use", func(t *testing.T) {
+		// a pooled container of another consumer lives as long as its pool
+		poolLabels := entities.SandboxLabels("", 0, time.Now().Add(-2*time.Hour))
+		poolLabels[entities.SandboxLabelPool] = "true"
+		_, err := fakeDocker.CreateContainer(services.ContainerConfig{Name: "pool", Labels: poolLabels})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// a sandbox of this process that is still in use
+		sandbox, err := testServiceKit.SandboxService.CreateSandbox(entities.PythonInstructionBook.Language, "# in use")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer testServiceKit.SandboxService.CleanUp(sandbox)
+		if compile := testServiceKit.SandboxService.CompileSandbox(sandbox); compile.Err != nil {
+			t.Fatal(compile.Err)
+		}
+
+		containers, volumes, err := testServiceKit.SandboxReaper.Reap(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if containers != 0 {
+			t.Errorf("expected no container reaped, got %d", containers)
+		}
+
+		inUse, err := fakeDocker.ListVolumes(entities.SandboxLabelRunID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, v := range inUse {
+			found = found || v.Labels[entities.SandboxLabelRunID] == sandbox.RunID
+		}
+		if !found {
+			t.Errorf("expected the volume in use kept, %d volumes reaped", volumes)
+		}
+	})
+}
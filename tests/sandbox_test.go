@@ -1,10 +1,13 @@
 package tests_test
 
 import (
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/wuttinanhi/code-judge-system/databases"
 	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/repositories"
 	"github.com/wuttinanhi/code-judge-system/services"
 )
 
@@ -66,6 +69,12 @@ func TestSandbox(t *testing.T) {
 		if result.Stderr != "" {
 			t.Error("stderr not match")
 		}
+		if result.TimeMs > 1000 {
+			t.Error("expected time within limit got", result.TimeMs)
+		}
+		if result.MemoryUsage == 0 || result.MemoryUsage > entities.SandboxMemoryMB*128 {
+			t.Error("expected memory usage within limit got", result.MemoryUsage)
+		}
 	})
 
 	t.Run("Sandbox C Test", func(t *testing.T) {
@@ -96,6 +105,136 @@ func TestSandbox(t *testing.T) {
 		}
 	})
 
+	languageTests := []struct {
+		name     string
+		language string
+		code     string
+	}{
+		{"Sandbox C++ Test", entities.CppInstructionBook.Language, entities.CppCodeExample},
+		{"Sandbox Java Test", entities.JavaInstructionBook.Language, entities.JavaCodeExample},
+		{"Sandbox JavaScript Test", entities.JavaScriptInstructionBook.Language, entities.JavaScriptCodeExample},
+		{"Sandbox Rust Test", entities.RustInstructionBook.Language, entities.RustCodeExample},
+		{"Sandbox Kotlin Test", entities.KotlinInstructionBook.Language, entities.KotlinCodeExample},
+	}
+
+	for _, languageTest := range languageTests {
+		languageTest := languageTest
+
+		t.Run(languageTest.name, func(t *testing.T) {
+			sandbox, err := testServiceKit.SandboxService.CreateSandbox(languageTest.language, languageTest.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer testServiceKit.SandboxService.CleanUp(sandbox)
+
+			compile := testServiceKit.SandboxService.CompileSandbox(sandbox)
+			if compile.Err != nil {
+				t.Fatal(compile.Err, sandbox.CompileStdout, sandbox.CompileStderr)
+			}
+
+			result := testServiceKit.SandboxService.Run(sandbox, "1\n2\n", entities.SandboxMemoryMB*128, 2000)
+			if result.Err != nil {
+				t.Fatal(result.Err)
+			}
+
+			if result.Stdout != "3\n" {
+				t.Error("stdout not match got\n", result.Stdout, result.Stderr)
+			}
+			if result.ExitCode != 0 {
+				t.Error("exit code not match expected 0 got", result.ExitCode)
+			}
+		})
+	}
+
+	t.Run("Sandbox C Compile Error Test", func(t *testing.T) {
+		sandbox, err := testServiceKit.SandboxService.CreateSandbox(
+			entities.CInstructionBook.Language,
+			entities.CCodeCompileErrorExample,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer testServiceKit.SandboxService.CleanUp(sandbox)
+
+		compile := testServiceKit.SandboxService.CompileSandbox(sandbox)
+		if compile.Err == nil {
+			t.Fatal("compile error expected")
+		}
+
+		if compile.ExitCode == 0 {
+			t.Error("compile exit code must not be 0")
+		}
+		if sandbox.CompileStderr == "" && sandbox.CompileStdout == "" {
+			t.Error("compiler output must not be empty")
+		}
+	})
+
+	t.Run("Sandbox Custom Checker Test", func(t *testing.T) {
+		checker, err := services.NewCustomChecker(
+			testServiceKit.SandboxService,
+			entities.PythonInstructionBook.Language,
+			entities.PythonCheckerExample,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer checker.CleanUp()
+
+		status, note := checker.Check("", "1 2 3\n", "3 3\n")
+		if status != entities.SubmissionStatusCorrect {
+			t.Error("checker status not match expected CORRECT got", status, note)
+		}
+
+		status, note = checker.Check("", "1 2 3\n", "5\n")
+		if status != entities.SubmissionStatusWrong {
+			t.Error("checker status not match expected WRONG got", status, note)
+		}
+	})
+
+	t.Run("Sandbox Interactive Python Test", func(t *testing.T) {
+		sandbox, err := testServiceKit.SandboxService.CreateSandbox(
+			entities.PythonInstructionBook.Language,
+			entities.PythonInteractiveCodeExample,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer testServiceKit.SandboxService.CleanUp(sandbox)
+
+		compile := testServiceKit.SandboxService.CompileSandbox(sandbox)
+		if compile.Err != nil {
+			t.Fatal(compile.Err)
+		}
+
+		interactor, err := testServiceKit.SandboxService.CreateSandbox(
+			entities.PythonInstructionBook.Language,
+			entities.PythonInteractorExample,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer testServiceKit.SandboxService.CleanUp(interactor)
+
+		compile = testServiceKit.SandboxService.CompileSandbox(interactor)
+		if compile.Err != nil {
+			t.Fatal(compile.Err)
+		}
+
+		result, interactorResult := testServiceKit.SandboxService.RunInteractive(
+			sandbox, interactor, "777\n", "", entities.SandboxMemoryMB*128, 2000,
+		)
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+
+		if result.ExitCode != 0 {
+			t.Error("program exit code not match expected 0 got", result.ExitCode, result.Stderr)
+		}
+		if interactorResult.ExitCode != 0 {
+			t.Error("interactor exit code not match expected 0 got", interactorResult.ExitCode, interactorResult.Stderr)
+		}
+	})
+
 	t.Run("Sandbox OOM Python Test", func(t *testing.T) {
 		sandbox, err := testServiceKit.SandboxService.CreateSandbox(
 			entities.PythonInstructionBook.Language,
@@ -120,6 +259,10 @@ func TestSandbox(t *testing.T) {
 		if result.ExitCode != 137 {
 			t.Error("OOM exit code not match, got", result.ExitCode)
 		}
+		// container must be killed by OOM killer
+		if result.OOMKilled != true {
+			t.Error("OOM killed not match expected true got", result.OOMKilled)
+		}
 	})
 
 	t.Run("Sandbox Timeout Python Test", func(t *testing.T) {
@@ -132,6 +275,9 @@ func TestSandbox(t *testing.T) {
 		}
 		defer testServiceKit.SandboxService.CleanUp(sandbox)
 
+		// judge the time limit as given
+		sandbox.TimeMultiplier = 1
+
 		compile := testServiceKit.SandboxService.CompileSandbox(sandbox)
 		if compile.Err != nil {
 			t.Fatal(compile.Err)
@@ -151,4 +297,275 @@ func TestSandbox(t *testing.T) {
 			t.Error("timeout not match expected true got", result.Timeout)
 		}
 	})
+
+	t.Run("Sandbox Time Multiplier Python Test", func(t *testing.T) {
+		sandbox, err := testServiceKit.SandboxService.CreateSandbox(
+			entities.PythonInstructionBook.Language,
+			entities.PythonCodeTimeoutTestCode,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer testServiceKit.SandboxService.CleanUp(sandbox)
+
+		compile := testServiceKit.SandboxService.CompileSandbox(sandbox)
+		if compile.Err != nil {
+			t.Fatal(compile.Err)
+		}
+
+		// the program sleeps 2 seconds, within the 1 second limit times 3
+		sandbox.TimeMultiplier = 3
+
+		result := testServiceKit.SandboxService.Run(sandbox, "", entities.SandboxMemoryMB*128, 1000)
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		if result.Timeout {
+			t.Error("timeout not match expected false got", result.Timeout)
+		}
+		if result.ExitCode != 0 {
+			t.Error("exit code not match expected 0 got", result.ExitCode)
+		}
+	})
+
+	t.Run("Sandbox Stderr Python Test", func(t *testing.T) {
+		sandbox, err := testServiceKit.SandboxService.CreateSandbox(
+			entities.PythonInstructionBook.Language,
+			entities.PythonCodeStderrExample,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer testServiceKit.SandboxService.CleanUp(sandbox)
+
+		compile := testServiceKit.SandboxService.CompileSandbox(sandbox)
+		if compile.Err != nil {
+			t.Fatal(compile.Err)
+		}
+
+		result := testServiceKit.SandboxService.Run(sandbox, "1\n2\n", entities.SandboxMemoryMB*128, 1000)
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+
+		// stdout must hold only the answer, with its line ending untouched
+		if result.Stdout != "3\n" {
+			t.Errorf("stdout not match expected %q got %q", "3\n", result.Stdout)
+		}
+		// stderr must hold the debug output without the usage line
+		if result.Stderr != "x = 1 y = 2\n" {
+			t.Errorf("stderr not match expected %q got %q", "x = 1 y = 2\n", result.Stderr)
+		}
+	})
+
+	t.Run("Sandbox Output Limit Python Test", func(t *testing.T) {
+		sandbox, err := testServiceKit.SandboxService.CreateSandbox(
+			entities.PythonInstructionBook.Language,
+			entities.PythonCodeOutputFloodTestCode,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer testServiceKit.SandboxService.CleanUp(sandbox)
+
+		compile := testServiceKit.SandboxService.CompileSandbox(sandbox)
+		if compile.Err != nil {
+			t.Fatal(compile.Err)
+		}
+
+		result := testServiceKit.SandboxService.Run(sandbox, "", entities.SandboxMemoryMB*128, 5000)
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+
+		// the program must be stopped for its output before the time limit
+		if !result.OutputLimitExceeded {
+			t.Error("output limit exceeded not match expected true got", result.OutputLimitExceeded)
+		}
+		if result.Timeout {
+			t.Error("timeout not match expected false got", result.Timeout)
+		}
+		if len(result.Stdout)+len(result.Stderr) > 1024*1024 {
+			t.Error("captured output not limited, got", len(result.Stdout)+len(result.Stderr), "bytes")
+		}
+	})
+
+	t.Run("Sandbox Fork Bomb Python Test", func(t *testing.T) {
+		sandbox, err := testServiceKit.SandboxService.CreateSandbox(
+			entities.PythonInstructionBook.Language,
+			entities.PythonCodeForkBombTestCode,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer testServiceKit.SandboxService.CleanUp(sandbox)
+
+		compile := testServiceKit.SandboxService.CompileSandbox(sandbox)
+		if compile.Err != nil {
+			t.Fatal(compile.Err)
+		}
+
+		result := testServiceKit.SandboxService.Run(sandbox, "", entities.SandboxMemoryMB*128, 3000)
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		if result.Timeout {
+			t.Fatal("fork bomb was not stopped by the pids limit")
+		}
+
+		// the program prints how many children it created before fork failed
+		children, err := strconv.Atoi(strings.TrimSpace(result.Stdout))
+		if err != nil {
+			t.Fatal("unexpected output", result.Stdout, result.Stderr)
+		}
+		if int64(children) >= entities.SandboxRunLimits.PidsLimit {
+			t.Error("children not limited, expected less than", entities.SandboxRunLimits.PidsLimit, "got", children)
+		}
+	})
+
+	t.Run("Sandbox Disk Filler Python Test", func(t *testing.T) {
+		sandbox, err := testServiceKit.SandboxService.CreateSandbox(
+			entities.PythonInstructionBook.Language,
+			entities.PythonCodeDiskFillerTestCode,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer testServiceKit.SandboxService.CleanUp(sandbox)
+
+		compile := testServiceKit.SandboxService.CompileSandbox(sandbox)
+		if compile.Err != nil {
+			t.Fatal(compile.Err)
+		}
+
+		result := testServiceKit.SandboxService.Run(sandbox, "", entities.SandboxMemoryMB*256, 5000)
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+
+		// the program prints the megabytes written to /tmp, /sandbox and the root filesystem
+		written := strings.Fields(result.Stdout)
+		if len(written) != 3 {
+			t.Fatal("unexpected output", result.Stdout, result.Stderr)
+		}
+
+		tmpMB, _ := strconv.Atoi(written[0])
+		if int64(tmpMB) > entities.SandboxRunLimits.FileSizeLimit/int64(entities.SandboxMemoryMB) {
+			t.Error("/tmp not limited, wrote", tmpMB, "MB")
+		}
+		if written[1] != "0" {
+			t.Error("/sandbox must be read-only, wrote", written[1], "MB")
+		}
+		if written[2] != "0" {
+			t.Error("root filesystem must be read-only, wrote", written[2], "MB")
+		}
+	})
+}
+
+func TestSandboxPool(t *testing.T) {
+	db := databases.NewTempSQLiteDatabase()
+	languageService := services.NewLanguageService(repositories.NewLanguageRepository(db))
+	err := languageService.SeedLanguages(services.DefaultLanguages())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sandboxService := services.NewSandboxService(services.NewDockerservice(), languageService, entities.SandboxMemoryMB*256, 10000, 1024*1024, services.ContainerPoolConfig{
+		Size:    1,
+		MaxUses: 2,
+	})
+
+	runTests := []struct {
+		name     string
+		language string
+		code     string
+	}{
+		{"Sandbox Pool Go Test", entities.GoInstructionBook.Language, entities.GoCodeExample},
+		{"Sandbox Pool Python Test", entities.PythonInstructionBook.Language, entities.PythonCodeExample},
+	}
+
+	for _, runTest := range runTests {
+		runTest := runTest
+		t.Run(runTest.name, func(t *testing.T) {
+			sandbox, err := sandboxService.CreateSandbox(runTest.language, runTest.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sandboxService.CleanUp(sandbox)
+
+			compile := sandboxService.CompileSandbox(sandbox)
+			if compile.Err != nil {
+				t.Fatal(compile.Err)
+			}
+			if sandbox.ProgramArchive == nil {
+				t.Fatal("expected program archive")
+			}
+
+			// the second run may reuse the container of the first
+			for i := 0; i < 2; i++ {
+				result := sandboxService.Run(sandbox, "1\n2\n", entities.SandboxMemoryMB*128, 1000)
+				if result.Err != nil {
+					t.Fatal(result.Err)
+				}
+				if result.Stdout != "3\n" {
+					t.Error("stdout not match got\n", result.Stdout)
+				}
+				if result.Stderr != "" {
+					t.Error("stderr not match got\n", result.Stderr)
+				}
+			}
+		})
+	}
+
+	t.Run("Sandbox Pool OOM Python Test", func(t *testing.T) {
+		sandbox, err := sandboxService.CreateSandbox(
+			entities.PythonInstructionBook.Language,
+			entities.PythonCodeOOMTestCode,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sandboxService.CleanUp(sandbox)
+
+		compile := sandboxService.CompileSandbox(sandbox)
+		if compile.Err != nil {
+			t.Fatal(compile.Err)
+		}
+
+		result := sandboxService.Run(sandbox, "", entities.SandboxMemoryMB*128, 1000)
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		if result.ExitCode != 137 {
+			t.Error("OOM exit code not match, got", result.ExitCode)
+		}
+		if result.OOMKilled != true {
+			t.Error("OOM killed not match expected true got", result.OOMKilled)
+		}
+	})
+
+	t.Run("Sandbox Pool Timeout Python Test", func(t *testing.T) {
+		sandbox, err := sandboxService.CreateSandbox(
+			entities.PythonInstructionBook.Language,
+			entities.PythonCodeTimeoutTestCode,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sandboxService.CleanUp(sandbox)
+		sandbox.TimeMultiplier = 1
+
+		compile := sandboxService.CompileSandbox(sandbox)
+		if compile.Err != nil {
+			t.Fatal(compile.Err)
+		}
+
+		result := sandboxService.Run(sandbox, "", entities.SandboxMemoryMB*128, 1000)
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		if result.Timeout != true {
+			t.Error("timeout not match expected true got", result.Timeout)
+		}
+	})
 }
@@ -0,0 +1,70 @@
+package tests_test
+
+import (
+	"testing"
+
+	"github.com/wuttinanhi/code-judge-system/entities"
+)
+
+func TestParseSandboxUsage(t *testing.T) {
+	t.Run("Usage After Output", func(t *testing.T) {
+		output := "err\n\n" + entities.SandboxUsageMarker + " 12345 67108864\n"
+
+		rest, usage, ok := entities.ParseSandboxUsage(output)
+		if !ok {
+			t.Fatal("expected usage to be found")
+		}
+		if rest != "err\n" {
+			t.Errorf("expected output to be restored, got %q", rest)
+		}
+		if usage.TimeMs != 12 {
+			t.Errorf("expected 12 ms, got %v", usage.TimeMs)
+		}
+		if usage.MemoryUsage != 67108864 {
+			t.Errorf("expected 67108864 bytes, got %v", usage.MemoryUsage)
+		}
+		if usage.OOMKills != 0 {
+			t.Errorf("expected no OOM kills without the count, got %v", usage.OOMKills)
+		}
+	})
+
+	t.Run("OOM Kills", func(t *testing.T) {
+		output := "\n" + entities.SandboxUsageMarker + " 1000 1 2\n"
+
+		rest, usage, ok := entities.ParseSandboxUsage(output)
+		if !ok || rest != "" {
+			t.Fatalf("expected usage to be removed, got %q", rest)
+		}
+		if usage.OOMKills != 2 {
+			t.Errorf("expected 2 OOM kills, got %v", usage.OOMKills)
+		}
+	})
+
+	t.Run("Usage Without Trailing Newline In Output", func(t *testing.T) {
+		output := "3\n" + entities.SandboxUsageMarker + " 1000 1\n"
+
+		rest, _, ok := entities.ParseSandboxUsage(output)
+		if !ok || rest != "3" {
+			t.Errorf("expected output %q, got %q", "3", rest)
+		}
+	})
+
+	t.Run("Forged Usage Is Ignored", func(t *testing.T) {
+		output := "\n" + entities.SandboxUsageMarker + " 1 1\n\n" + entities.SandboxUsageMarker + " 5000 2\n"
+
+		rest, usage, ok := entities.ParseSandboxUsage(output)
+		if !ok || usage.TimeMs != 5 {
+			t.Errorf("expected the last usage line to be used, got %v ms", usage.TimeMs)
+		}
+		if rest != "\n"+entities.SandboxUsageMarker+" 1 1\n" {
+			t.Errorf("expected only the last usage line to be removed, got %q", rest)
+		}
+	})
+
+	t.Run("No Usage", func(t *testing.T) {
+		rest, _, ok := entities.ParseSandboxUsage("3\n")
+		if ok || rest != "3\n" {
+			t.Errorf("expected output to be unchanged, got %q", rest)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package tests_test
+
+import (
+	"testing"
+
+	"github.com/wuttinanhi/code-judge-system/databases"
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/services"
+)
+
+func TestScoreSubmission(t *testing.T) {
+	challenge := &entities.Challenge{
+		Testcases: []*entities.ChallengeTestcase{
+			{ID: 1, Subtask: 1},
+			{ID: 2, Subtask: 1},
+			{ID: 3, Subtask: 2},
+			{ID: 4, Subtask: 2},
+			{ID: 5, Subtask: 2},
+			{ID: 6, Subtask: 2},
+			{ID: 7},
+		},
+		Subtasks: []*entities.ChallengeSubtask{
+			{Number: 1, Points: 30, ScoringRule: entities.ChallengeSubtaskScoringAll},
+			{Number: 2, Points: 70, ScoringRule: entities.ChallengeSubtaskScoringProportional},
+		},
+	}
+
+	newSubmission := func(statuses ...string) *entities.Submission {
+		submission := &entities.Submission{}
+		for i, status := range statuses {
+			submission.SubmissionTestcases = append(submission.SubmissionTestcases, &entities.SubmissionTestcase{
+				ChallengeTestcaseID: uint(i + 1),
+				Status:              status,
+			})
+		}
+		return submission
+	}
+
+	correct := entities.SubmissionStatusCorrect
+	wrong := entities.SubmissionStatusWrong
+	tle := entities.SubmissionStatusTimeLimitExceeded
+
+	t.Run("All Correct", func(t *testing.T) {
+		score, results := services.ScoreSubmission(challenge, newSubmission(correct, correct, correct, correct, correct, correct, correct))
+		if score != 100 {
+			t.Errorf("Expected score 100, got %v", score)
+		}
+		if len(results) != 2 {
+			t.Fatalf("Expected 2 subtask results, got %v", len(results))
+		}
+	})
+
+	t.Run("Partial Score", func(t *testing.T) {
+		score, results := services.ScoreSubmission(challenge, newSubmission(correct, wrong, correct, tle, correct, correct, wrong))
+		if score != 52.5 {
+			t.Errorf("Expected score 52.5, got %v", score)
+		}
+		if results[0].Score != 0 || results[0].Status != wrong {
+			t.Errorf("Expected subtask 1 to score 0 with WRONG, got %v with %v", results[0].Score, results[0].Status)
+		}
+		if results[1].Passed != 3 || results[1].Total != 4 || results[1].Status != tle {
+			t.Errorf("Expected subtask 2 to pass 3/4 with TLE, got %v/%v with %v", results[1].Passed, results[1].Total, results[1].Status)
+		}
+	})
+
+	t.Run("No Subtasks", func(t *testing.T) {
+		plain := &entities.Challenge{}
+
+		score, results := services.ScoreSubmission(plain, newSubmission(correct, correct))
+		if score != entities.ChallengeDefaultMaxScore || results != nil {
+			t.Errorf("Expected default score without subtask results, got %v %v", score, results)
+		}
+
+		score, _ = services.ScoreSubmission(plain, newSubmission(correct, wrong))
+		if score != 0 {
+			t.Errorf("Expected score 0, got %v", score)
+		}
+	})
+
+	t.Run("Validate Subtasks", func(t *testing.T) {
+		db := databases.NewTempSQLiteDatabase()
+		testServiceKit := services.CreateTestServiceKit(db)
+
+		created, err := testServiceKit.ChallengeService.CreateChallenge(&entities.Challenge{
+			Name: "Subtask Challenge",
+			Testcases: []*entities.ChallengeTestcase{
+				{Input: "1", ExpectedOutput: "1", LimitMemory: 1, LimitTimeMs: 1, Subtask: 1},
+			},
+			Subtasks: []*entities.ChallengeSubtask{
+				{Number: 1, Points: 40},
+				{Number: 2, Points: 60},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		challenge, err := testServiceKit.ChallengeService.FindChallengeByID(created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(challenge.Subtasks) != 2 || challenge.MaxScore() != 100 {
+			t.Errorf("Expected 2 subtasks worth 100, got %v worth %v", len(challenge.Subtasks), challenge.MaxScore())
+		}
+
+		_, err = testServiceKit.ChallengeService.CreateChallenge(&entities.Challenge{
+			Name: "Invalid Subtask Challenge",
+			Testcases: []*entities.ChallengeTestcase{
+				{Input: "1", ExpectedOutput: "1", LimitMemory: 1, LimitTimeMs: 1, Subtask: 3},
+			},
+			Subtasks: []*entities.ChallengeSubtask{
+				{Number: 1, Points: 100},
+			},
+		})
+		if err == nil {
+			t.Error("Expected error for testcase in missing subtask")
+		}
+	})
+}
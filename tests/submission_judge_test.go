@@ -0,0 +1,358 @@
+package tests_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/wuttinanhi/code-judge-system/databases"
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/repositories"
+	"github.com/wuttinanhi/code-judge-system/services"
+	"github.com/wuttinanhi/code-judge-system/tests"
+)
+
+func TestSubmissionJudge(t *testing.T) {
+	db := databases.NewTempSQLiteDatabase()
+	fakeDocker := tests.NewFakeDockerService()
+	testServiceKit := services.CreateTestServiceKitWithDocker(db, fakeDocker)
+
+	user, err := testServiceKit.UserService.Register("test-judge@example.com", "testpassword", "test-judge")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenge, err := testServiceKit.ChallengeService.CreateChallenge(&entities.Challenge{
+		Name:        "Test Judge Challenge",
+		Description: "Test Description",
+		Testcases: []*entities.ChallengeTestcase{
+			{Input: "1\n2\n", ExpectedOutput: "3\n", LimitMemory: entities.SandboxMemoryMB * 128, LimitTimeMs: 1000},
+			{Input: "20\n22\n", ExpectedOutput: "42\n", LimitMemory: entities.SandboxMemoryMB * 128, LimitTimeMs: 1000},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	judge := func(t *testing.T, language, code string) *entities.Submission {
+		submission, err := testServiceKit.SubmissionService.SubmitSubmission(&entities.Submission{
+			ChallengeID: challenge.ID,
+			UserID:      user.ID,
+			Language:    language,
+			Code:        code,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		submission, err = testServiceKit.SubmissionService.ProcessSubmission(context.Background(), submission)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if submission.State != entities.SubmissionStateJudged {
+			t.Errorf("expected state %s, got %s", entities.SubmissionStateJudged, submission.State)
+		}
+		return submission
+	}
+
+	judgeTests := []struct {
+		name    string
+		program tests.FakeProgram
+		status  string
+	}{
+		{"Accepted", fakeSum, entities.SubmissionStatusCorrect},
+		{"Wrong Answer", func(process *tests.FakeProcess) tests.FakeExit {
+			fmt.Fprintln(process.Stdout, 0)
+			return tests.FakeExit{}
+		}, entities.SubmissionStatusWrong},
+		{"Time Limit Exceeded", func(process *tests.FakeProcess) tests.FakeExit {
+			return tests.FakeExit{Hang: true}
+		}, entities.SubmissionStatusTimeLimitExceeded},
+		{"Memory Limit Exceeded", func(process *tests.FakeProcess) tests.FakeExit {
+			return tests.FakeExit{OOMKilled: true}
+		}, entities.SubmissionStatusMemoryLimitExceeded},
+		{"Runtime Error", func(process *tests.FakeProcess) tests.FakeExit {
+			return tests.FakeExit{ExitCode: 1}
+		}, entities.SubmissionStatusRuntimeError},
+	}
+
+	for i, judgeTest := range judgeTests {
+		t.Run(judgeTest.name, func(t *testing.T) {
+			code := fmt.Sprintf("# judge %d", i)
+			fakeDocker.HandleCode(code, judgeTest.program)
+
+			submission := judge(t, entities.PythonInstructionBook.Language, code)
+			if submission.Status != judgeTest.status {
+				t.Errorf("expected status %s, got %s", judgeTest.status, submission.Status)
+			}
+			for _, testcase := range submission.SubmissionTestcases {
+				if testcase.Status != judgeTest.status {
+					t.Errorf("expected testcase status %s, got %s", judgeTest.status, testcase.Status)
+				}
+			}
+		})
+	}
+
+	t.Run("Compilation Error", func(t *testing.T) {
+		code := "package main // broken"
+		fakeDocker.HandleCompile(code, func(process *tests.FakeProcess) tests.FakeExit {
+			fmt.Fprint(process.Stderr, "syntax error\n")
+			return tests.FakeExit{ExitCode: 2}
+		})
+
+		submission := judge(t, entities.GoInstructionBook.Language, code)
+		if submission.Status != entities.SubmissionStatusCompilationError {
+			t.Errorf("expected status %s, got %s", entities.SubmissionStatusCompilationError, submission.Status)
+		}
+		if submission.CompileStderr != "syntax error\n" {
+			t.Errorf("expected compile stderr %q, got %q", "syntax error\n", submission.CompileStderr)
+		}
+	})
+
+	t.Run("Compilation Output Limit Exceeded", func(t *testing.T) {
+		code := "package main // noisy"
+		fakeDocker.HandleCompile(code, func(process *tests.FakeProcess) tests.FakeExit {
+			fmt.Fprint(process.Stderr, strings.Repeat("warning\n", 256*1024))
+			return tests.FakeExit{}
+		})
+
+		submission := judge(t, entities.GoInstructionBook.Language, code)
+		if submission.Status != entities.SubmissionStatusCompilationError {
+			t.Errorf("expected status %s, got %s", entities.SubmissionStatusCompilationError, submission.Status)
+		}
+	})
+}
+
+func TestSubmissionJudgeInteractive(t *testing.T) {
+	db := databases.NewTempSQLiteDatabase()
+	fakeDocker := tests.NewFakeDockerService()
+	testServiceKit := services.CreateTestServiceKitWithDocker(db, fakeDocker)
+
+	user, err := testServiceKit.UserService.Register("test-judge-interactive@example.com", "testpassword", "test-judge-interactive")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the interactor sends the input and accepts when the program answers with its double
+	interactorCode := "# judge interactor"
+	fakeDocker.Handle(func(process *tests.FakeProcess) bool {
+		return strings.Contains(process.Command, "/stdin/answer") && process.HasFile("/sandbox", interactorCode)
+	}, func(process *tests.FakeProcess) tests.FakeExit {
+		input, _ := process.ReadFile("/stdin/input")
+		reader := bufio.NewReader(process.Stdin)
+		fmt.Fprintln(process.Stdout, input)
+		line, _ := reader.ReadString('\n')
+		fmt.Fprintln(process.Stdout, "done")
+		io.Copy(io.Discard, reader)
+
+		if strings.TrimSpace(line) != "42" {
+			return tests.FakeExit{ExitCode: 1}
+		}
+		return tests.FakeExit{}
+	})
+
+	challenge, err := testServiceKit.ChallengeService.CreateChallenge(&entities.Challenge{
+		Name:               "Test Judge Interactive Challenge",
+		Description:        "Test Description",
+		Type:               entities.ChallengeTypeInteractive,
+		InteractorLanguage: entities.PythonInstructionBook.Language,
+		InteractorCode:     interactorCode,
+		Testcases: []*entities.ChallengeTestcase{
+			{Input: "21", ExpectedOutput: "42", LimitMemory: entities.SandboxMemoryMB * 128, LimitTimeMs: 1000},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	judgeTests := []struct {
+		name   string
+		answer int
+		exit   int
+		status string
+	}{
+		{"Accepted", 42, 0, entities.SubmissionStatusCorrect},
+		{"Wrong Answer", 0, 0, entities.SubmissionStatusWrong},
+		// a program cut off by a rejecting interactor often crashes, the rejection still decides
+		{"Wrong Answer Before Crash", 0, 1, entities.SubmissionStatusWrong},
+		{"Runtime Error", 42, 1, entities.SubmissionStatusRuntimeError},
+	}
+
+	for i, judgeTest := range judgeTests {
+		t.Run(judgeTest.name, func(t *testing.T) {
+			code := fmt.Sprintf("# interactive %d", i)
+			fakeDocker.HandleCode(code, func(process *tests.FakeProcess) tests.FakeExit {
+				fmt.Fprintln(process.Stdout, judgeTest.answer)
+				scanner := bufio.NewScanner(process.Stdin)
+				for scanner.Scan() && scanner.Text() != "done" {
+				}
+				return tests.FakeExit{ExitCode: judgeTest.exit}
+			})
+
+			submission, err := testServiceKit.SubmissionService.SubmitSubmission(&entities.Submission{
+				ChallengeID: challenge.ID,
+				UserID:      user.ID,
+				Language:    entities.PythonInstructionBook.Language,
+				Code:        code,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			submission, err = testServiceKit.SubmissionService.ProcessSubmission(context.Background(), submission)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if submission.Status != judgeTest.status {
+				t.Errorf("expected status %s, got %s", judgeTest.status, submission.Status)
+			}
+		})
+	}
+}
+
+// failingRunDocker fails to create the containers programs run in, like a Docker daemon
+// that went away while a submission was judged.
+type failingRunDocker struct {
+	*tests.FakeDockerService
+}
+
+func (d failingRunDocker) CreateContainer(config services.ContainerConfig) (container.CreateResponse, error) {
+	if strings.Contains(strings.Join(config.Command, " "), entities.SandboxUsageMarker) {
+		return container.CreateResponse{}, errors.New("docker is down")
+	}
+	return d.FakeDockerService.CreateContainer(config)
+}
+
+func TestSubmissionJudgeFailure(t *testing.T) {
+	db := databases.NewTempSQLiteDatabase()
+	testServiceKit := services.CreateTestServiceKitWithDocker(db, failingRunDocker{tests.NewFakeDockerService()})
+
+	user, err := testServiceKit.UserService.Register("test-judge-failure@example.com", "testpassword", "test-judge-failure")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenge, err := testServiceKit.ChallengeService.CreateChallenge(&entities.Challenge{
+		Name:        "Test Judge Failure Challenge",
+		Description: "Test Description",
+		Testcases: []*entities.ChallengeTestcase{
+			{Input: "1\n2\n", ExpectedOutput: "3\n", LimitMemory: entities.SandboxMemoryMB * 128, LimitTimeMs: 1000},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	submission, err := testServiceKit.SubmissionService.SubmitSubmission(&entities.Submission{
+		ChallengeID: challenge.ID,
+		UserID:      user.ID,
+		Language:    entities.PythonInstructionBook.Language,
+		Code:        "# judge failure",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = testServiceKit.SubmissionService.ProcessSubmission(context.Background(), submission)
+	if err == nil {
+		t.Fatal("expected a system error to fail the submission so its job is retried")
+	}
+
+	stored, err := testServiceKit.SubmissionService.GetSubmissionByID(submission.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.State != entities.SubmissionStateFailed || stored.Status != entities.SubmissionStatusSystemError {
+		t.Errorf("expected state %s with status %s, got %s with %s", entities.SubmissionStateFailed, entities.SubmissionStatusSystemError, stored.State, stored.Status)
+	}
+}
+
+func TestSubmissionJudgeStopped(t *testing.T) {
+	db := databases.NewTempSQLiteDatabase()
+	fakeDocker := tests.NewFakeDockerService()
+	testServiceKit := services.CreateTestServiceKitWithDocker(db, fakeDocker)
+
+	user, err := testServiceKit.UserService.Register("test-judge-stopped@example.com", "testpassword", "test-judge-stopped")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenge, err := testServiceKit.ChallengeService.CreateChallenge(&entities.Challenge{
+		Name:        "Test Judge Stopped Challenge",
+		Description: "Test Description",
+		Testcases: []*entities.ChallengeTestcase{
+			{Input: "1\n2\n", ExpectedOutput: "3\n", LimitMemory: entities.SandboxMemoryMB * 128, LimitTimeMs: 1000},
+		},
+		SerialTestcases: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	submit := func(t *testing.T, code string) *entities.Submission {
+		submission, err := testServiceKit.SubmissionService.SubmitSubmission(&entities.Submission{
+			ChallengeID: challenge.ID,
+			UserID:      user.ID,
+			Language:    entities.PythonInstructionBook.Language,
+			Code:        code,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return submission
+	}
+
+	expectState := func(t *testing.T, submissionID uint, state string) {
+		stored, err := testServiceKit.SubmissionService.GetSubmissionByID(submissionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.State != state {
+			t.Errorf("expected state %s, got %s", state, stored.State)
+		}
+	}
+
+	t.Run("Queued While Judged", func(t *testing.T) {
+		submission := submit(t, "# requeued")
+
+		// the submission is queued again while its testcase runs, like on a shutdown
+		fakeDocker.HandleCode("# requeued", func(process *tests.FakeProcess) tests.FakeExit {
+			stored, err := testServiceKit.SubmissionService.GetSubmissionByID(submission.ID)
+			if err == nil {
+				_, err = testServiceKit.SubmissionService.RequeueSubmission(stored)
+			}
+			if err != nil {
+				t.Error(err)
+			}
+			return fakeSum(process)
+		})
+
+		_, err := testServiceKit.SubmissionService.ProcessSubmission(context.Background(), submission)
+		if !errors.Is(err, repositories.ErrSubmissionStateChanged) {
+			t.Errorf("expected the result not to be saved, got %v", err)
+		}
+		expectState(t, submission.ID, entities.SubmissionStateQueued)
+	})
+
+	t.Run("Stopped", func(t *testing.T) {
+		submission := submit(t, "# stopped")
+
+		ctx, stop := context.WithCancel(context.Background())
+		fakeDocker.HandleCode("# stopped", func(process *tests.FakeProcess) tests.FakeExit {
+			stop()
+			return fakeSum(process)
+		})
+
+		_, err := testServiceKit.SubmissionService.ProcessSubmission(ctx, submission)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected judging to stop, got %v", err)
+		}
+		// the consumer queues it again
+		expectState(t, submission.ID, entities.SubmissionStateRunning)
+	})
+}
@@ -0,0 +1,214 @@
+package tests_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/wuttinanhi/code-judge-system/controllers"
+	"github.com/wuttinanhi/code-judge-system/databases"
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/repositories"
+	"github.com/wuttinanhi/code-judge-system/services"
+	"github.com/wuttinanhi/code-judge-system/tests"
+)
+
+func TestSubmissionRejudge(t *testing.T) {
+	db := databases.NewTempSQLiteDatabase()
+	testServiceKit := services.CreateTestServiceKit(db)
+	rateLimitStorage := controllers.GetMemoryStorage()
+	app := controllers.SetupAPI(testServiceKit, rateLimitStorage)
+	submissionRepo := repositories.NewSubmissionRepository(db)
+
+	staffUser, err := testServiceKit.UserService.Register("test-rejudge-staff@example.com", "testpassword", "test-rejudge-staff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = testServiceKit.UserService.UpdateRole(staffUser, entities.UserRoleStaff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	staffAccessToken, err := testServiceKit.JWTService.GenerateToken(*staffUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := testServiceKit.UserService.Register("test-rejudge-user@example.com", "testpassword", "test-rejudge-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userAccessToken, err := testServiceKit.JWTService.GenerateToken(*user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenge, err := testServiceKit.ChallengeService.CreateChallenge(&entities.Challenge{
+		Name:        "Test Rejudge Challenge",
+		Description: "Test Description",
+		Testcases: []*entities.ChallengeTestcase{
+			{Input: "1", ExpectedOutput: "1", LimitMemory: 1, LimitTimeMs: 1},
+			{Input: "2", ExpectedOutput: "2", LimitMemory: 2, LimitTimeMs: 2},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// createJudgedSubmission creates a submission as if the consumer judged it
+	createJudgedSubmission := func() *entities.Submission {
+		submission, err := testServiceKit.SubmissionService.SubmitSubmission(&entities.Submission{
+			ChallengeID: challenge.ID,
+			UserID:      user.ID,
+			Language:    "python",
+			Code:        "print(1)",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, testcase := range submission.SubmissionTestcases {
+			testcase.Status = entities.SubmissionStatusWrong
+		}
+		submission.Status = entities.SubmissionStatusWrong
+		submission.State = entities.SubmissionStateJudged
+
+		submission, err = submissionRepo.UpdateSubmission(submission)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return submission
+	}
+
+	rejudge := func(accessToken, path string) (*http.Response, entities.SubmissionRejudgeResponse) {
+		request, _ := http.NewRequest(http.MethodPost, path, nil)
+		request.Header.Set("Authorization", "Bearer "+accessToken)
+
+		response, err := app.Test(request, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var body entities.SubmissionRejudgeResponse
+		json.Unmarshal(tests.ResponseBodyToBytes(response), &body)
+		return response, body
+	}
+
+	t.Run("/submission/rejudge/:id", func(t *testing.T) {
+		submission := createJudgedSubmission()
+
+		response, _ := rejudge(userAccessToken, fmt.Sprintf("/submission/rejudge/%d", submission.ID))
+		if response.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected status Forbidden, got %v", response.StatusCode)
+		}
+
+		response, body := rejudge(staffAccessToken, fmt.Sprintf("/submission/rejudge/%d", submission.ID))
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status OK, got %v", response.StatusCode)
+		}
+		if body.Total != 1 {
+			t.Errorf("Expected 1 rejudged submission, got %v", body.Total)
+		}
+
+		submission, err := testServiceKit.SubmissionService.GetSubmissionByID(submission.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if submission.Status != entities.SubmissionStatusPending {
+			t.Errorf("Expected status %v, got %v", entities.SubmissionStatusPending, submission.Status)
+		}
+		if len(submission.SubmissionTestcases) != len(challenge.Testcases) {
+			t.Errorf("Expected %v testcases, got %v", len(challenge.Testcases), len(submission.SubmissionTestcases))
+		}
+		for _, testcase := range submission.SubmissionTestcases {
+			if testcase.Status != entities.SubmissionStatusPending {
+				t.Errorf("Expected testcase status %v, got %v", entities.SubmissionStatusPending, testcase.Status)
+			}
+		}
+		if len(submission.VerdictHistory) != 1 {
+			t.Fatalf("Expected 1 verdict history, got %v", len(submission.VerdictHistory))
+		}
+		if submission.VerdictHistory[0].Status != entities.SubmissionStatusWrong {
+			t.Errorf("Expected previous status %v, got %v", entities.SubmissionStatusWrong, submission.VerdictHistory[0].Status)
+		}
+		if submission.VerdictHistory[0].RejudgedByID != staffUser.ID {
+			t.Errorf("Expected rejudged by %v, got %v", staffUser.ID, submission.VerdictHistory[0].RejudgedByID)
+		}
+	})
+
+	t.Run("/submission/rejudge/:id Not Finished", func(t *testing.T) {
+		submission := createJudgedSubmission()
+
+		response, _ := rejudge(staffAccessToken, fmt.Sprintf("/submission/rejudge/%d", submission.ID))
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status OK, got %v", response.StatusCode)
+		}
+
+		// the submission is queued now and its judge would write over a reset
+		response, _ = rejudge(staffAccessToken, fmt.Sprintf("/submission/rejudge/%d", submission.ID))
+		if response.StatusCode != http.StatusConflict {
+			t.Errorf("Expected status Conflict, got %v", response.StatusCode)
+		}
+	})
+
+	t.Run("Rejudge Of Stale Submission", func(t *testing.T) {
+		submission := createJudgedSubmission()
+		stale, err := testServiceKit.SubmissionService.GetSubmissionByID(submission.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = testServiceKit.SubmissionService.RejudgeSubmission(submission, staffUser, "first")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = testServiceKit.SubmissionService.RejudgeSubmission(stale, staffUser, "second")
+		if !errors.Is(err, repositories.ErrSubmissionStateChanged) {
+			t.Errorf("Expected %v, got %v", repositories.ErrSubmissionStateChanged, err)
+		}
+
+		// a judge still holding a replaced testcase does not bring it back
+		_, err = submissionRepo.UpdateSubmissionTestcase(stale.SubmissionTestcases[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		submission, err = testServiceKit.SubmissionService.GetSubmissionByID(submission.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(submission.SubmissionTestcases) != len(challenge.Testcases) {
+			t.Errorf("Expected %v testcases, got %v", len(challenge.Testcases), len(submission.SubmissionTestcases))
+		}
+		if len(submission.VerdictHistory) != 1 {
+			t.Errorf("Expected 1 verdict history, got %v", len(submission.VerdictHistory))
+		}
+	})
+
+	t.Run("/submission/rejudge/challenge/:id", func(t *testing.T) {
+		createJudgedSubmission()
+
+		// the submission rejudged above is still pending and is skipped
+		response, body := rejudge(staffAccessToken, fmt.Sprintf("/submission/rejudge/challenge/%d", challenge.ID))
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status OK, got %v", response.StatusCode)
+		}
+		if body.Total != 1 {
+			t.Errorf("Expected 1 rejudged submission, got %v", body.Total)
+		}
+	})
+
+	t.Run("/submission/rejudge/testcase/:id", func(t *testing.T) {
+		createJudgedSubmission()
+
+		response, body := rejudge(staffAccessToken, fmt.Sprintf("/submission/rejudge/testcase/%d", challenge.Testcases[0].ID))
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status OK, got %v", response.StatusCode)
+		}
+		if body.Total != 1 {
+			t.Errorf("Expected 1 rejudged submission, got %v", body.Total)
+		}
+	})
+}
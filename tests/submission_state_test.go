@@ -0,0 +1,93 @@
+package tests_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/wuttinanhi/code-judge-system/databases"
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/repositories"
+	"github.com/wuttinanhi/code-judge-system/services"
+)
+
+func TestSubmissionState(t *testing.T) {
+	t.Run("Transitions", func(t *testing.T) {
+		submission := &entities.Submission{State: entities.SubmissionStateQueued}
+		now := time.Now()
+
+		steps := []string{
+			entities.SubmissionStateCompiling,
+			entities.SubmissionStateRunning,
+			entities.SubmissionStateJudged,
+			entities.SubmissionStateQueued,
+		}
+		for _, state := range steps {
+			if err := submission.TransitionTo(state, now); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if submission.QueuedAt == nil || submission.StartedAt != nil || submission.FinishedAt != nil {
+			t.Error("Expected only queued_at to be set after queueing again")
+		}
+
+		if err := submission.TransitionTo(entities.SubmissionStateJudged, now); err == nil {
+			t.Error("Expected error for QUEUED to JUDGED")
+		}
+		if err := submission.TransitionTo(entities.SubmissionStateCompiling, now); err != nil {
+			t.Fatal(err)
+		}
+		if submission.StartedAt == nil {
+			t.Error("Expected started_at to be set")
+		}
+		if err := submission.TransitionTo(entities.SubmissionStateFailed, now); err != nil {
+			t.Fatal(err)
+		}
+		if submission.FinishedAt == nil {
+			t.Error("Expected finished_at to be set")
+		}
+		if err := submission.TransitionTo(entities.SubmissionStateRunning, now); err == nil {
+			t.Error("Expected error for FAILED to RUNNING")
+		}
+	})
+
+	t.Run("Submit And Process", func(t *testing.T) {
+		db := databases.NewTempSQLiteDatabase()
+		testServiceKit := services.CreateTestServiceKit(db)
+		submissionRepo := repositories.NewSubmissionRepository(db)
+
+		challenge, err := testServiceKit.ChallengeService.CreateChallenge(&entities.Challenge{
+			Name: "Test State Challenge",
+			Testcases: []*entities.ChallengeTestcase{
+				{Input: "1", ExpectedOutput: "1", LimitMemory: 1, LimitTimeMs: 1},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		submission, err := testServiceKit.SubmissionService.SubmitSubmission(&entities.Submission{
+			ChallengeID: challenge.ID,
+			Language:    "python",
+			Code:        "print(1)",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if submission.State != entities.SubmissionStateQueued || submission.QueuedAt == nil {
+			t.Errorf("Expected state %v with queued_at, got %v", entities.SubmissionStateQueued, submission.State)
+		}
+
+		// a judged submission must not be judged again
+		submission.State = entities.SubmissionStateJudged
+		_, err = submissionRepo.UpdateSubmission(submission)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = testServiceKit.SubmissionService.ProcessSubmission(context.Background(), submission)
+		if err == nil {
+			t.Error("Expected error when processing a judged submission")
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package tests_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/wuttinanhi/code-judge-system/controllers"
+	"github.com/wuttinanhi/code-judge-system/databases"
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/repositories"
+	"github.com/wuttinanhi/code-judge-system/services"
+	"github.com/wuttinanhi/code-judge-system/tests"
+)
+
+func TestSubmissionStderrVisibility(t *testing.T) {
+	owner := &entities.User{ID: 1, Role: entities.UserRoleUser}
+	other := &entities.User{ID: 2, Role: entities.UserRoleUser}
+	staff := &entities.User{ID: 3, Role: entities.UserRoleStaff}
+	submission := &entities.Submission{UserID: owner.ID}
+
+	visibilityTests := []struct {
+		policy string
+		user   *entities.User
+		want   bool
+	}{
+		{entities.SubmissionStderrVisibilityAll, other, true},
+		{entities.SubmissionStderrVisibilityOwner, owner, true},
+		{entities.SubmissionStderrVisibilityOwner, staff, true},
+		{entities.SubmissionStderrVisibilityOwner, other, false},
+		{entities.SubmissionStderrVisibilityStaff, owner, false},
+		{entities.SubmissionStderrVisibilityStaff, staff, true},
+		{entities.SubmissionStderrVisibilityNone, staff, false},
+	}
+
+	for _, visibilityTest := range visibilityTests {
+		got := submission.StderrVisibleTo(visibilityTest.user, visibilityTest.policy)
+		if got != visibilityTest.want {
+			t.Errorf("policy %s user %d: expected %v, got %v", visibilityTest.policy, visibilityTest.user.ID, visibilityTest.want, got)
+		}
+	}
+
+	if entities.IsSubmissionStderrVisibility("everyone") {
+		t.Error("expected unknown policy to be invalid")
+	}
+}
+
+func TestSubmissionStderrRoute(t *testing.T) {
+	db := databases.NewTempSQLiteDatabase()
+	testServiceKit := services.CreateTestServiceKit(db)
+	rateLimitStorage := controllers.GetMemoryStorage()
+	app := controllers.SetupAPI(testServiceKit, rateLimitStorage)
+	submissionRepo := repositories.NewSubmissionRepository(db)
+
+	owner, err := testServiceKit.UserService.Register("test-stderr-owner@example.com", "testpassword", "test-stderr-owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownerAccessToken, err := testServiceKit.JWTService.GenerateToken(*owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := testServiceKit.UserService.Register("test-stderr-other@example.com", "testpassword", "test-stderr-other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherAccessToken, err := testServiceKit.JWTService.GenerateToken(*other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenge, err := testServiceKit.ChallengeService.CreateChallenge(&entities.Challenge{
+		Name:        "Test Stderr Challenge",
+		Description: "Test Description",
+		Testcases: []*entities.ChallengeTestcase{
+			{Input: "1", ExpectedOutput: "1", LimitMemory: 1, LimitTimeMs: 1},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	submission, err := testServiceKit.SubmissionService.SubmitSubmission(&entities.Submission{
+		ChallengeID: challenge.ID,
+		UserID:      owner.ID,
+		Language:    "python",
+		Code:        "print(1)",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// store the testcase as if the consumer judged it
+	submission.SubmissionTestcases[0].Output = "1\n"
+	submission.SubmissionTestcases[0].Stderr = "debug\n"
+	_, err = submissionRepo.UpdateSubmissionTestcase(submission.SubmissionTestcases[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getSubmission := func(accessToken string) *entities.Submission {
+		request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/submission/get/%d", submission.ID), nil)
+		request.Header.Set("Authorization", "Bearer "+accessToken)
+
+		response, err := app.Test(request, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status OK, got %v", response.StatusCode)
+		}
+
+		var body entities.Submission
+		json.Unmarshal(tests.ResponseBodyToBytes(response), &body)
+		if len(body.SubmissionTestcases) != 1 {
+			t.Fatalf("Expected 1 testcase, got %v", len(body.SubmissionTestcases))
+		}
+		return &body
+	}
+
+	t.Run("Owner Sees Stderr", func(t *testing.T) {
+		body := getSubmission(ownerAccessToken)
+		if body.SubmissionTestcases[0].Stderr != "debug\n" {
+			t.Errorf("Expected stderr %q, got %q", "debug\n", body.SubmissionTestcases[0].Stderr)
+		}
+		if body.SubmissionTestcases[0].Output != "1\n" {
+			t.Errorf("Expected output %q, got %q", "1\n", body.SubmissionTestcases[0].Output)
+		}
+	})
+
+	t.Run("Other User Does Not See Stderr", func(t *testing.T) {
+		body := getSubmission(otherAccessToken)
+		if body.SubmissionTestcases[0].Stderr != "" {
+			t.Errorf("Expected empty stderr, got %q", body.SubmissionTestcases[0].Stderr)
+		}
+		if body.SubmissionTestcases[0].Output != "1\n" {
+			t.Errorf("Expected output %q, got %q", "1\n", body.SubmissionTestcases[0].Output)
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package tests_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wuttinanhi/code-judge-system/controllers"
+	"github.com/wuttinanhi/code-judge-system/databases"
+	"github.com/wuttinanhi/code-judge-system/entities"
+	"github.com/wuttinanhi/code-judge-system/repositories"
+	"github.com/wuttinanhi/code-judge-system/services"
+	"github.com/wuttinanhi/code-judge-system/tests"
+)
+
+func TestSubmissionStream(t *testing.T) {
+	db := databases.NewTempSQLiteDatabase()
+	testServiceKit := services.CreateTestServiceKit(db)
+	rateLimitStorage := controllers.GetMemoryStorage()
+	app := controllers.SetupAPI(testServiceKit, rateLimitStorage)
+	submissionRepo := repositories.NewSubmissionRepository(db)
+
+	user, err := testServiceKit.UserService.Register("test-stream@example.com", "testpassword", "test-stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userAccessToken, err := testServiceKit.JWTService.GenerateToken(*user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenge, err := testServiceKit.ChallengeService.CreateChallenge(&entities.Challenge{
+		Name:        "Test Stream Challenge",
+		Description: "Test Description",
+		Testcases: []*entities.ChallengeTestcase{
+			{Input: "1", ExpectedOutput: "1", LimitMemory: 1, LimitTimeMs: 1},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	submit := func() *entities.Submission {
+		submission, err := testServiceKit.SubmissionService.SubmitSubmission(&entities.Submission{
+			ChallengeID: challenge.ID,
+			UserID:      user.ID,
+			Language:    "python",
+			Code:        "print(1)",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return submission
+	}
+
+	stream := func(path string) *http.Response {
+		request, _ := http.NewRequest(http.MethodGet, path, nil)
+
+		response, err := app.Test(request, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	t.Run("/submission/stream/:id judged submission", func(t *testing.T) {
+		submission := submit()
+		submission.Status = entities.SubmissionStatusCorrect
+		submission.State = entities.SubmissionStateJudged
+		_, err := submissionRepo.UpdateSubmission(submission)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response := stream(fmt.Sprintf("/submission/stream/%d?token=%s", submission.ID, userAccessToken))
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status OK, got %v", response.StatusCode)
+		}
+		if response.Header.Get("Content-Type") != "text/event-stream" {
+			t.Errorf("Expected event stream, got %v", response.Header.Get("Content-Type"))
+		}
+
+		body := tests.ResponseBodyToString(response)
+		if !strings.HasPrefix(body, "event: result\ndata: ") {
+			t.Errorf("Expected a single result event, got %v", body)
+		}
+		if !strings.Contains(body, `"status":"CORRECT"`) {
+			t.Errorf("Expected status CORRECT in event, got %v", body)
+		}
+	})
+
+	t.Run("/submission/stream/:id live events", func(t *testing.T) {
+		submission := submit()
+
+		go func() {
+			// give the request time to subscribe
+			time.Sleep(200 * time.Millisecond)
+
+			testcase := submission.SubmissionTestcases[0]
+			testcase.Status = entities.SubmissionStatusCorrect
+			testServiceKit.SubmissionEventService.Publish(entities.NewSubmissionTestcaseEvent(submission, testcase))
+
+			submission.Status = entities.SubmissionStatusCorrect
+			submission.State = entities.SubmissionStateJudged
+			testServiceKit.SubmissionEventService.Publish(entities.NewSubmissionEvent(entities.SubmissionEventResult, submission))
+		}()
+
+		response := stream(fmt.Sprintf("/submission/stream/%d?token=%s", submission.ID, userAccessToken))
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status OK, got %v", response.StatusCode)
+		}
+
+		body := tests.ResponseBodyToString(response)
+		events := strings.Split(strings.TrimSpace(body), "\n\n")
+		if len(events) != 3 {
+			t.Fatalf("Expected 3 events, got %v", body)
+		}
+		if !strings.HasPrefix(events[0], "event: state\n") || !strings.Contains(events[0], `"state":"QUEUED"`) {
+			t.Errorf("Expected queued state event, got %v", events[0])
+		}
+		if !strings.HasPrefix(events[1], "event: testcase\n") || !strings.Contains(events[1], `"status":"CORRECT"`) {
+			t.Errorf("Expected correct testcase event, got %v", events[1])
+		}
+		if !strings.HasPrefix(events[2], "event: result\n") || !strings.Contains(events[2], `"state":"JUDGED"`) {
+			t.Errorf("Expected judged result event, got %v", events[2])
+		}
+	})
+
+	t.Run("/submission/stream/:id not found", func(t *testing.T) {
+		response := stream("/submission/stream/999999?token=" + userAccessToken)
+		if response.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected status NotFound, got %v", response.StatusCode)
+		}
+	})
+
+	t.Run("/submission/stream/:id unauthorized", func(t *testing.T) {
+		response := stream("/submission/stream/1")
+		if response.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected status Unauthorized, got %v", response.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package tests_test
+
+import (
+	"testing"
+
+	"github.com/wuttinanhi/code-judge-system/entities"
+)
+
+func TestSubmissionVerdict(t *testing.T) {
+	verdictTests := []struct {
+		name     string
+		statuses []string
+		verdict  string
+	}{
+		{"Correct", []string{entities.SubmissionStatusCorrect, entities.SubmissionStatusCorrect}, entities.SubmissionStatusCorrect},
+		{"First Failing Testcase", []string{entities.SubmissionStatusCorrect, entities.SubmissionStatusTimeLimitExceeded, entities.SubmissionStatusWrong}, entities.SubmissionStatusTimeLimitExceeded},
+		{"System Error After Wrong", []string{entities.SubmissionStatusWrong, entities.SubmissionStatusSystemError}, entities.SubmissionStatusSystemError},
+	}
+
+	for _, verdictTest := range verdictTests {
+		t.Run(verdictTest.name, func(t *testing.T) {
+			submission := &entities.Submission{}
+			for _, status := range verdictTest.statuses {
+				submission.SubmissionTestcases = append(submission.SubmissionTestcases, &entities.SubmissionTestcase{Status: status})
+			}
+
+			if verdict := submission.Verdict(); verdict != verdictTest.verdict {
+				t.Errorf("expected verdict %s, got %s", verdictTest.verdict, verdict)
+			}
+		})
+	}
+}
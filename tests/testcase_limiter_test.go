@@ -0,0 +1,86 @@
+package tests_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wuttinanhi/code-judge-system/services"
+)
+
+func TestTestcaseLimiter(t *testing.T) {
+	t.Run("Limit Parallel Testcases", func(t *testing.T) {
+		limiter := services.NewTestcaseLimiter(2)
+
+		var running, maxRunning int32
+		wg := sync.WaitGroup{}
+		for i := 0; i < 8; i++ {
+			release := limiter.Acquire()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer release()
+
+				current := atomic.AddInt32(&running, 1)
+				for {
+					max := atomic.LoadInt32(&maxRunning)
+					if current <= max || atomic.CompareAndSwapInt32(&maxRunning, max, current) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+			}()
+		}
+		wg.Wait()
+
+		if maxRunning != 2 {
+			t.Errorf("expected at most 2 testcases at the same time, got %d", maxRunning)
+		}
+	})
+
+	t.Run("Exclusive Waits For All Slots", func(t *testing.T) {
+		limiter := services.NewTestcaseLimiter(2)
+
+		release := limiter.Acquire()
+
+		acquired := make(chan func())
+		go func() {
+			acquired <- limiter.AcquireExclusive()
+		}()
+
+		select {
+		case <-acquired:
+			t.Fatal("expected exclusive acquire to wait for the running testcase")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		release()
+		releaseExclusive := <-acquired
+
+		// no testcase runs beside an exclusive one
+		acquiredAfter := make(chan func())
+		go func() {
+			acquiredAfter <- limiter.Acquire()
+		}()
+
+		select {
+		case <-acquiredAfter:
+			t.Fatal("expected acquire to wait for the exclusive testcase")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		releaseExclusive()
+		(<-acquiredAfter)()
+	})
+
+	t.Run("Unlimited", func(t *testing.T) {
+		limiter := services.NewTestcaseLimiter(0)
+
+		for i := 0; i < 100; i++ {
+			limiter.Acquire()
+		}
+		limiter.AcquireExclusive()()
+	})
+}